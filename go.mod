@@ -3,7 +3,6 @@ module github.com/OpenListTeam/wazero-wasip2
 go 1.23.0
 
 require (
-	github.com/hashicorp/golang-lru/v2 v2.0.7
 	github.com/stretchr/testify v1.10.0
 	github.com/tetratelabs/wazero v1.9.0
 	golang.org/x/sys v0.34.0
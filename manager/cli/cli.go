@@ -0,0 +1,28 @@
+package cli
+
+import (
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+// TerminalInput 代表一个连接到终端的输入端。
+// 目前 wasi:cli 没有为它定义任何方法，仅作为能力标记使用。
+type TerminalInput struct{}
+
+// TerminalOutput 代表一个连接到终端的输出端。
+type TerminalOutput struct{}
+
+// TerminalInputManager 是 terminal-input 资源的管理器。
+type TerminalInputManager = witgo.ResourceManager[*TerminalInput]
+
+// TerminalOutputManager 是 terminal-output 资源的管理器。
+type TerminalOutputManager = witgo.ResourceManager[*TerminalOutput]
+
+// NewTerminalInputManager 创建一个新的 terminal-input 管理器。
+func NewTerminalInputManager() *TerminalInputManager {
+	return witgo.NewResourceManager[*TerminalInput](nil)
+}
+
+// NewTerminalOutputManager 创建一个新的 terminal-output 管理器。
+func NewTerminalOutputManager() *TerminalOutputManager {
+	return witgo.NewResourceManager[*TerminalOutput](nil)
+}
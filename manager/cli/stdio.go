@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"sync"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/io"
+)
+
+// Stdio 持有一组标准输入输出流，wasi:cli 的 stdin、stdout、stderr 和 exit 共用。
+// 每个流在第一次被获取时才通过对应的函数创建。
+type Stdio struct {
+	mu                             sync.Mutex
+	newStdin, newStdout, newStderr func() *io.Stream
+	stdin, stdout, stderr          *io.Stream
+}
+
+// NewStdio 创建一组标准流，stdin、stdout 和 stderr 分别用于创建对应的流。
+func NewStdio(stdin, stdout, stderr func() *io.Stream) *Stdio {
+	return &Stdio{newStdin: stdin, newStdout: stdout, newStderr: stderr}
+}
+
+func (s *Stdio) get(stream **io.Stream, create func() *io.Stream) *io.Stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if *stream == nil {
+		*stream = create()
+	}
+	return *stream
+}
+
+// Stdin 返回标准输入流。
+func (s *Stdio) Stdin() *io.Stream { return s.get(&s.stdin, s.newStdin) }
+
+// Stdout 返回标准输出流。
+func (s *Stdio) Stdout() *io.Stream { return s.get(&s.stdout, s.newStdout) }
+
+// Stderr 返回标准错误流。
+func (s *Stdio) Stderr() *io.Stream { return s.get(&s.stderr, s.newStderr) }
+
+// Flush 将已经创建的 stdout 和 stderr 中缓冲的数据全部写入底层 writer。
+func (s *Stdio) Flush() {
+	s.mu.Lock()
+	streams := []*io.Stream{s.stdout, s.stderr}
+	s.mu.Unlock()
+	for _, stream := range streams {
+		if stream != nil && stream.Flusher != nil {
+			_ = stream.Flusher.Flush()
+		}
+	}
+}
+
+// Close 关闭已经创建的流，输出流中缓冲的数据会先被写出。
+func (s *Stdio) Close() {
+	s.mu.Lock()
+	streams := []*io.Stream{s.stdin, s.stdout, s.stderr}
+	s.mu.Unlock()
+	for _, stream := range streams {
+		if stream != nil && stream.Closer != nil {
+			_ = stream.Closer.Close()
+		}
+	}
+}
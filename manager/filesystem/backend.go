@@ -0,0 +1,169 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File 是描述符背后一个已打开的文件或目录。
+type File interface {
+	io.Closer
+	io.ReaderAt
+	io.WriterAt
+	Stat() (fs.FileInfo, error)
+	Truncate(size int64) error
+	Sync() error
+	// ReadDirectory 返回目录中的全部条目。
+	ReadDirectory() ([]fs.DirEntry, error)
+	// Chtimes 修改文件的访问时间和修改时间。
+	Chtimes(atime, mtime time.Time) error
+}
+
+// Backend 是 wasi:filesystem 的存储后端。
+//
+// 除 Root 外，所有方法中的路径都是相对于目录 dir 的 Guest 路径。
+// 后端必须保证解析结果不会离开 dir，越界时返回 ErrNotPermitted。
+// follow 表示是否跟随最后一个分量上的符号链接，中间分量上的符号链接总是会被跟随。
+// dir 必须是由同一个后端打开的文件，否则返回 ErrCrossDevice。
+type Backend interface {
+	// Root 打开后端的根目录，用作预打开目录。
+	Root() (File, error)
+	OpenAt(dir File, path string, follow bool, flag int, perm fs.FileMode) (File, error)
+	StatAt(dir File, path string, follow bool) (fs.FileInfo, error)
+	MkdirAt(dir File, path string, perm fs.FileMode) error
+	ReadlinkAt(dir File, path string) (string, error)
+	RmdirAt(dir File, path string) error
+	UnlinkAt(dir File, path string) error
+	RenameAt(oldDir File, oldPath string, newDir File, newPath string) error
+	LinkAt(oldDir File, oldPath string, follow bool, newDir File, newPath string) error
+	SymlinkAt(target string, dir File, path string) error
+	ChtimesAt(dir File, path string, follow bool, atime, mtime time.Time) error
+}
+
+// 后端返回的错误。除这些错误外，后端也可以返回 fs.ErrNotExist 等标准错误或系统错误。
+var (
+	// ErrNotPermitted 表示操作不被允许，例如路径试图离开基准目录。
+	ErrNotPermitted = errors.New("operation not permitted")
+	// ErrLoop 表示符号链接过多，或者在不跟随时遇到了符号链接。
+	ErrLoop = errors.New("too many levels of symbolic links")
+	// ErrIsDirectory 表示对目录执行了只适用于文件的操作。
+	ErrIsDirectory = errors.New("is a directory")
+	// ErrNotDirectory 表示路径中的某个分量不是目录。
+	ErrNotDirectory = errors.New("not a directory")
+	// ErrNotEmpty 表示目录非空。
+	ErrNotEmpty = errors.New("directory not empty")
+	// ErrReadOnly 表示后端是只读的。
+	ErrReadOnly = errors.New("read-only file system")
+	// ErrCrossDevice 表示操作跨越了不同的后端。
+	ErrCrossDevice = errors.New("cross-device link")
+	// ErrBadDescriptor 表示文件的打开模式不支持该操作。
+	ErrBadDescriptor = errors.New("bad file descriptor")
+	// ErrFileTooLarge 表示文件超过了后端允许的大小。
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrNoSpace 表示后端没有剩余的空间。
+	ErrNoSpace = errors.New("no space left on device")
+)
+
+// 与 Linux 保持一致的符号链接解析深度上限。
+const maxSymlinks = 40
+
+// SameFile 判断两个文件是否指向同一个对象。
+func SameFile(a, b File) bool {
+	switch a := a.(type) {
+	case *OSFile:
+		b, ok := b.(*OSFile)
+		if !ok {
+			return false
+		}
+		infoA, errA := a.Stat()
+		infoB, errB := b.Stat()
+		return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+	case *fsFile:
+		b, ok := b.(*fsFile)
+		return ok && a.backend == b.backend && a.name == b.name
+	case *memFile:
+		b, ok := b.(*memFile)
+		return ok && a.node == b.node
+	case *overlayFile:
+		b, ok := b.(*overlayFile)
+		return ok && a.o == b.o && a.path == b.path
+	}
+	return false
+}
+
+// isGuestSeparator 判断 r 是否为路径分隔符。
+// Guest 使用 '/'，但在 Windows 上 '\' 同样会被系统当作分隔符，必须一并处理。
+func isGuestSeparator(r rune) bool {
+	return r == '/' || (r < 0x80 && os.IsPathSeparator(uint8(r)))
+}
+
+// guestPathComponents 将路径拆分为各个分量，空分量会被忽略。
+func guestPathComponents(path string) []string {
+	return strings.FieldsFunc(path, isGuestSeparator)
+}
+
+// isAbsGuestPath 判断 path 是否为绝对路径或带有卷名（如 Windows 的 "C:"）。
+func isAbsGuestPath(path string) bool {
+	return path != "" && (isGuestSeparator(rune(path[0])) || filepath.IsAbs(path) || filepath.VolumeName(path) != "")
+}
+
+// checkGuestPath 拒绝绝对路径，以及在词法上就会越出基准目录的路径。
+// 这只是快速检查，真正的保证来自逐级解析。
+func checkGuestPath(path string) error {
+	if isAbsGuestPath(path) {
+		return ErrNotPermitted
+	}
+	depth := 0
+	for _, c := range guestPathComponents(path) {
+		switch c {
+		case ".":
+		case "..":
+			depth--
+			if depth < 0 {
+				return ErrNotPermitted
+			}
+		default:
+			depth++
+		}
+	}
+	return nil
+}
+
+// guestParent 将路径拆分为父目录路径和最后一个分量。
+// 会修改目录项的操作要求最后一个分量是普通名字，不能是 "." 或 ".."。
+func guestParent(path string) (string, string, error) {
+	if err := checkGuestPath(path); err != nil {
+		return "", "", err
+	}
+	trimmed := strings.TrimRightFunc(path, isGuestSeparator)
+	parent, base := "", trimmed
+	if i := strings.LastIndexFunc(trimmed, isGuestSeparator); i >= 0 {
+		parent, base = trimmed[:i], trimmed[i+1:]
+	}
+	switch base {
+	case "":
+		return "", "", fs.ErrNotExist
+	case ".", "..":
+		return "", "", fs.ErrInvalid
+	}
+	return parent, base, nil
+}
+
+// checkSymlinkTarget 拒绝绝对路径的链接目标。
+// 即便解析时会拦截，这类链接在沙盒内也永远无法使用。
+func checkSymlinkTarget(target string) error {
+	if isAbsGuestPath(target) {
+		return ErrNotPermitted
+	}
+	return nil
+}
+
+// isWriteFlag 判断打开模式是否需要写权限。
+func isWriteFlag(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
@@ -2,20 +2,23 @@ package filesystem
 
 import (
 	"io/fs"
-	"os"
 
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
 )
 
 // Descriptor represents a file or directory descriptor.
-// It holds the underlying os.File and the pre-opened path.
+// It holds the underlying file and the pre-opened path.
 type Descriptor struct {
-	// The underlying file descriptor from Go's os package.
-	File *os.File
+	// The underlying open file, provided by Backend.
+	File File
+	// Backend 是该描述符所属的后端，所有 *-at 操作都通过它完成。
+	Backend Backend
 	// The path this descriptor was pre-opened with, for identification.
 	Path string
 	// Permissions associated with this descriptor.
 	Flags fs.FileMode // We can use fs.FileMode to store basic permissions
+	// ReadOnly 为 true 时，禁止通过该描述符（及从它打开的描述符）进行任何写入或修改。
+	ReadOnly bool
 }
 
 // Manager is the resource manager for all filesystem descriptors.
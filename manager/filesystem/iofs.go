@@ -0,0 +1,190 @@
+package filesystem
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// FSBackend 将任意 fs.FS（例如 embed.FS 或 zip.Reader）作为只读后端。
+// fs.FS 不支持符号链接，所有路径都按词法解析。
+type FSBackend struct {
+	fsys fs.FS
+}
+
+// NewFSBackend 创建以 fsys 为内容的只读后端。
+func NewFSBackend(fsys fs.FS) *FSBackend {
+	return &FSBackend{fsys: fsys}
+}
+
+// fsFile 是 FSBackend 打开的文件。
+type fsFile struct {
+	backend *FSBackend
+	// name 是文件在 fsys 中的路径，根目录为 "."。
+	name string
+
+	mu sync.Mutex
+	// f 是用于读取内容的底层文件，目录不会打开它。
+	f fs.File
+	// pos 是 f 当前的读取位置，用于不支持随机访问的文件。
+	pos int64
+}
+
+func (f *fsFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return fs.Stat(f.backend.fsys, f.name)
+}
+
+// ReadAt 尽量使用底层文件的随机读取能力，否则退化为顺序读取。
+func (f *fsFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		info, err := fs.Stat(f.backend.fsys, f.name)
+		if err != nil {
+			return 0, err
+		}
+		if info.IsDir() {
+			return 0, ErrIsDirectory
+		}
+		return 0, fs.ErrClosed
+	}
+
+	if ra, ok := f.f.(io.ReaderAt); ok {
+		return ra.ReadAt(p, off)
+	}
+	if seeker, ok := f.f.(io.Seeker); ok {
+		if _, err := seeker.Seek(off, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return readFull(f.f, p)
+	}
+
+	// 既不能随机读取也不能 Seek，只能向前跳过；需要回退时重新打开文件。
+	if off < f.pos {
+		nf, err := f.backend.fsys.Open(f.name)
+		if err != nil {
+			return 0, err
+		}
+		f.f.Close()
+		f.f, f.pos = nf, 0
+	}
+	if off > f.pos {
+		n, err := io.CopyN(io.Discard, f.f, off-f.pos)
+		f.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := readFull(f.f, p)
+	f.pos += int64(n)
+	return n, err
+}
+
+// readFull 与 io.ReadFull 相同，但按照 io.ReaderAt 的约定在读到结尾时返回 io.EOF。
+func readFull(r io.Reader, p []byte) (int, error) {
+	n, err := io.ReadFull(r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *fsFile) WriteAt([]byte, int64) (int, error) { return 0, ErrReadOnly }
+func (f *fsFile) Truncate(int64) error               { return ErrReadOnly }
+func (f *fsFile) Chtimes(time.Time, time.Time) error { return ErrReadOnly }
+func (f *fsFile) Sync() error                        { return nil }
+
+func (f *fsFile) ReadDirectory() ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.backend.fsys, f.name)
+}
+
+func (b *FSBackend) Root() (File, error) {
+	if _, err := fs.Stat(b.fsys, "."); err != nil {
+		return nil, err
+	}
+	return &fsFile{backend: b, name: "."}, nil
+}
+
+// resolve 将相对于 dir 的 path 转换为 fsys 中的路径。
+func (b *FSBackend) resolve(dir File, p string) (string, error) {
+	d, ok := dir.(*fsFile)
+	if !ok || d.backend != b {
+		return "", ErrCrossDevice
+	}
+	if err := checkGuestPath(p); err != nil {
+		return "", err
+	}
+	name := path.Join(append([]string{d.name}, guestPathComponents(p)...)...)
+	if !fs.ValidPath(name) {
+		return "", ErrNotPermitted
+	}
+	return name, nil
+}
+
+func (b *FSBackend) OpenAt(dir File, p string, _ bool, flag int, _ fs.FileMode) (File, error) {
+	name, err := b.resolve(dir, p)
+	if err != nil {
+		return nil, err
+	}
+	if isWriteFlag(flag) {
+		return nil, ErrReadOnly
+	}
+	info, err := fs.Stat(b.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	file := &fsFile{backend: b, name: name}
+	if !info.IsDir() {
+		if file.f, err = b.fsys.Open(name); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (b *FSBackend) StatAt(dir File, p string, _ bool) (fs.FileInfo, error) {
+	name, err := b.resolve(dir, p)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(b.fsys, name)
+}
+
+func (b *FSBackend) ReadlinkAt(dir File, p string) (string, error) {
+	name, err := b.resolve(dir, p)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fs.Stat(b.fsys, name); err != nil {
+		return "", err
+	}
+	// fs.FS 中没有符号链接。
+	return "", fs.ErrInvalid
+}
+
+func (b *FSBackend) MkdirAt(File, string, fs.FileMode) error { return ErrReadOnly }
+func (b *FSBackend) RmdirAt(File, string) error              { return ErrReadOnly }
+func (b *FSBackend) UnlinkAt(File, string) error             { return ErrReadOnly }
+func (b *FSBackend) RenameAt(File, string, File, string) error {
+	return ErrReadOnly
+}
+func (b *FSBackend) LinkAt(File, string, bool, File, string) error {
+	return ErrReadOnly
+}
+func (b *FSBackend) SymlinkAt(string, File, string) error { return ErrReadOnly }
+func (b *FSBackend) ChtimesAt(File, string, bool, time.Time, time.Time) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,651 @@
+package filesystem
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMemoryFSSize 是 MemoryFS 默认允许保存的文件数据总量。
+const DefaultMemoryFSSize = 1 << 30
+
+// MemoryFS 是一个完全位于内存中的可写后端，适合为每次运行提供一次性的临时目录。
+// MemoryFS 同时实现了 fs.FS，Host 可以在 Guest 运行结束后读取其中的内容。
+type MemoryFS struct {
+	mu   sync.RWMutex
+	root *memNode
+
+	// maxSize 限制所有文件数据的总量，maxFileSize 限制单个文件的大小。
+	maxSize, maxFileSize int64
+	// used 是仍有目录项指向的文件占用的字节数。
+	used int64
+}
+
+// MemoryFSOption 是用于配置 MemoryFS 的选项函数。
+type MemoryFSOption func(*MemoryFS)
+
+// WithMaxSize 限制 MemoryFS 中所有文件数据的总量，超过时写入返回 ErrNoSpace。
+// 已被删除但仍然打开的文件不计入总量，只受单个文件大小的限制。
+func WithMaxSize(size int64) MemoryFSOption {
+	return func(m *MemoryFS) {
+		m.maxSize = size
+	}
+}
+
+// WithMaxFileSize 限制 MemoryFS 中单个文件的大小，超过时写入返回 ErrFileTooLarge。
+func WithMaxFileSize(size int64) MemoryFSOption {
+	return func(m *MemoryFS) {
+		m.maxFileSize = size
+	}
+}
+
+// NewMemoryFS 创建一个空的内存文件系统。
+// 默认最多保存 DefaultMemoryFSSize 字节的数据，单个文件的大小默认只受总量的限制。
+func NewMemoryFS(opts ...MemoryFSOption) *MemoryFS {
+	m := &MemoryFS{root: newMemNode(fs.ModeDir | 0755), maxSize: DefaultMemoryFSSize}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.maxFileSize <= 0 || m.maxFileSize > m.maxSize {
+		m.maxFileSize = m.maxSize
+	}
+	return m
+}
+
+// resize 将 node 的数据调整为 size 字节，调用方需要持有写锁。
+// 增长之前检查大小限制，Guest 给出的大小不可信，超过限制时不会分配任何内存。
+func (m *MemoryFS) resize(node *memNode, size int64) error {
+	if size < 0 {
+		return fs.ErrInvalid
+	}
+	if size > m.maxFileSize {
+		return ErrFileTooLarge
+	}
+	delta := size - int64(len(node.data))
+	if node.nlink > 0 && delta > 0 && m.used+delta > m.maxSize {
+		return ErrNoSpace
+	}
+	switch {
+	case delta > 0:
+		node.data = append(node.data, make([]byte, delta)...)
+	case int64(cap(node.data)) > 2*size:
+		// 大幅缩小时重新分配，释放多余的容量
+		node.data = append([]byte(nil), node.data[:size]...)
+	default:
+		node.data = node.data[:size]
+	}
+	if node.nlink > 0 {
+		m.used += delta
+	}
+	return nil
+}
+
+// unlink 减少 node 的链接数，最后一个目录项被删除时归还它占用的空间，调用方需要持有写锁。
+func (m *MemoryFS) unlink(node *memNode) {
+	node.nlink--
+	if node.nlink == 0 {
+		m.used -= int64(len(node.data))
+	}
+}
+
+// memNode 是内存文件系统中的一个 inode，硬链接会共享同一个节点。
+type memNode struct {
+	mode     fs.FileMode
+	modTime  time.Time
+	atime    time.Time
+	data     []byte
+	children map[string]*memNode
+	target   string
+	nlink    int
+}
+
+func newMemNode(mode fs.FileMode) *memNode {
+	now := time.Now()
+	n := &memNode{mode: mode, modTime: now, atime: now, nlink: 1}
+	if mode.IsDir() {
+		n.children = make(map[string]*memNode)
+	}
+	return n
+}
+
+func (n *memNode) isSymlink() bool {
+	return n.mode&fs.ModeSymlink != 0
+}
+
+func (n *memNode) info(name string) *memFileInfo {
+	size := int64(len(n.data))
+	if n.isSymlink() {
+		size = int64(len(n.target))
+	}
+	return &memFileInfo{name: name, size: size, mode: n.mode, modTime: n.modTime}
+}
+
+// memFileInfo 是 memNode 在某一时刻的快照。
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return nil }
+
+// memFile 是 MemoryFS 打开的文件，同时实现了 fs.File 和 fs.ReadDirFile。
+type memFile struct {
+	fs   *MemoryFS
+	node *memNode
+	name string
+	flag int
+
+	mu sync.Mutex
+	// offset 和 entries 仅用于 fs.File 的顺序读取。
+	offset  int64
+	entries []fs.DirEntry
+}
+
+func (f *memFile) readable() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != os.O_WRONLY
+}
+
+func (f *memFile) writable() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+func (f *memFile) Close() error { return nil }
+func (f *memFile) Sync() error  { return nil }
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.node.info(f.name), nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if !f.readable() {
+		return 0, ErrBadDescriptor
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.node.mode.IsDir() {
+		return 0, ErrIsDirectory
+	}
+	if off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	f.node.atime = time.Now()
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	if !f.writable() {
+		return 0, ErrBadDescriptor
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.node.mode.IsDir() {
+		return 0, ErrIsDirectory
+	}
+	if off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	end := off + int64(len(p))
+	if end < off {
+		return 0, ErrFileTooLarge
+	}
+	if end > int64(len(f.node.data)) {
+		if err := f.fs.resize(f.node, end); err != nil {
+			return 0, err
+		}
+	}
+	copy(f.node.data[off:], p)
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	if !f.writable() {
+		return ErrBadDescriptor
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.node.mode.IsDir() {
+		return ErrIsDirectory
+	}
+	if err := f.fs.resize(f.node, size); err != nil {
+		return err
+	}
+	f.node.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Chtimes(atime, mtime time.Time) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.node.atime, f.node.modTime = atime, mtime
+	return nil
+}
+
+func (f *memFile) ReadDirectory() ([]fs.DirEntry, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if !f.node.mode.IsDir() {
+		return nil, ErrNotDirectory
+	}
+	names := make([]string, 0, len(f.node.children))
+	for name := range f.node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, fs.FileInfoToDirEntry(f.node.children[name].info(name)))
+	}
+	return entries, nil
+}
+
+// Read 实现 fs.File，供 Host 通过 fs.FS 接口读取内容。
+func (f *memFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadDir 实现 fs.ReadDirFile。
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.entries == nil {
+		entries, err := f.ReadDirectory()
+		if err != nil {
+			return nil, err
+		}
+		f.entries = entries
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = f.entries[len(f.entries):]
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+// Open 实现 fs.FS。路径上的符号链接会被跟随，但不能离开根目录。
+func (m *MemoryFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, _, node, err := m.resolve(m.root, name, true)
+	if err == nil && node == nil {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &memFile{fs: m, node: node, name: path.Base(name), flag: os.O_RDONLY}, nil
+}
+
+func (m *MemoryFS) Root() (File, error) {
+	return &memFile{fs: m, node: m.root, name: ".", flag: os.O_RDONLY}, nil
+}
+
+// dirNode 取出 MemoryFS 打开的目录对应的节点。
+func (m *MemoryFS) dirNode(dir File) (*memNode, error) {
+	f, ok := dir.(*memFile)
+	if !ok || f.fs != m {
+		return nil, ErrCrossDevice
+	}
+	return f.node, nil
+}
+
+// resolve 从 dir 开始逐级解析 path，调用方需要持有锁。
+// 返回最后一个分量所在的目录、最后一个分量的名字和它对应的节点。
+// 最后一个分量不存在时 node 为 nil；路径以 "." 或 ".." 结束时 parent 为 nil。
+func (m *MemoryFS) resolve(dir *memNode, p string, follow bool) (parent *memNode, name string, node *memNode, err error) {
+	if err := checkGuestPath(p); err != nil {
+		return nil, "", nil, err
+	}
+	stack := []*memNode{dir}
+	pending := guestPathComponents(p)
+	links := 0
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+
+		switch c {
+		case ".":
+			continue
+		case "..":
+			if len(stack) == 1 {
+				return nil, "", nil, ErrNotPermitted
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		current := stack[len(stack)-1]
+		if !current.mode.IsDir() {
+			return nil, "", nil, ErrNotDirectory
+		}
+		child := current.children[c]
+		last := len(pending) == 0
+		if child != nil && child.isSymlink() && (!last || follow) {
+			links++
+			if links > maxSymlinks {
+				return nil, "", nil, ErrLoop
+			}
+			if child.target == "" || isAbsGuestPath(child.target) {
+				return nil, "", nil, ErrNotPermitted
+			}
+			// 链接目标相对于链接所在目录，继续与剩余分量一起解析。
+			pending = append(guestPathComponents(child.target), pending...)
+			continue
+		}
+		if last {
+			return current, c, child, nil
+		}
+		if child == nil {
+			return nil, "", nil, fs.ErrNotExist
+		}
+		stack = append(stack, child)
+	}
+	return nil, "", stack[len(stack)-1], nil
+}
+
+// resolveEntry 解析一个将被创建、删除或重命名的目录项。
+func (m *MemoryFS) resolveEntry(dir File, p string) (*memNode, string, *memNode, error) {
+	d, err := m.dirNode(dir)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if _, _, err := guestParent(p); err != nil {
+		return nil, "", nil, err
+	}
+	parent, name, node, err := m.resolve(d, p, false)
+	if err == nil && !parent.mode.IsDir() {
+		err = ErrNotDirectory
+	}
+	return parent, name, node, err
+}
+
+func (m *MemoryFS) OpenAt(dir File, p string, follow bool, flag int, perm fs.FileMode) (File, error) {
+	d, err := m.dirNode(dir)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parent, name, node, err := m.resolve(d, p, follow)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case node == nil:
+		if flag&os.O_CREATE == 0 {
+			return nil, fs.ErrNotExist
+		}
+		if parent == nil || !parent.mode.IsDir() {
+			return nil, ErrNotDirectory
+		}
+		node = newMemNode(perm.Perm())
+		parent.children[name] = node
+		parent.modTime = node.modTime
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, fs.ErrExist
+	case node.isSymlink():
+		// 与 O_NOFOLLOW 相同。
+		return nil, ErrLoop
+	case node.mode.IsDir():
+		if isWriteFlag(flag) {
+			return nil, ErrIsDirectory
+		}
+	case flag&os.O_TRUNC != 0:
+		m.resize(node, 0)
+		node.data = nil
+		node.modTime = time.Now()
+	}
+	if name == "" {
+		name = "."
+	}
+	return &memFile{fs: m, node: node, name: name, flag: flag}, nil
+}
+
+func (m *MemoryFS) StatAt(dir File, p string, follow bool) (fs.FileInfo, error) {
+	d, err := m.dirNode(dir)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, name, node, err := m.resolve(d, p, follow)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fs.ErrNotExist
+	}
+	return node.info(name), nil
+}
+
+func (m *MemoryFS) MkdirAt(dir File, p string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, name, node, err := m.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	if node != nil {
+		return fs.ErrExist
+	}
+	node = newMemNode(fs.ModeDir | perm.Perm())
+	parent.children[name] = node
+	parent.modTime = node.modTime
+	return nil
+}
+
+func (m *MemoryFS) ReadlinkAt(dir File, p string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, _, node, err := m.resolveEntry(dir, p)
+	if err != nil {
+		return "", err
+	}
+	if node == nil {
+		return "", fs.ErrNotExist
+	}
+	if !node.isSymlink() {
+		return "", fs.ErrInvalid
+	}
+	return node.target, nil
+}
+
+func (m *MemoryFS) RmdirAt(dir File, p string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, name, node, err := m.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	switch {
+	case node == nil:
+		return fs.ErrNotExist
+	case !node.mode.IsDir():
+		return ErrNotDirectory
+	case len(node.children) > 0:
+		return ErrNotEmpty
+	}
+	delete(parent.children, name)
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (m *MemoryFS) UnlinkAt(dir File, p string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, name, node, err := m.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	switch {
+	case node == nil:
+		return fs.ErrNotExist
+	case node.mode.IsDir():
+		return ErrIsDirectory
+	}
+	delete(parent.children, name)
+	m.unlink(node)
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (m *MemoryFS) RenameAt(oldDir File, oldPath string, newDir File, newPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldParent, oldName, node, err := m.resolveEntry(oldDir, oldPath)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fs.ErrNotExist
+	}
+	newParent, newName, existing, err := m.resolveEntry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	if existing == node {
+		return nil
+	}
+	if existing != nil {
+		switch {
+		case node.mode.IsDir() && !existing.mode.IsDir():
+			return ErrNotDirectory
+		case !node.mode.IsDir() && existing.mode.IsDir():
+			return ErrIsDirectory
+		case existing.mode.IsDir() && len(existing.children) > 0:
+			return ErrNotEmpty
+		}
+	}
+	// 目录不能被移动到它自己的子树中。
+	if node.mode.IsDir() && containsNode(node, newParent) {
+		return fs.ErrInvalid
+	}
+
+	delete(oldParent.children, oldName)
+	if existing != nil {
+		m.unlink(existing)
+	}
+	newParent.children[newName] = node
+	now := time.Now()
+	oldParent.modTime, newParent.modTime = now, now
+	return nil
+}
+
+func (m *MemoryFS) LinkAt(oldDir File, oldPath string, follow bool, newDir File, newPath string) error {
+	d, err := m.dirNode(oldDir)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, node, err := m.resolve(d, oldPath, follow)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fs.ErrNotExist
+	}
+	if node.mode.IsDir() {
+		return ErrNotPermitted
+	}
+	parent, name, existing, err := m.resolveEntry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fs.ErrExist
+	}
+	parent.children[name] = node
+	node.nlink++
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (m *MemoryFS) SymlinkAt(target string, dir File, p string) error {
+	if err := checkSymlinkTarget(target); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, name, node, err := m.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	if node != nil {
+		return fs.ErrExist
+	}
+	node = newMemNode(fs.ModeSymlink | 0777)
+	node.target = target
+	parent.children[name] = node
+	parent.modTime = node.modTime
+	return nil
+}
+
+func (m *MemoryFS) ChtimesAt(dir File, p string, follow bool, atime, mtime time.Time) error {
+	d, err := m.dirNode(dir)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, node, err := m.resolve(d, p, follow)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fs.ErrNotExist
+	}
+	node.atime, node.modTime = atime, mtime
+	return nil
+}
+
+// containsNode 判断 target 是否位于以 dir 为根的子树中（包括 dir 本身）。
+func containsNode(dir, target *memNode) bool {
+	if dir == target {
+		return true
+	}
+	for _, child := range dir.children {
+		if child.mode.IsDir() && containsNode(child, target) {
+			return true
+		}
+	}
+	return false
+}
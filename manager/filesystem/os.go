@@ -0,0 +1,198 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// OSBackend 将 Host 上的一个目录作为后端。
+// 所有 *-at 操作都在内核（openat2）中解析，或相对于已打开的目录逐级解析，Guest 无法离开该目录。
+// 无法做到这一点的平台上 Root 返回 errors.ErrUnsupported。
+type OSBackend struct {
+	root    string
+	sandbox sandbox
+	// temp 为 true 时，Close 会删除整个目录。
+	temp bool
+}
+
+// NewOSBackend 创建以 Host 目录 root 为根的后端。
+func NewOSBackend(root string) *OSBackend {
+	return &OSBackend{root: root, sandbox: newSandbox()}
+}
+
+// NewTempDirBackend 在 dir 下创建一个临时目录作为后端，参数与 os.MkdirTemp 相同。
+// 调用 Close 会删除该目录，适合用作 OverlayFS 的上层。
+func NewTempDirBackend(dir, pattern string) (*OSBackend, error) {
+	root, err := os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	b := NewOSBackend(root)
+	b.temp = true
+	return b, nil
+}
+
+// Dir 返回后端在 Host 上的根目录。
+func (b *OSBackend) Dir() string {
+	return b.root
+}
+
+// Close 删除由 NewTempDirBackend 创建的临时目录，对其他后端没有作用。
+func (b *OSBackend) Close() error {
+	if !b.temp {
+		return nil
+	}
+	return os.RemoveAll(b.root)
+}
+
+// OSFile 是 OSBackend 打开的文件。
+// 目录读取和时间修改都作用在已打开的描述符上，不经过 Name 返回的路径，
+// 文件被重命名后 Name 可能已经指向其他位置。
+type OSFile struct {
+	*os.File
+	// backend 是打开该文件的后端，用于拒绝其他后端的目录。
+	backend *OSBackend
+	// dirMutex 保证读取目录时的 Seek 和读取不会交错。
+	dirMutex sync.Mutex
+}
+
+func (f *OSFile) ReadDirectory() ([]fs.DirEntry, error) {
+	f.dirMutex.Lock()
+	defer f.dirMutex.Unlock()
+	return readDirectory(f.File)
+}
+
+func (f *OSFile) Chtimes(atime, mtime time.Time) error {
+	return chtimes(f.File, atime, mtime)
+}
+
+func (b *OSBackend) Root() (File, error) {
+	if b.sandbox == nil {
+		return nil, errors.ErrUnsupported
+	}
+	f, err := os.Open(b.root)
+	if err != nil {
+		return nil, err
+	}
+	return &OSFile{File: f, backend: b}, nil
+}
+
+func (b *OSBackend) OpenAt(dir File, path string, follow bool, flag int, perm fs.FileMode) (File, error) {
+	d, err := b.dir(dir)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.sandbox.Open(d, path, follow, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &OSFile{File: f, backend: b}, nil
+}
+
+func (b *OSBackend) StatAt(dir File, path string, follow bool) (fs.FileInfo, error) {
+	d, err := b.dir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return b.sandbox.Stat(d, path, follow)
+}
+
+func (b *OSBackend) MkdirAt(dir File, path string, perm fs.FileMode) error {
+	d, err := b.dir(dir)
+	if err != nil {
+		return err
+	}
+	return b.sandbox.Mkdir(d, path, perm)
+}
+
+func (b *OSBackend) ReadlinkAt(dir File, path string) (string, error) {
+	d, err := b.dir(dir)
+	if err != nil {
+		return "", err
+	}
+	return b.sandbox.Readlink(d, path)
+}
+
+func (b *OSBackend) RmdirAt(dir File, path string) error {
+	d, err := b.dir(dir)
+	if err != nil {
+		return err
+	}
+	return b.sandbox.Rmdir(d, path)
+}
+
+func (b *OSBackend) UnlinkAt(dir File, path string) error {
+	d, err := b.dir(dir)
+	if err != nil {
+		return err
+	}
+	return b.sandbox.Unlink(d, path)
+}
+
+func (b *OSBackend) RenameAt(oldDir File, oldPath string, newDir File, newPath string) error {
+	oldD, err := b.dir(oldDir)
+	if err != nil {
+		return err
+	}
+	newD, err := b.dir(newDir)
+	if err != nil {
+		return err
+	}
+	return b.sandbox.Rename(oldD, oldPath, newD, newPath)
+}
+
+func (b *OSBackend) LinkAt(oldDir File, oldPath string, follow bool, newDir File, newPath string) error {
+	oldD, err := b.dir(oldDir)
+	if err != nil {
+		return err
+	}
+	newD, err := b.dir(newDir)
+	if err != nil {
+		return err
+	}
+	return b.sandbox.Link(oldD, oldPath, follow, newD, newPath)
+}
+
+func (b *OSBackend) SymlinkAt(target string, dir File, path string) error {
+	d, err := b.dir(dir)
+	if err != nil {
+		return err
+	}
+	return b.sandbox.Symlink(target, d, path)
+}
+
+func (b *OSBackend) ChtimesAt(dir File, path string, follow bool, atime, mtime time.Time) error {
+	d, err := b.dir(dir)
+	if err != nil {
+		return err
+	}
+	return b.sandbox.Chtimes(d, path, follow, atime, mtime)
+}
+
+// dir 取出 OSBackend 打开的目录对应的 *os.File。
+// 其他 OSBackend 打开的目录即使位于同一个挂载点也返回 ErrCrossDevice。
+func (b *OSBackend) dir(dir File) (*os.File, error) {
+	f, ok := dir.(*OSFile)
+	if !ok || f.backend != b {
+		return nil, ErrCrossDevice
+	}
+	return f.File, nil
+}
+
+// sandbox 负责在 Host 文件系统上执行基于路径的操作。
+// 所有路径都相对于基准目录解析，并且保证解析结果不会离开该目录。
+type sandbox interface {
+	Open(dir *os.File, path string, follow bool, flag int, perm os.FileMode) (*os.File, error)
+	Stat(dir *os.File, path string, follow bool) (fs.FileInfo, error)
+	Mkdir(dir *os.File, path string, perm os.FileMode) error
+	Readlink(dir *os.File, path string) (string, error)
+	Rmdir(dir *os.File, path string) error
+	Unlink(dir *os.File, path string) error
+	Rename(oldDir *os.File, oldPath string, newDir *os.File, newPath string) error
+	Link(oldDir *os.File, oldPath string, follow bool, newDir *os.File, newPath string) error
+	Symlink(target string, dir *os.File, path string) error
+	Chtimes(dir *os.File, path string, follow bool, atime, mtime time.Time) error
+}
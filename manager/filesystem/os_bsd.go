@@ -0,0 +1,24 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package filesystem
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// newSandbox 在没有 openat2 的系统上逐级 openat 解析路径。
+func newSandbox() sandbox {
+	return walkSandbox{}
+}
+
+// chtimes 修改 f 的访问和修改时间。
+func chtimes(f *os.File, atime, mtime time.Time) error {
+	tv := []unix.Timeval{
+		unix.NsecToTimeval(atime.UnixNano()),
+		unix.NsecToTimeval(mtime.UnixNano()),
+	}
+	return wrapPathError("futimes", f.Name(), unix.Futimes(int(f.Fd()), tv))
+}
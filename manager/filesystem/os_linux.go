@@ -0,0 +1,244 @@
+//go:build linux
+
+package filesystem
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// newSandbox 优先使用 openat2(RESOLVE_BENEATH) 在内核中完成路径解析。
+// 内核不支持（低于 5.6）或被 seccomp 拦截时，退回到逐级 openat 的解析。
+var newSandbox = sync.OnceValue(func() sandbox {
+	fd, err := unix.Openat2(unix.AT_FDCWD, ".", &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH,
+	})
+	if err != nil {
+		return walkSandbox{}
+	}
+	unix.Close(fd)
+	return openat2Sandbox{}
+})
+
+// openat2Sandbox 借助 openat2 的 RESOLVE_BENEATH 保证解析过程不会离开基准目录，
+// 然后在解析得到的父目录上使用 *at 系统调用完成操作，避免了用户态解析的竞争问题。
+type openat2Sandbox struct{}
+
+// open 在 dir 之下打开 path。
+func (openat2Sandbox) open(dir *os.File, path string, flags, mode uint64) (int, error) {
+	if err := checkGuestPath(path); err != nil {
+		return -1, err
+	}
+	how := &unix.OpenHow{
+		Flags:   flags | unix.O_CLOEXEC,
+		Mode:    mode,
+		Resolve: unix.RESOLVE_BENEATH,
+	}
+	for {
+		fd, err := unix.Openat2(int(dir.Fd()), path, how)
+		switch err {
+		case nil:
+			return fd, nil
+		case unix.EINTR, unix.EAGAIN:
+			// 解析期间发生了并发的重命名，内核要求重试。
+			continue
+		case unix.EXDEV:
+			return -1, ErrNotPermitted
+		default:
+			return -1, &fs.PathError{Op: "openat2", Path: path, Err: err}
+		}
+	}
+}
+
+// parent 打开 path 的父目录，返回父目录 fd 和最后一个分量。
+// 调用方使用完毕后需要调用返回的 release。
+func (s openat2Sandbox) parent(dir *os.File, path string) (int, string, func(), error) {
+	parentPath, base, err := guestParent(path)
+	if err != nil {
+		return -1, "", nil, err
+	}
+	if parentPath == "" {
+		return int(dir.Fd()), base, func() {}, nil
+	}
+	fd, err := s.open(dir, parentPath, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return -1, "", nil, err
+	}
+	return fd, base, func() { unix.Close(fd) }, nil
+}
+
+func (s openat2Sandbox) Open(dir *os.File, path string, follow bool, flag int, perm os.FileMode) (*os.File, error) {
+	flags := uint64(flag)
+	if !follow {
+		flags |= unix.O_NOFOLLOW
+	}
+	var mode uint64
+	if flag&os.O_CREATE != 0 {
+		mode = uint64(perm.Perm())
+	}
+	fd, err := s.open(dir, path, flags, mode)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), fdPath(fd, filepath.Join(dir.Name(), path))), nil
+}
+
+func (s openat2Sandbox) Stat(dir *os.File, path string, follow bool) (fs.FileInfo, error) {
+	flags := uint64(unix.O_PATH)
+	if !follow {
+		flags |= unix.O_NOFOLLOW
+	}
+	fd, err := s.open(dir, path, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), filepath.Join(dir.Name(), path))
+	defer f.Close()
+	return f.Stat()
+}
+
+func (s openat2Sandbox) Mkdir(dir *os.File, path string, perm os.FileMode) error {
+	fd, base, release, err := s.parent(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("mkdirat", path, unix.Mkdirat(fd, base, uint32(perm.Perm())))
+}
+
+func (s openat2Sandbox) Readlink(dir *os.File, path string) (string, error) {
+	fd, base, release, err := s.parent(dir, path)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	target, err := readlinkAt(fd, base)
+	return target, wrapPathError("readlinkat", path, err)
+}
+
+func (s openat2Sandbox) Rmdir(dir *os.File, path string) error {
+	fd, base, release, err := s.parent(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("unlinkat", path, unix.Unlinkat(fd, base, unix.AT_REMOVEDIR))
+}
+
+func (s openat2Sandbox) Unlink(dir *os.File, path string) error {
+	fd, base, release, err := s.parent(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("unlinkat", path, unix.Unlinkat(fd, base, 0))
+}
+
+func (s openat2Sandbox) Rename(oldDir *os.File, oldPath string, newDir *os.File, newPath string) error {
+	oldFd, oldBase, releaseOld, err := s.parent(oldDir, oldPath)
+	if err != nil {
+		return err
+	}
+	defer releaseOld()
+	newFd, newBase, releaseNew, err := s.parent(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	defer releaseNew()
+	return wrapPathError("renameat", oldPath, unix.Renameat(oldFd, oldBase, newFd, newBase))
+}
+
+func (s openat2Sandbox) Link(oldDir *os.File, oldPath string, follow bool, newDir *os.File, newPath string) error {
+	newFd, newBase, releaseNew, err := s.parent(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	defer releaseNew()
+
+	if !follow {
+		oldFd, oldBase, releaseOld, err := s.parent(oldDir, oldPath)
+		if err != nil {
+			return err
+		}
+		defer releaseOld()
+		return wrapPathError("linkat", oldPath, unix.Linkat(oldFd, oldBase, newFd, newBase, 0))
+	}
+
+	// 直接让 linkat 跟随链接会绕过 RESOLVE_BENEATH，
+	// 因此先在沙盒内解析出目标文件，再通过 /proc 中的 fd 链接它。
+	fd, err := s.open(oldDir, oldPath, unix.O_PATH, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return wrapPathError("linkat", oldPath, unix.Linkat(unix.AT_FDCWD, procFdPath(fd), newFd, newBase, unix.AT_SYMLINK_FOLLOW))
+}
+
+func (s openat2Sandbox) Symlink(target string, dir *os.File, path string) error {
+	if err := checkSymlinkTarget(target); err != nil {
+		return err
+	}
+	fd, base, release, err := s.parent(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("symlinkat", path, unix.Symlinkat(target, fd, base))
+}
+
+func (s openat2Sandbox) Chtimes(dir *os.File, path string, follow bool, atime, mtime time.Time) error {
+	ts := []unix.Timespec{
+		unix.NsecToTimespec(atime.UnixNano()),
+		unix.NsecToTimespec(mtime.UnixNano()),
+	}
+	if !follow {
+		if fd, base, release, err := s.parent(dir, path); err == nil {
+			defer release()
+			return wrapPathError("utimensat", path, unix.UtimesNanoAt(fd, base, ts, unix.AT_SYMLINK_NOFOLLOW))
+		}
+		// "." 和 ".." 不可能是符号链接，与跟随时的处理相同。
+	}
+
+	fd, err := s.open(dir, path, unix.O_PATH, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return wrapPathError("utimensat", path, unix.UtimesNano(procFdPath(fd), ts))
+}
+
+// procFdPath 返回 fd 在 /proc 中对应的路径，用于对 O_PATH 打开的文件执行操作。
+func procFdPath(fd int) string {
+	return "/proc/self/fd/" + strconv.Itoa(fd)
+}
+
+// fdPath 返回 fd 实际指向的宿主路径，获取失败时返回 fallback。
+// 路径中可能经过了符号链接或 ".."，词法拼接得到的路径并不可靠。
+func fdPath(fd int, fallback string) string {
+	if p, err := os.Readlink(procFdPath(fd)); err == nil && filepath.IsAbs(p) {
+		return p
+	}
+	return fallback
+}
+
+// chtimes 修改 f 的访问和修改时间。
+func chtimes(f *os.File, atime, mtime time.Time) error {
+	ts := []unix.Timespec{
+		unix.NsecToTimespec(atime.UnixNano()),
+		unix.NsecToTimespec(mtime.UnixNano()),
+	}
+	fd := int(f.Fd())
+	err := unix.UtimesNanoAt(fd, "", ts, unix.AT_EMPTY_PATH)
+	if err == unix.EINVAL || err == unix.ENOENT {
+		// 较旧的内核不支持 utimensat 的 AT_EMPTY_PATH。
+		err = unix.UtimesNano(procFdPath(fd), ts)
+	}
+	return wrapPathError("utimensat", f.Name(), err)
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !windows
+
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// newSandbox 在无法相对于目录描述符解析路径的平台上返回 nil，OSBackend 不可用。
+func newSandbox() sandbox {
+	return nil
+}
+
+func readDirectory(*os.File) ([]fs.DirEntry, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func chtimes(*os.File, time.Time, time.Time) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,321 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package filesystem
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// walkSandbox 使用 openat 逐级打开路径上的每个目录，用于没有 openat2 的系统。
+// 每一步都相对于上一级目录的 fd 进行并且不跟随符号链接，符号链接在用户态读取后继续解析，
+// 因此目录被重命名或被替换为符号链接也不会让解析离开基准目录。
+type walkSandbox struct{}
+
+// walk 解析 path，返回最后一个分量所在目录的 fd 和最后一个分量的名字，path 指向目录本身时名字为 "."。
+// 中间分量上的符号链接总是会被跟随，最后一个分量仅在 follow 为 true 时跟随，
+// 调用方对返回的名字执行操作时不能再跟随符号链接。使用完毕后需要调用返回的 release。
+func (walkSandbox) walk(dir *os.File, path string, follow bool) (int, string, func(), error) {
+	if err := checkGuestPath(path); err != nil {
+		return -1, "", nil, err
+	}
+	stack := []int{int(dir.Fd())}
+	release := func() {
+		for _, fd := range stack[1:] {
+			unix.Close(fd)
+		}
+	}
+	fail := func(err error) (int, string, func(), error) {
+		release()
+		return -1, "", nil, err
+	}
+
+	pending := guestPathComponents(path)
+	links := 0
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+
+		switch c {
+		case ".":
+			continue
+		case "..":
+			if len(stack) == 1 {
+				return fail(ErrNotPermitted)
+			}
+			unix.Close(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		top := stack[len(stack)-1]
+		last := len(pending) == 0
+		if last && !follow {
+			return top, c, release, nil
+		}
+		var st unix.Stat_t
+		if err := unix.Fstatat(top, c, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			if last && err == unix.ENOENT {
+				// 不存在的最后一个分量交给实际操作去创建或报错。
+				return top, c, release, nil
+			}
+			return fail(wrapPathError("fstatat", path, err))
+		}
+		if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+			links++
+			if links > maxSymlinks {
+				return fail(ErrLoop)
+			}
+			target, err := readlinkAt(top, c)
+			if err != nil {
+				return fail(wrapPathError("readlinkat", path, err))
+			}
+			if target == "" || isAbsGuestPath(target) {
+				return fail(ErrNotPermitted)
+			}
+			// 链接目标相对于链接所在目录，继续与剩余分量一起解析。
+			pending = append(guestPathComponents(target), pending...)
+			continue
+		}
+		if last {
+			return top, c, release, nil
+		}
+		fd, err := unix.Openat(top, c, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fail(wrapPathError("openat", path, err))
+		}
+		stack = append(stack, fd)
+	}
+	return stack[len(stack)-1], ".", release, nil
+}
+
+// entry 解析一个将被创建、删除或重命名的目录项，最后一个分量不会被跟随。
+func (s walkSandbox) entry(dir *os.File, path string) (int, string, func(), error) {
+	if _, _, err := guestParent(path); err != nil {
+		return -1, "", nil, err
+	}
+	return s.walk(dir, path, false)
+}
+
+func (s walkSandbox) Open(dir *os.File, path string, follow bool, flag int, perm os.FileMode) (*os.File, error) {
+	fd, base, release, err := s.walk(dir, path, follow)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	// 需要跟随的链接已经在 walk 中处理过，最后一个分量在这期间被换成链接时拒绝打开。
+	nfd, err := unix.Openat(fd, base, flag|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(perm.Perm()))
+	if err != nil {
+		return nil, wrapPathError("openat", path, err)
+	}
+	return os.NewFile(uintptr(nfd), filepath.Join(dir.Name(), path)), nil
+}
+
+func (s walkSandbox) Stat(dir *os.File, path string, follow bool) (fs.FileInfo, error) {
+	fd, base, release, err := s.walk(dir, path, follow)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return statAt(fd, base, path)
+}
+
+func (s walkSandbox) Mkdir(dir *os.File, path string, perm os.FileMode) error {
+	fd, base, release, err := s.entry(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("mkdirat", path, unix.Mkdirat(fd, base, uint32(perm.Perm())))
+}
+
+func (s walkSandbox) Readlink(dir *os.File, path string) (string, error) {
+	fd, base, release, err := s.entry(dir, path)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	target, err := readlinkAt(fd, base)
+	return target, wrapPathError("readlinkat", path, err)
+}
+
+func (s walkSandbox) Rmdir(dir *os.File, path string) error {
+	fd, base, release, err := s.entry(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("unlinkat", path, unix.Unlinkat(fd, base, unix.AT_REMOVEDIR))
+}
+
+func (s walkSandbox) Unlink(dir *os.File, path string) error {
+	fd, base, release, err := s.entry(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("unlinkat", path, unix.Unlinkat(fd, base, 0))
+}
+
+func (s walkSandbox) Rename(oldDir *os.File, oldPath string, newDir *os.File, newPath string) error {
+	oldFd, oldBase, releaseOld, err := s.entry(oldDir, oldPath)
+	if err != nil {
+		return err
+	}
+	defer releaseOld()
+	newFd, newBase, releaseNew, err := s.entry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	defer releaseNew()
+	return wrapPathError("renameat", oldPath, unix.Renameat(oldFd, oldBase, newFd, newBase))
+}
+
+func (s walkSandbox) Link(oldDir *os.File, oldPath string, follow bool, newDir *os.File, newPath string) error {
+	oldFd, oldBase, releaseOld, err := s.walk(oldDir, oldPath, follow)
+	if err != nil {
+		return err
+	}
+	defer releaseOld()
+	newFd, newBase, releaseNew, err := s.entry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	defer releaseNew()
+	// linkat 不带 AT_SYMLINK_FOLLOW 时不跟随链接，需要跟随的链接已经在 walk 中处理过。
+	return wrapPathError("linkat", oldPath, unix.Linkat(oldFd, oldBase, newFd, newBase, 0))
+}
+
+func (s walkSandbox) Symlink(target string, dir *os.File, path string) error {
+	if err := checkSymlinkTarget(target); err != nil {
+		return err
+	}
+	fd, base, release, err := s.entry(dir, path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return wrapPathError("symlinkat", path, unix.Symlinkat(target, fd, base))
+}
+
+func (s walkSandbox) Chtimes(dir *os.File, path string, follow bool, atime, mtime time.Time) error {
+	fd, base, release, err := s.walk(dir, path, follow)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ts := []unix.Timespec{
+		unix.NsecToTimespec(atime.UnixNano()),
+		unix.NsecToTimespec(mtime.UnixNano()),
+	}
+	return wrapPathError("utimensat", path, unix.UtimesNanoAt(fd, base, ts, unix.AT_SYMLINK_NOFOLLOW))
+}
+
+// readlinkAt 读取目录 fd 中名为 name 的符号链接。
+func readlinkAt(fd int, name string) (string, error) {
+	for size := 256; ; size *= 2 {
+		buf := make([]byte, size)
+		n, err := unix.Readlinkat(fd, name, buf)
+		if err != nil {
+			return "", err
+		}
+		if n < size {
+			return string(buf[:n]), nil
+		}
+	}
+}
+
+// readDirectory 读取 f 中的全部条目。条目的信息通过 fstatat 相对于 f 获取，
+// 不经过 f.Name()，目录被重命名后也不会读到其他位置的内容。
+func readDirectory(f *os.File) ([]fs.DirEntry, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(f.Fd())
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		info, err := statAt(fd, name, name)
+		if os.IsNotExist(err) {
+			// 条目在读取期间被删除。
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+// syscall.Stat_t 和 unix.Stat_t 来自同一个 C 结构体，大小不同时无法编译。
+var _ [unsafe.Sizeof(syscall.Stat_t{}) - unsafe.Sizeof(unix.Stat_t{})]struct{} = [unsafe.Sizeof(unix.Stat_t{}) - unsafe.Sizeof(syscall.Stat_t{})]struct{}{}
+
+// statAt 不跟随符号链接地获取目录 fd 中 name 的信息，Sys 与 os.Lstat 一样返回 *syscall.Stat_t。
+func statAt(fd int, name, path string) (fs.FileInfo, error) {
+	info := &statInfo{name: filepath.Base(name)}
+	st := (*unix.Stat_t)(unsafe.Pointer(&info.sys))
+	if err := unix.Fstatat(fd, name, st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return nil, wrapPathError("fstatat", path, err)
+	}
+	info.size = st.Size
+	info.modTime = time.Unix(st.Mtim.Unix())
+	info.mode = fs.FileMode(st.Mode & 0777)
+	switch uint32(st.Mode) & unix.S_IFMT {
+	case unix.S_IFBLK:
+		info.mode |= fs.ModeDevice
+	case unix.S_IFCHR:
+		info.mode |= fs.ModeDevice | fs.ModeCharDevice
+	case unix.S_IFDIR:
+		info.mode |= fs.ModeDir
+	case unix.S_IFIFO:
+		info.mode |= fs.ModeNamedPipe
+	case unix.S_IFLNK:
+		info.mode |= fs.ModeSymlink
+	case unix.S_IFSOCK:
+		info.mode |= fs.ModeSocket
+	}
+	if st.Mode&unix.S_ISGID != 0 {
+		info.mode |= fs.ModeSetgid
+	}
+	if st.Mode&unix.S_ISUID != 0 {
+		info.mode |= fs.ModeSetuid
+	}
+	if st.Mode&unix.S_ISVTX != 0 {
+		info.mode |= fs.ModeSticky
+	}
+	return info, nil
+}
+
+// statInfo 是 statAt 得到的文件信息。
+type statInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	sys     syscall.Stat_t
+}
+
+func (i *statInfo) Name() string       { return i.name }
+func (i *statInfo) Size() int64        { return i.size }
+func (i *statInfo) Mode() fs.FileMode  { return i.mode }
+func (i *statInfo) ModTime() time.Time { return i.modTime }
+func (i *statInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *statInfo) Sys() any           { return &i.sys }
+
+func wrapPathError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
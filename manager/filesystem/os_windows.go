@@ -0,0 +1,251 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// newSandbox 在 Windows 上使用用户态解析。
+func newSandbox() sandbox {
+	return userSandbox{}
+}
+
+// handlePath 返回 f 的句柄当前指向的路径。
+func handlePath(f *os.File) (string, error) {
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	for {
+		n, err := windows.GetFinalPathNameByHandle(windows.Handle(f.Fd()), &buf[0], uint32(len(buf)), 0)
+		if err != nil {
+			return "", &fs.PathError{Op: "GetFinalPathNameByHandle", Path: f.Name(), Err: err}
+		}
+		if int(n) < len(buf) {
+			p := windows.UTF16ToString(buf[:n])
+			if rest, ok := strings.CutPrefix(p, `\\?\UNC\`); ok {
+				return `\\` + rest, nil
+			}
+			return strings.TrimPrefix(p, `\\?\`), nil
+		}
+		buf = make([]uint16, n)
+	}
+}
+
+// readDirectory 通过句柄读取 f 中的全部条目。
+func readDirectory(f *os.File) ([]fs.DirEntry, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return f.ReadDir(-1)
+}
+
+var procReOpenFile = windows.NewLazySystemDLL("kernel32.dll").NewProc("ReOpenFile")
+
+// chtimes 修改 f 的访问和修改时间。os.Open 得到的句柄没有写属性的权限，
+// 因此通过 ReOpenFile 从同一个句柄重新打开，而不是再次按路径打开。
+func chtimes(f *os.File, atime, mtime time.Time) error {
+	h, _, err := procReOpenFile.Call(f.Fd(), windows.FILE_WRITE_ATTRIBUTES,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, windows.FILE_FLAG_BACKUP_SEMANTICS)
+	if windows.Handle(h) == windows.InvalidHandle {
+		return &fs.PathError{Op: "ReOpenFile", Path: f.Name(), Err: err}
+	}
+	defer windows.CloseHandle(windows.Handle(h))
+	a := windows.NsecToFiletime(atime.UnixNano())
+	m := windows.NsecToFiletime(mtime.UnixNano())
+	return wrapPathError("SetFileTime", f.Name(), windows.SetFileTime(windows.Handle(h), nil, &a, &m))
+}
+
+func wrapPathError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
+
+// userSandbox 在用户态逐级解析路径，用于 Windows。
+// 基准目录的路径取自已打开的句柄而不是打开时的名字，Windows 上打开的目录及其上级目录
+// 不能被重命名，因此该路径在句柄关闭前保持有效。解析与实际操作之间仍存在竞争窗口。
+type userSandbox struct{}
+
+// resolve 将 path 解析为 dir 之下的宿主路径。
+// 中间分量上的符号链接总是会被跟随，最后一个分量仅在 follow 为 true 时跟随。
+// 返回的路径中不包含需要跟随的符号链接。
+func (userSandbox) resolve(dir *os.File, path string, follow bool) (string, error) {
+	if err := checkGuestPath(path); err != nil {
+		return "", err
+	}
+
+	root, err := handlePath(dir)
+	if err != nil {
+		return "", err
+	}
+	var resolved []string
+	pending := guestPathComponents(path)
+	links := 0
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+
+		switch c {
+		case ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", ErrNotPermitted
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		if filepath.VolumeName(c) != "" {
+			return "", ErrNotPermitted
+		}
+		if len(pending) == 0 && !follow {
+			resolved = append(resolved, c)
+			continue
+		}
+
+		current := filepath.Join(root, filepath.Join(resolved...), c)
+		info, err := os.Lstat(current)
+		// Windows 上的目录联接（junction）表现为 ModeIrregular，同样需要当作链接处理。
+		if err != nil || info.Mode()&(fs.ModeSymlink|fs.ModeIrregular) == 0 {
+			// 不存在的分量交给后续的实际操作去报错。
+			resolved = append(resolved, c)
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", ErrLoop
+		}
+		target, err := os.Readlink(current)
+		if err != nil {
+			return "", err
+		}
+		if target == "" || isAbsGuestPath(target) {
+			return "", ErrNotPermitted
+		}
+		// 链接目标相对于链接所在目录，继续与剩余分量一起解析。
+		pending = append(guestPathComponents(target), pending...)
+	}
+	return filepath.Join(append([]string{root}, resolved...)...), nil
+}
+
+func (s userSandbox) Open(dir *os.File, path string, follow bool, flag int, perm os.FileMode) (*os.File, error) {
+	p, err := s.resolve(dir, path, follow)
+	if err != nil {
+		return nil, err
+	}
+	if !follow {
+		// 模拟 O_NOFOLLOW：最后一个分量是符号链接时拒绝打开。
+		if info, err := os.Lstat(p); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return nil, ErrLoop
+		}
+	}
+	return os.OpenFile(p, flag, perm)
+}
+
+func (s userSandbox) Stat(dir *os.File, path string, follow bool) (fs.FileInfo, error) {
+	p, err := s.resolve(dir, path, follow)
+	if err != nil {
+		return nil, err
+	}
+	// 需要跟随的链接已经在 resolve 中处理过，这里不能再让系统跟随。
+	return os.Lstat(p)
+}
+
+func (s userSandbox) Mkdir(dir *os.File, path string, perm os.FileMode) error {
+	p, err := s.resolveEntry(dir, path)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, perm)
+}
+
+func (s userSandbox) Readlink(dir *os.File, path string) (string, error) {
+	p, err := s.resolveEntry(dir, path)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(p)
+}
+
+func (s userSandbox) Rmdir(dir *os.File, path string) error {
+	p, err := s.resolveEntry(dir, path)
+	if err != nil {
+		return err
+	}
+	return syscall.Rmdir(p)
+}
+
+func (s userSandbox) Unlink(dir *os.File, path string) error {
+	p, err := s.resolveEntry(dir, path)
+	if err != nil {
+		return err
+	}
+	// os.Remove 也会删除空目录，因此需要先排除目录。
+	if info, err := os.Lstat(p); err == nil && info.IsDir() {
+		return ErrIsDirectory
+	}
+	return os.Remove(p)
+}
+
+func (s userSandbox) Rename(oldDir *os.File, oldPath string, newDir *os.File, newPath string) error {
+	oldP, err := s.resolveEntry(oldDir, oldPath)
+	if err != nil {
+		return err
+	}
+	newP, err := s.resolveEntry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldP, newP)
+}
+
+func (s userSandbox) Link(oldDir *os.File, oldPath string, follow bool, newDir *os.File, newPath string) error {
+	oldP, err := s.resolve(oldDir, oldPath, follow)
+	if err != nil {
+		return err
+	}
+	newP, err := s.resolveEntry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	return os.Link(oldP, newP)
+}
+
+func (s userSandbox) Symlink(target string, dir *os.File, path string) error {
+	if err := checkSymlinkTarget(target); err != nil {
+		return err
+	}
+	p, err := s.resolveEntry(dir, path)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, p)
+}
+
+func (s userSandbox) Chtimes(dir *os.File, path string, follow bool, atime, mtime time.Time) error {
+	p, err := s.resolve(dir, path, follow)
+	if err != nil {
+		return err
+	}
+	// os.Chtimes 总是跟随符号链接，无法只修改链接本身。
+	if info, err := os.Lstat(p); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return errors.ErrUnsupported
+	}
+	return os.Chtimes(p, atime, mtime)
+}
+
+// resolveEntry 解析一个将被创建、删除或重命名的目录项，最后一个分量不会被跟随。
+func (s userSandbox) resolveEntry(dir *os.File, path string) (string, error) {
+	if _, _, err := guestParent(path); err != nil {
+		return "", err
+	}
+	return s.resolve(dir, path, false)
+}
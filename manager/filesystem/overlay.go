@@ -0,0 +1,817 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OverlayFS 是一个写时复制的后端。
+//
+// 读取会穿透到只读的基础层（base），所有的写入、创建、重命名和删除都落在上层（upper）中，
+// 基础层永远不会被修改。修改基础层中已有的文件前，会先把它复制到上层（copy-up）；
+// 删除基础层中的条目时，会记录一个 whiteout 将其隐藏。
+// Guest 结束后，Host 可以通过 Changes 查看改动，或通过 ExportTar 导出改动。
+type OverlayFS struct {
+	base, upper         Backend
+	baseRoot, upperRoot File
+
+	mu sync.Mutex
+	// whiteouts 记录被删除的路径。基础层中该路径及其下的所有条目都不再可见，
+	// 但上层中同名的条目仍然可见（例如删除目录后又重新创建）。
+	whiteouts map[string]bool
+}
+
+// NewOverlayFS 创建一个以 base 为基础层、以 upper 为上层的覆盖后端。
+// upper 通常是 NewMemoryFS() 或 NewTempDirBackend() 创建的空后端。
+func NewOverlayFS(base, upper Backend) (*OverlayFS, error) {
+	baseRoot, err := base.Root()
+	if err != nil {
+		return nil, err
+	}
+	upperRoot, err := upper.Root()
+	if err != nil {
+		baseRoot.Close()
+		return nil, err
+	}
+	return &OverlayFS{
+		base:      base,
+		upper:     upper,
+		baseRoot:  baseRoot,
+		upperRoot: upperRoot,
+		whiteouts: make(map[string]bool),
+	}, nil
+}
+
+// Base 返回基础层。
+func (o *OverlayFS) Base() Backend { return o.base }
+
+// Upper 返回保存所有改动的上层。
+func (o *OverlayFS) Upper() Backend { return o.upper }
+
+// Close 释放覆盖后端持有的根目录。上层实现了 io.Closer 时（例如临时目录）也会被关闭。
+func (o *OverlayFS) Close() error {
+	err := errors.Join(o.baseRoot.Close(), o.upperRoot.Close())
+	if c, ok := o.upper.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// ChangeKind 表示一处改动的类型。
+type ChangeKind int
+
+const (
+	// ChangeAdd 表示条目只存在于上层。
+	ChangeAdd ChangeKind = iota
+	// ChangeModify 表示基础层中的条目被修改或替换。
+	ChangeModify
+	// ChangeDelete 表示基础层中的条目被删除。
+	ChangeDelete
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdd:
+		return "A"
+	case ChangeModify:
+		return "C"
+	case ChangeDelete:
+		return "D"
+	}
+	return "?"
+}
+
+// Change 描述上层相对于基础层的一处改动。
+type Change struct {
+	// Path 是相对于根目录、以 '/' 分隔的路径。
+	Path string
+	Kind ChangeKind
+}
+
+// Changes 返回上层相对于基础层的所有改动，按路径排序。
+// 因为有文件被修改而复制到上层的目录会被报告为 ChangeModify。
+func (o *OverlayFS) Changes() ([]Change, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var changes []Change
+	err := o.walkUpper(".", func(p string, _ fs.FileInfo) error {
+		kind := ChangeAdd
+		if _, err := o.base.StatAt(o.baseRoot, p, false); err == nil {
+			kind = ChangeModify
+		}
+		changes = append(changes, Change{Path: p, Kind: kind})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range o.whiteouts {
+		if _, err := o.upper.StatAt(o.upperRoot, p, false); err != nil {
+			changes = append(changes, Change{Path: p, Kind: ChangeDelete})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// ExportTar 将上层以 OCI 镜像层的格式写入 w：
+// 删除的条目写为 ".wh.<name>"，替换了基础层目录的目录带有 ".wh..wh..opq" 标记。
+func (o *OverlayFS) ExportTar(w io.Writer) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	tw := tar.NewWriter(w)
+	err := o.walkUpper(".", func(p string, info fs.FileInfo) error {
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			target, err := o.upper.ReadlinkAt(o.upperRoot, p)
+			if err != nil {
+				return err
+			}
+			link = target
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = p
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode().IsRegular():
+			f, err := o.upper.OpenAt(o.upperRoot, p, false, os.O_RDONLY, 0)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(tw, io.NewSectionReader(f, 0, info.Size())); err != nil {
+				return err
+			}
+		case info.IsDir() && o.whiteouts[p]:
+			// 目录替换了基础层中被删除的目录，基础层中的内容不应再出现。
+			return tw.WriteHeader(&tar.Header{
+				Name:     path.Join(p, ".wh..wh..opq"),
+				Typeflag: tar.TypeReg,
+				Mode:     0644,
+				ModTime:  info.ModTime(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	deleted := make([]string, 0, len(o.whiteouts))
+	for p := range o.whiteouts {
+		if _, err := o.upper.StatAt(o.upperRoot, p, false); err != nil {
+			deleted = append(deleted, p)
+		}
+	}
+	sort.Strings(deleted)
+	for _, p := range deleted {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     path.Join(path.Dir(p), ".wh."+path.Base(p)),
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			ModTime:  time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// walkUpper 按路径顺序遍历上层中 dir 之下的所有条目，调用方需要持有锁。
+func (o *OverlayFS) walkUpper(dir string, fn func(p string, info fs.FileInfo) error) error {
+	f, err := o.upper.OpenAt(o.upperRoot, dir, false, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	entries, err := f.ReadDirectory()
+	f.Close()
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		info, err := o.upper.StatAt(o.upperRoot, p, false)
+		if err != nil {
+			return err
+		}
+		if err := fn(p, info); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := o.walkUpper(p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// overlayFile 是 OverlayFS 打开的文件。
+// 普通文件直接使用某一层中打开的文件；目录则在读取时合并两层的内容。
+type overlayFile struct {
+	o    *OverlayFS
+	path string
+	// f 是在某一层中打开的文件，目录为 nil。
+	f File
+}
+
+func (f *overlayFile) Close() error {
+	if f.f == nil {
+		return nil
+	}
+	return f.f.Close()
+}
+
+func (f *overlayFile) Stat() (fs.FileInfo, error) {
+	if f.f != nil {
+		return f.f.Stat()
+	}
+	f.o.mu.Lock()
+	defer f.o.mu.Unlock()
+	info, _, err := f.o.lookup(f.path)
+	return info, err
+}
+
+func (f *overlayFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.f == nil {
+		return 0, ErrIsDirectory
+	}
+	return f.f.ReadAt(p, off)
+}
+
+func (f *overlayFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.f == nil {
+		return 0, ErrIsDirectory
+	}
+	return f.f.WriteAt(p, off)
+}
+
+func (f *overlayFile) Truncate(size int64) error {
+	if f.f == nil {
+		return ErrIsDirectory
+	}
+	return f.f.Truncate(size)
+}
+
+func (f *overlayFile) Sync() error {
+	if f.f == nil {
+		return nil
+	}
+	return f.f.Sync()
+}
+
+func (f *overlayFile) ReadDirectory() ([]fs.DirEntry, error) {
+	if f.f != nil {
+		return nil, ErrNotDirectory
+	}
+	f.o.mu.Lock()
+	defer f.o.mu.Unlock()
+	return f.o.readDir(f.path)
+}
+
+func (f *overlayFile) Chtimes(atime, mtime time.Time) error {
+	f.o.mu.Lock()
+	defer f.o.mu.Unlock()
+	return f.o.chtimes(f.path, atime, mtime)
+}
+
+func (o *OverlayFS) Root() (File, error) {
+	return &overlayFile{o: o, path: "."}, nil
+}
+
+// dirPath 取出 OverlayFS 打开的目录对应的路径。
+func (o *OverlayFS) dirPath(dir File) (string, error) {
+	f, ok := dir.(*overlayFile)
+	if !ok || f.o != o {
+		return "", ErrCrossDevice
+	}
+	return f.path, nil
+}
+
+// lowerVisible 判断基础层中的 p 是否没有被 whiteout 隐藏。
+func (o *OverlayFS) lowerVisible(p string) bool {
+	for q := p; ; q = path.Dir(q) {
+		if o.whiteouts[q] {
+			return false
+		}
+		if q == "." {
+			return true
+		}
+	}
+}
+
+// lowerStat 返回基础层中未被隐藏的 p 的信息。
+func (o *OverlayFS) lowerStat(p string) (fs.FileInfo, error) {
+	if !o.lowerVisible(p) {
+		return nil, fs.ErrNotExist
+	}
+	return o.base.StatAt(o.baseRoot, p, false)
+}
+
+// lookup 返回 p 在合并视图中的信息，以及它是否位于上层。
+func (o *OverlayFS) lookup(p string) (fs.FileInfo, bool, error) {
+	if info, err := o.upper.StatAt(o.upperRoot, p, false); err == nil {
+		return info, true, nil
+	}
+	info, err := o.lowerStat(p)
+	return info, false, err
+}
+
+// resolve 在合并视图中从 dir 开始逐级解析 p，返回相对于根目录的路径。
+// 中间分量上的符号链接总是会被跟随，最后一个分量仅在 follow 为 true 时跟随。
+func (o *OverlayFS) resolve(dir, p string, follow bool) (string, error) {
+	if err := checkGuestPath(p); err != nil {
+		return "", err
+	}
+	var stack []string
+	if dir != "." {
+		stack = strings.Split(dir, "/")
+	}
+	floor := len(stack)
+	pending := guestPathComponents(p)
+	links := 0
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+
+		switch c {
+		case ".":
+			continue
+		case "..":
+			if len(stack) == floor {
+				return "", ErrNotPermitted
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if len(pending) == 0 && !follow {
+			stack = append(stack, c)
+			continue
+		}
+
+		current := path.Join(path.Join(stack...), c)
+		info, _, err := o.lookup(current)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			// 不存在的分量交给后续的实际操作去报错。
+			stack = append(stack, c)
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", ErrLoop
+		}
+		target, err := o.readlink(current)
+		if err != nil {
+			return "", err
+		}
+		if target == "" || isAbsGuestPath(target) {
+			return "", ErrNotPermitted
+		}
+		pending = append(guestPathComponents(target), pending...)
+	}
+	if len(stack) == 0 {
+		return ".", nil
+	}
+	return path.Join(stack...), nil
+}
+
+// resolveEntry 解析一个将被创建、删除或重命名的目录项。
+func (o *OverlayFS) resolveEntry(dir File, p string) (string, error) {
+	d, err := o.dirPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if _, _, err := guestParent(p); err != nil {
+		return "", err
+	}
+	return o.resolve(d, p, false)
+}
+
+func (o *OverlayFS) readlink(p string) (string, error) {
+	if _, inUpper, err := o.lookup(p); err != nil {
+		return "", err
+	} else if inUpper {
+		return o.upper.ReadlinkAt(o.upperRoot, p)
+	}
+	return o.base.ReadlinkAt(o.baseRoot, p)
+}
+
+// readDir 合并两层中目录 p 的内容。
+func (o *OverlayFS) readDir(p string) ([]fs.DirEntry, error) {
+	info, inUpper, err := o.lookup(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+
+	merged := make(map[string]fs.DirEntry)
+	if inUpper {
+		entries, err := readDirAt(o.upper, o.upperRoot, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			merged[entry.Name()] = entry
+		}
+	}
+	if lower, err := o.lowerStat(p); err == nil && lower.IsDir() {
+		entries, err := readDirAt(o.base, o.baseRoot, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if _, ok := merged[entry.Name()]; ok || o.whiteouts[path.Join(p, entry.Name())] {
+				continue
+			}
+			merged[entry.Name()] = entry
+		}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func readDirAt(b Backend, root File, p string) ([]fs.DirEntry, error) {
+	f, err := b.OpenAt(root, p, false, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.ReadDirectory()
+}
+
+// copyUp 确保 p 存在于上层中，必要时从基础层复制。
+func (o *OverlayFS) copyUp(p string) error {
+	if _, err := o.upper.StatAt(o.upperRoot, p, false); err == nil {
+		return nil
+	}
+	info, err := o.lowerStat(p)
+	if err != nil {
+		return err
+	}
+	if err := o.ensureUpperDir(path.Dir(p)); err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		return o.upper.MkdirAt(o.upperRoot, p, info.Mode().Perm())
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := o.base.ReadlinkAt(o.baseRoot, p)
+		if err != nil {
+			return err
+		}
+		return o.upper.SymlinkAt(target, o.upperRoot, p)
+	case info.Mode().IsRegular():
+		src, err := o.base.OpenAt(o.baseRoot, p, false, os.O_RDONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := o.upper.OpenAt(o.upperRoot, p, false, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+		if _, err := io.Copy(io.NewOffsetWriter(dst, 0), io.NewSectionReader(src, 0, info.Size())); err != nil {
+			return err
+		}
+		return dst.Chtimes(info.ModTime(), info.ModTime())
+	}
+	return ErrNotPermitted
+}
+
+// ensureUpperDir 确保目录 p 及其所有上级目录存在于上层中。
+func (o *OverlayFS) ensureUpperDir(p string) error {
+	if p == "." {
+		return nil
+	}
+	if info, err := o.upper.StatAt(o.upperRoot, p, false); err == nil {
+		if !info.IsDir() {
+			return ErrNotDirectory
+		}
+		return nil
+	}
+	info, err := o.lowerStat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotDirectory
+	}
+	if err := o.ensureUpperDir(path.Dir(p)); err != nil {
+		return err
+	}
+	return o.upper.MkdirAt(o.upperRoot, p, info.Mode().Perm())
+}
+
+// hide 在 p 从上层移除后，隐藏基础层中对应的条目。
+func (o *OverlayFS) hide(p string) {
+	if _, err := o.lowerStat(p); err != nil {
+		return
+	}
+	o.whiteouts[p] = true
+	// 上级目录已经被隐藏，下面的 whiteout 不再需要。
+	for q := range o.whiteouts {
+		if strings.HasPrefix(q, p+"/") {
+			delete(o.whiteouts, q)
+		}
+	}
+}
+
+// create 为在 p 创建新条目做准备：p 不能已经存在，其上级目录需要复制到上层。
+func (o *OverlayFS) create(p string) error {
+	if _, _, err := o.lookup(p); err == nil {
+		return fs.ErrExist
+	}
+	return o.ensureUpperDir(path.Dir(p))
+}
+
+func (o *OverlayFS) chtimes(p string, atime, mtime time.Time) error {
+	if err := o.copyUp(p); err != nil {
+		return err
+	}
+	return o.upper.ChtimesAt(o.upperRoot, p, false, atime, mtime)
+}
+
+func (o *OverlayFS) OpenAt(dir File, p string, follow bool, flag int, perm fs.FileMode) (File, error) {
+	d, err := o.dirPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	target, err := o.resolve(d, p, follow)
+	if err != nil {
+		return nil, err
+	}
+	info, inUpper, err := o.lookup(target)
+	switch {
+	case err != nil:
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		if err := o.ensureUpperDir(path.Dir(target)); err != nil {
+			return nil, err
+		}
+		inUpper = true
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, fs.ErrExist
+	case info.Mode()&fs.ModeSymlink != 0:
+		// 与 O_NOFOLLOW 相同。
+		return nil, ErrLoop
+	case info.IsDir():
+		if isWriteFlag(flag) {
+			return nil, ErrIsDirectory
+		}
+		return &overlayFile{o: o, path: target}, nil
+	case !inUpper && isWriteFlag(flag):
+		if err := o.copyUp(target); err != nil {
+			return nil, err
+		}
+		inUpper = true
+	}
+
+	var f File
+	if inUpper {
+		f, err = o.upper.OpenAt(o.upperRoot, target, false, flag, perm)
+	} else {
+		f, err = o.base.OpenAt(o.baseRoot, target, false, flag, perm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &overlayFile{o: o, path: target, f: f}, nil
+}
+
+func (o *OverlayFS) StatAt(dir File, p string, follow bool) (fs.FileInfo, error) {
+	d, err := o.dirPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	target, err := o.resolve(d, p, follow)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := o.lookup(target)
+	return info, err
+}
+
+func (o *OverlayFS) MkdirAt(dir File, p string, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	target, err := o.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	if err := o.create(target); err != nil {
+		return err
+	}
+	return o.upper.MkdirAt(o.upperRoot, target, perm)
+}
+
+func (o *OverlayFS) ReadlinkAt(dir File, p string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	target, err := o.resolveEntry(dir, p)
+	if err != nil {
+		return "", err
+	}
+	return o.readlink(target)
+}
+
+func (o *OverlayFS) RmdirAt(dir File, p string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	target, err := o.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	entries, err := o.readDir(target)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrNotEmpty
+	}
+	if _, err := o.upper.StatAt(o.upperRoot, target, false); err == nil {
+		if err := o.upper.RmdirAt(o.upperRoot, target); err != nil {
+			return err
+		}
+	}
+	o.hide(target)
+	return nil
+}
+
+func (o *OverlayFS) UnlinkAt(dir File, p string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	target, err := o.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	info, inUpper, err := o.lookup(target)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ErrIsDirectory
+	}
+	if inUpper {
+		if err := o.upper.UnlinkAt(o.upperRoot, target); err != nil {
+			return err
+		}
+	}
+	o.hide(target)
+	return nil
+}
+
+func (o *OverlayFS) RenameAt(oldDir File, oldPath string, newDir File, newPath string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	oldTarget, err := o.resolveEntry(oldDir, oldPath)
+	if err != nil {
+		return err
+	}
+	newTarget, err := o.resolveEntry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	if oldTarget == newTarget {
+		return nil
+	}
+
+	info, _, err := o.lookup(oldTarget)
+	if err != nil {
+		return err
+	}
+	if existing, _, err := o.lookup(newTarget); err == nil {
+		switch {
+		case info.IsDir() && !existing.IsDir():
+			return ErrNotDirectory
+		case !info.IsDir() && existing.IsDir():
+			return ErrIsDirectory
+		case existing.IsDir():
+			if entries, err := o.readDir(newTarget); err != nil {
+				return err
+			} else if len(entries) > 0 {
+				return ErrNotEmpty
+			}
+		}
+	}
+	if info.IsDir() {
+		// 与未开启 redirect_dir 的 Linux overlayfs 相同：基础层中的目录无法被重命名，
+		// 调用方应退回到复制后删除。
+		if lower, err := o.lowerStat(oldTarget); err == nil && lower.IsDir() {
+			return ErrCrossDevice
+		}
+	}
+
+	if err := o.copyUp(oldTarget); err != nil {
+		return err
+	}
+	if err := o.ensureUpperDir(path.Dir(newTarget)); err != nil {
+		return err
+	}
+	if err := o.upper.RenameAt(o.upperRoot, oldTarget, o.upperRoot, newTarget); err != nil {
+		return err
+	}
+	o.hide(oldTarget)
+	// 目录移动到基础层中已有（但合并后为空）的目录上时，基础层中的内容不能再出现。
+	if lower, err := o.lowerStat(newTarget); err == nil && lower.IsDir() {
+		o.whiteouts[newTarget] = true
+	}
+	return nil
+}
+
+func (o *OverlayFS) LinkAt(oldDir File, oldPath string, follow bool, newDir File, newPath string) error {
+	d, err := o.dirPath(oldDir)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	oldTarget, err := o.resolve(d, oldPath, follow)
+	if err != nil {
+		return err
+	}
+	newTarget, err := o.resolveEntry(newDir, newPath)
+	if err != nil {
+		return err
+	}
+	info, _, err := o.lookup(oldTarget)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ErrNotPermitted
+	}
+	if err := o.create(newTarget); err != nil {
+		return err
+	}
+	if err := o.copyUp(oldTarget); err != nil {
+		return err
+	}
+	return o.upper.LinkAt(o.upperRoot, oldTarget, false, o.upperRoot, newTarget)
+}
+
+func (o *OverlayFS) SymlinkAt(target string, dir File, p string) error {
+	if err := checkSymlinkTarget(target); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, err := o.resolveEntry(dir, p)
+	if err != nil {
+		return err
+	}
+	if err := o.create(entry); err != nil {
+		return err
+	}
+	return o.upper.SymlinkAt(target, o.upperRoot, entry)
+}
+
+func (o *OverlayFS) ChtimesAt(dir File, p string, follow bool, atime, mtime time.Time) error {
+	d, err := o.dirPath(dir)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	target, err := o.resolve(d, p, follow)
+	if err != nil {
+		return err
+	}
+	return o.chtimes(target, atime, mtime)
+}
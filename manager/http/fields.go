@@ -0,0 +1,153 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field 是一个 HTTP 头部条目。
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Fields 代表 HTTP 头部或尾部。
+// 条目按添加的顺序保存，并保留名称的大小写，查找时不区分大小写。
+// nil 的 Fields 可以读取，表现为空。
+type Fields struct {
+	entries []Field
+}
+
+func NewFields() *Fields {
+	return &Fields{}
+}
+
+// FieldsFromHeader 将 net/http 的头部转换为 Fields，h 为 nil 时返回 nil。
+// http.Header 中没有顺序，这里按名称排序以保证结果稳定，名称统一转为小写。
+func FieldsFromHeader(h http.Header) *Fields {
+	if h == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	f := &Fields{}
+	for _, k := range keys {
+		name := strings.ToLower(k)
+		for _, v := range h[k] {
+			f.entries = append(f.entries, Field{Name: name, Value: v})
+		}
+	}
+	return f
+}
+
+// Get 返回名称为 name 的所有值。
+func (f *Fields) Get(name string) []string {
+	if f == nil {
+		return nil
+	}
+	var values []string
+	for _, e := range f.entries {
+		if strings.EqualFold(e.Name, name) {
+			values = append(values, e.Value)
+		}
+	}
+	return values
+}
+
+// Has 判断是否存在名称为 name 的条目。
+func (f *Fields) Has(name string) bool {
+	if f == nil {
+		return false
+	}
+	for _, e := range f.entries {
+		if strings.EqualFold(e.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set 用 values 替换名称为 name 的所有条目，新条目位于原来第一个同名条目的位置。
+func (f *Fields) Set(name string, values []string) {
+	pos := -1
+	entries := f.entries[:0]
+	for _, e := range f.entries {
+		if strings.EqualFold(e.Name, name) {
+			if pos < 0 {
+				pos = len(entries)
+			}
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if pos < 0 {
+		pos = len(entries)
+	}
+
+	replaced := make([]Field, 0, len(entries)+len(values))
+	replaced = append(replaced, entries[:pos]...)
+	for _, v := range values {
+		replaced = append(replaced, Field{Name: name, Value: v})
+	}
+	f.entries = append(replaced, entries[pos:]...)
+}
+
+// Append 在末尾添加一个条目。
+func (f *Fields) Append(name, value string) {
+	f.entries = append(f.entries, Field{Name: name, Value: value})
+}
+
+// Delete 删除名称为 name 的所有条目。
+func (f *Fields) Delete(name string) {
+	entries := f.entries[:0]
+	for _, e := range f.entries {
+		if !strings.EqualFold(e.Name, name) {
+			entries = append(entries, e)
+		}
+	}
+	clear(f.entries[len(entries):])
+	f.entries = entries
+}
+
+// Entries 按顺序返回所有条目的副本。
+func (f *Fields) Entries() []Field {
+	if f == nil {
+		return nil
+	}
+	return append([]Field(nil), f.entries...)
+}
+
+// Clone 返回一个深拷贝。
+func (f *Fields) Clone() *Fields {
+	return &Fields{entries: f.Entries()}
+}
+
+// Header 将 Fields 转换为 net/http 使用的头部，名称会被规范化。
+func (f *Fields) Header() http.Header {
+	h := make(http.Header)
+	if f == nil {
+		return h
+	}
+	for _, e := range f.entries {
+		h.Add(e.Name, e.Value)
+	}
+	return h
+}
+
+// ContentLength 返回 fields 中声明的 Content-Length，没有声明或无法解析时返回 nil。
+func ContentLength(fields *Fields) *uint64 {
+	values := fields.Get("Content-Length")
+	if len(values) == 0 {
+		return nil
+	}
+	if val, err := strconv.ParseUint(strings.TrimSpace(values[0]), 10, 64); err == nil {
+		return &val
+	}
+	return nil
+}
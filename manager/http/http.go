@@ -3,6 +3,7 @@ package http
 import (
 	"io"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -10,9 +11,6 @@ import (
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
 )
 
-// Fields 代表 HTTP 头部或尾部。
-type Fields = http.Header
-
 // IncomingRequest 代表一个由 Host 接收的、传递给 Guest 的 HTTP 请求的内部表示。
 type IncomingRequest struct {
 	Request *http.Request
@@ -28,6 +26,8 @@ type IncomingRequest struct {
 
 	// BodyHandle 用于 Guest 端消费 Body
 	BodyHandle uint32
+	// 消耗标记
+	Consumed atomic.Bool
 }
 
 // OutgoingRequest 代表一个由 guest 构建的出站 HTTP 请求。
@@ -39,9 +39,12 @@ type OutgoingRequest struct {
 	Scheme    *string
 	Authority *string
 	Path      string
-	Headers   Fields
+	Headers   *Fields
 
 	Body io.Reader
+	// Trailers 在创建 Body 时初始化，并作为 Request.Trailer 发送。
+	// outgoing-body.finish 会在 Body 结束之前填充它。
+	Trailers http.Header
 
 	// 这里只是引用资源，不属于OutgoingRequest生命周期管理
 	// BodyWriter 用于在 Host 端写入 Guest 提供的数据
@@ -66,7 +69,7 @@ type IncomingResponse struct {
 	Response *http.Response
 
 	StatusCode int
-	Headers    Fields
+	Headers    *Fields
 
 	Body       *IncomingBody
 	BodyHandle uint32 // 指向 incoming-body 的句柄
@@ -74,14 +77,24 @@ type IncomingResponse struct {
 	Consumed atomic.Bool
 }
 
+// Close 在 Body 没有被取走时关闭响应体，取走之后由 incoming-body 负责关闭。
+func (o *IncomingResponse) Close() error {
+	if o.Consumed.Load() || o.Response == nil {
+		return nil
+	}
+	return o.Response.Body.Close()
+}
+
 // OutgoingResponse 代表一个由 Guest 构建的出站 HTTP 响应。
 type OutgoingResponse struct {
 	Response http.ResponseWriter
 
 	StatusCode int
-	Headers    Fields
+	Headers    *Fields
 
 	Body io.Reader
+	// Trailers 由 outgoing-body.finish 在 Body 结束之前设置。
+	Trailers *Fields
 
 	// 这里只是引用资源，不属于OutgoingResponse生命周期管理
 	BodyWriter *io.PipeWriter
@@ -113,13 +126,88 @@ type IncomingBody struct {
 	StreamHandle uint32 // 指向 input-stream 的句柄
 	Stream       io.Reader
 
-	// 可选方法
-	GetTrailers func() (trailers Fields)
+	// 可选方法，只有在 Stream 读取到结尾后返回的 trailers 才是完整的
+	GetTrailers func() (trailers *Fields)
+
+	// BytesRead 是已经从 Stream 读取的字节数
+	BytesRead atomic.Uint64
 
 	// 消耗标记
 	Consumed atomic.Bool
 }
 
+// NewIncomingBody 创建一个入站 Body。
+// Go 只有在 body 读取到 EOF 之后才会填充 trailers，getTrailers 会在那之后被调用。
+func NewIncomingBody(body io.ReadCloser, getTrailers func() *Fields) *IncomingBody {
+	return &IncomingBody{
+		Stream:      &trailerReader{ReadCloser: body, done: make(chan struct{})},
+		GetTrailers: getTrailers,
+	}
+}
+
+// Trailers 读取并丢弃 Body 中剩余的内容，然后返回 trailers。
+// Guest 可能没有读完 body 就调用了 finish，trailers 只有在 body 结束后才能得到。
+func (o *IncomingBody) Trailers() (*Fields, error) {
+	if r, ok := o.Stream.(*trailerReader); ok {
+		if err := r.drain(); err != nil {
+			return nil, err
+		}
+	}
+	if o.GetTrailers == nil {
+		return nil, nil
+	}
+	return o.GetTrailers(), nil
+}
+
+// trailerReader 记录 body 是否已经读取结束。
+// 读取是串行的，这样 drain 可以安全地接着 input-stream 的后台读取继续读下去。
+type trailerReader struct {
+	io.ReadCloser
+
+	mu   sync.Mutex
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func (r *trailerReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.done:
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, io.EOF
+	default:
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	if err != nil {
+		r.once.Do(func() {
+			if err != io.EOF {
+				r.err = err
+			}
+			close(r.done)
+		})
+	}
+	return n, err
+}
+
+func (r *trailerReader) drain() error {
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-r.done:
+			return r.err
+		default:
+		}
+		if _, err := r.Read(buf); err != nil && err != io.EOF {
+			return err
+		}
+	}
+}
+
 func (o *IncomingBody) Close() error {
 	if o.Stream != nil {
 		if closer, ok := o.Stream.(io.Closer); ok {
@@ -136,10 +224,13 @@ type OutgoingBody struct {
 	BodyWriter         *io.PipeWriter
 
 	// 可选方法
-	SetTrailers func(trailers Fields) error
+	SetTrailers func(trailers *Fields) error
 
+	// ContentLength 是头部中声明的长度，为 nil 时不限制写入的长度。
 	ContentLength *uint64
 	BytesWritten  atomic.Uint64
+	// Response 表示这是 outgoing-response 的 Body，用于区分长度不符时返回的错误。
+	Response bool
 
 	// 消耗标记
 	Consumed atomic.Bool
@@ -157,10 +248,20 @@ type FutureTrailers struct {
 	Pollable *manager_io.ChannelPollable
 	Result   ResultTrailers
 	Consumed atomic.Bool
+
+	// Cancel 中止后台对 Body 剩余内容的读取，future 被丢弃时调用。
+	Cancel func()
+}
+
+func (f *FutureTrailers) Close() error {
+	if f.Cancel != nil {
+		f.Cancel()
+	}
+	return nil
 }
 
 type ResultTrailers struct {
-	Trailers Fields
+	Trailers *Fields
 	Err      error
 }
 
@@ -172,11 +273,50 @@ type RequestOptions struct {
 }
 
 // FutureIncomingResponse 代表一个尚未到达的 HTTP 响应。
-// ResultChan 是实现异步的核心。
+// 后台的请求通过 SetResult 设置结果，Guest 在 Pollable 就绪后读取 Result。
 type FutureIncomingResponse struct {
 	Pollable *manager_io.ChannelPollable
 	Consumed atomic.Bool
 	Result   Result
+
+	// Cancel 取消进行中的请求，future 在取走响应之前被丢弃时调用。
+	Cancel func()
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// SetResult 设置请求的结果。future 已经被丢弃时，迟到的响应会被直接关闭。
+func (f *FutureIncomingResponse) SetResult(result Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		if result.Response != nil {
+			result.Response.Body.Close()
+		}
+		return
+	}
+	f.Result = result
+	f.Pollable.SetReady()
+}
+
+// Close 取消进行中的请求，并关闭还没有被 Guest 取走的响应。
+func (f *FutureIncomingResponse) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || f.Consumed.Load() {
+		// 响应已经交给了 incoming-response，由它负责关闭
+		f.closed = true
+		return nil
+	}
+	f.closed = true
+	if f.Cancel != nil {
+		f.Cancel()
+	}
+	if f.Result.Response != nil {
+		return f.Result.Response.Body.Close()
+	}
+	return nil
 }
 
 // Result 是一个内部类型，用于在 goroutine 之间传递 HTTP 请求的结果。
@@ -186,11 +326,58 @@ type Result struct {
 	Err      error // 或一个 Go 的 error
 }
 
-// FieldsManager 使用通用 ResourceManager 来管理 Fields 资源。
-type FieldsManager = witgo.ResourceManager[Fields]
+// FieldsManager 使用通用 ResourceManager 来管理 Fields 资源，并记录哪些句柄是不可变的。
+// 从请求、响应和 trailers 中取得的 fields 是不可变的，修改它们会返回 header-error.immutable。
+type FieldsManager struct {
+	*witgo.ResourceManager[*Fields]
+
+	mu        sync.Mutex
+	immutable map[uint32]struct{}
+}
 
 func NewFieldsManager() *FieldsManager {
-	return witgo.NewResourceManager[Fields](nil)
+	return &FieldsManager{
+		ResourceManager: witgo.NewResourceManager[*Fields](nil),
+		immutable:       make(map[uint32]struct{}),
+	}
+}
+
+// AddImmutable 添加一个不可变的 Fields。
+func (m *FieldsManager) AddImmutable(fields *Fields) uint32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	handle := m.ResourceManager.Add(fields)
+	m.immutable[handle] = struct{}{}
+	return handle
+}
+
+// IsImmutable 判断 handle 是否是不可变的。
+func (m *FieldsManager) IsImmutable(handle uint32) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.immutable[handle]
+	return ok
+}
+
+func (m *FieldsManager) Remove(handle uint32) bool {
+	m.mu.Lock()
+	delete(m.immutable, handle)
+	m.mu.Unlock()
+	return m.ResourceManager.Remove(handle)
+}
+
+func (m *FieldsManager) Pop(handle uint32) (*Fields, bool) {
+	m.mu.Lock()
+	delete(m.immutable, handle)
+	m.mu.Unlock()
+	return m.ResourceManager.Pop(handle)
+}
+
+func (m *FieldsManager) Clear() {
+	m.mu.Lock()
+	clear(m.immutable)
+	m.mu.Unlock()
+	m.ResourceManager.Clear()
 }
 
 // HTTPManager 是所有 HTTP 相关资源的总管理器。
@@ -223,18 +410,25 @@ func NewHTTPManager(sm *manager_io.StreamManager, poll *manager_io.PollManager)
 		OutgoingRequests: witgo.NewResourceManager[*OutgoingRequest](func(resource *OutgoingRequest) {
 			resource.Close()
 		}),
-		Futures:   witgo.NewResourceManager[*FutureIncomingResponse](nil),
-		Responses: witgo.NewResourceManager[*IncomingResponse](nil),
-		Bodies: witgo.NewResourceManager[*OutgoingBody](func(resource *OutgoingBody) {
+		Futures: witgo.NewResourceManager[*FutureIncomingResponse](func(resource *FutureIncomingResponse) {
 			resource.Close()
-
+		}),
+		Responses: witgo.NewResourceManager[*IncomingResponse](func(resource *IncomingResponse) {
+			resource.Close()
+		}),
+		Bodies: witgo.NewResourceManager[*OutgoingBody](func(resource *OutgoingBody) {
+			// finish 会先取出资源，走到这里说明 Body 没有正常结束，
+			// 必须先以 ErrShortWrite 关闭，读取方才不会把它当作完整的 Body。
 			// NOTE: 为了防止忘记关闭，这里的生命周期和Stream绑定
 			if resource.BodyWriter != nil {
 				resource.BodyWriter.CloseWithError(io.ErrShortWrite)
 				sm.Remove(resource.OutputStreamHandle)
 			}
+			resource.Close()
+		}),
+		FutureTrailers: witgo.NewResourceManager[*FutureTrailers](func(resource *FutureTrailers) {
+			resource.Close()
 		}),
-		FutureTrailers: witgo.NewResourceManager[*FutureTrailers](nil),
 
 		IncomingRequests: witgo.NewResourceManager[*IncomingRequest](func(resource *IncomingRequest) {
 			resource.Body.Close()
@@ -256,7 +450,23 @@ func NewHTTPManager(sm *manager_io.StreamManager, poll *manager_io.PollManager)
 	}
 }
 
-func (hm *HTTPManager) NewOutgoingBody(contentLength *uint64, setTrailers func(trailers Fields) error) (bodyHandle uint32, bodyReader *io.PipeReader, bodyWriter *io.PipeWriter) {
+// Clear 释放所有 HTTP 资源。Streams 和 Poll 由 Host 持有，不在此处释放。
+func (hm *HTTPManager) Clear() {
+	hm.OutgoingRequests.Clear()
+	hm.Bodies.Clear()
+	hm.Futures.Clear()
+	hm.Responses.Clear()
+	hm.FutureTrailers.Clear()
+	hm.Options.Clear()
+
+	hm.IncomingRequests.Clear()
+	hm.ResponseOutparams.Clear()
+	hm.OutgoingResponses.Clear()
+	hm.IncomingBodies.Clear()
+	hm.Fields.Clear()
+}
+
+func (hm *HTTPManager) NewOutgoingBody(contentLength *uint64, response bool, setTrailers func(trailers *Fields) error) (bodyHandle uint32, bodyReader *io.PipeReader, bodyWriter *io.PipeWriter) {
 	pr, pw := io.Pipe()
 
 	body := &OutgoingBody{
@@ -264,6 +474,7 @@ func (hm *HTTPManager) NewOutgoingBody(contentLength *uint64, setTrailers func(t
 		BodyWriter:    pw,
 		SetTrailers:   setTrailers,
 		ContentLength: contentLength,
+		Response:      response,
 	}
 
 	bodyHandle = hm.Bodies.Add(body)
@@ -10,6 +10,28 @@ import (
 // 默认的读取缓冲区大小，用于后台读取操作。
 const defaultBufferSize = 8192
 
+// 默认的读取高水位，后台读取的数据超过它时暂停读取，直到 Guest 取走数据。
+const defaultMaxReadBufferSize = 256 * 1024
+
+// chunkPool 复用后台读写时使用的临时缓冲区。
+var chunkPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, defaultBufferSize)
+		return &buf
+	},
+}
+
+// readBufferPool 复用 AsyncReadWrapper 的内部缓冲区，wrapper 关闭时归还。
+var readBufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// maxPooledReadBuffer 是放回 readBufferPool 的缓冲区的最大容量，
+// 更大的缓冲区来自调大的高水位，不放回以免长期占用内存。
+const maxPooledReadBuffer = 2 * defaultMaxReadBufferSize
+
 // --- 优化后的异步读取封装器 ---
 
 // AsyncReadWrapperOption 是用于配置 AsyncReadWrapper 的函数类型。
@@ -22,31 +44,54 @@ func DontCloseReader() AsyncReadWrapperOption {
 	}
 }
 
+// 记录读取数量，统计的是从底层 reader 读入缓冲区的字节数，包括 Guest 还没有取走的部分
+func ReaderRead(bytesRead *atomic.Uint64) AsyncReadWrapperOption {
+	return func(arw *AsyncReadWrapper) {
+		arw.bytesRead = bytesRead
+	}
+}
+
+// WithMaxReadBufferSize 设置内部缓冲区的高水位，缓冲的数据达到 size 时后台读取暂停。
+func WithMaxReadBufferSize(size int) AsyncReadWrapperOption {
+	return func(arw *AsyncReadWrapper) {
+		arw.maxBufferSize = size
+	}
+}
+
 // AsyncReadWrapper 将一个阻塞的 io.Reader 封装成一个非阻塞的 reader。
 // 它在后台持续地从底层 reader 读取数据，并将其存入内部缓冲区。
+// 缓冲区达到高水位时后台读取暂停，Guest 取走数据后继续，以此向底层 reader 施加背压。
 type AsyncReadWrapper struct {
 	reader          io.Reader
 	buffer          *bytes.Buffer
 	mutex           sync.Mutex
+	cond            *sync.Cond
 	ready           *ChannelPollable
 	done            chan struct{}
 	err             error
+	maxBufferSize   int
 	once            sync.Once
 	closeUnderlying bool
+	bytesRead       *atomic.Uint64
 }
 
 // NewAsyncReadWrapper 创建并启动一个新的异步读取封装器。
 func NewAsyncReadWrapper(r io.Reader, opts ...AsyncReadWrapperOption) *AsyncReadWrapper {
 	wrapper := &AsyncReadWrapper{
 		reader:          r,
-		buffer:          &bytes.Buffer{},
+		buffer:          readBufferPool.Get().(*bytes.Buffer),
 		ready:           NewPollable(nil),
 		done:            make(chan struct{}),
+		maxBufferSize:   defaultMaxReadBufferSize,
 		closeUnderlying: true, // 默认在 Close 时关闭底层 reader。
 	}
 	for _, opt := range opts {
 		opt(wrapper)
 	}
+	if wrapper.maxBufferSize <= 0 {
+		wrapper.maxBufferSize = defaultMaxReadBufferSize
+	}
+	wrapper.cond = sync.NewCond(&wrapper.mutex)
 	// 启动后台读取 goroutine。
 	go wrapper.run()
 	return wrapper
@@ -61,23 +106,40 @@ func (arw *AsyncReadWrapper) run() {
 		arw.mutex.Unlock()
 	}()
 
-	readBuf := make([]byte, defaultBufferSize)
+	chunk := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(chunk)
+	readBuf := *chunk
+
 	for {
-		// 检查是否有关闭信号。
-		select {
-		case <-arw.done:
+		// 缓冲区达到高水位时等待 Guest 取走数据，同时检查是否有关闭信号。
+		arw.mutex.Lock()
+		for arw.buffer.Len() >= arw.maxBufferSize && !arw.closed() {
+			arw.cond.Wait()
+		}
+		if arw.closed() {
+			arw.mutex.Unlock()
 			return
-		default:
 		}
+		// 每次最多读取到高水位为止
+		size := min(len(readBuf), arw.maxBufferSize-arw.buffer.Len())
+		arw.mutex.Unlock()
 
 		// 执行阻塞读取。当没有数据时，goroutine 会在这里自然地暂停。
-		n, readErr := arw.reader.Read(readBuf)
+		n, readErr := arw.reader.Read(readBuf[:size])
 
 		arw.mutex.Lock()
+		if arw.closed() {
+			// 缓冲区已经归还，丢弃读到的数据
+			arw.mutex.Unlock()
+			return
+		}
 		wasEmpty := arw.buffer.Len() == 0
 		if n > 0 {
 			// 将读取到的数据写入内部缓冲区。
 			arw.buffer.Write(readBuf[:n])
+			if arw.bytesRead != nil {
+				arw.bytesRead.Add(uint64(n))
+			}
 		}
 
 		// 如果发生了错误（例如 io.EOF），记录它并准备终止 goroutine。
@@ -117,6 +179,8 @@ func (arw *AsyncReadWrapper) Read(p []byte) (n int, err error) {
 		if arw.buffer.Len() == 0 && arw.err == nil {
 			arw.ready.Reset()
 		}
+		// 缓冲区有了空间，唤醒可能因高水位而暂停的后台读取
+		arw.cond.Signal()
 
 		return n, nil
 	}
@@ -149,7 +213,17 @@ func (arw *AsyncReadWrapper) subscribe() IPollable {
 func (arw *AsyncReadWrapper) Close() error {
 	var closeErr error
 	arw.once.Do(func() {
+		arw.mutex.Lock()
 		close(arw.done)
+		arw.cond.Broadcast()
+		// 关闭后缓冲的数据不再可读，把缓冲区归还给 pool
+		buf := arw.buffer
+		arw.buffer = new(bytes.Buffer)
+		arw.mutex.Unlock()
+		if buf.Cap() <= maxPooledReadBuffer {
+			buf.Reset()
+			readBufferPool.Put(buf)
+		}
 
 		// 根据配置决定是否关闭底层 reader
 		if arw.closeUnderlying {
@@ -161,6 +235,16 @@ func (arw *AsyncReadWrapper) Close() error {
 	return closeErr
 }
 
+// closed 判断 wrapper 是否已被关闭。
+func (arw *AsyncReadWrapper) closed() bool {
+	select {
+	case <-arw.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // NewAsyncStreamForReader 是一个便捷的辅助函数，
 // 将一个阻塞的 io.Reader 转换为完全支持异步 subscribe 的 *Stream。
 func NewAsyncStreamForReader(r io.Reader, opts ...AsyncReadWrapperOption) *Stream {
@@ -247,11 +331,19 @@ func (aww *AsyncWriteWrapper) run() {
 			}
 		}
 
-		tempBuf := make([]byte, aww.buffer.Len())
+		// 缓冲区不超过默认大小时使用复用的临时缓冲区
+		chunk := chunkPool.Get().(*[]byte)
+		var tempBuf []byte
+		if size := aww.buffer.Len(); size <= len(*chunk) {
+			tempBuf = (*chunk)[:size]
+		} else {
+			tempBuf = make([]byte, size)
+		}
 		_, _ = aww.buffer.Read(tempBuf)
 		aww.mutex.Unlock()
 
 		n, err := aww.writer.Write(tempBuf)
+		chunkPool.Put(chunk)
 
 		aww.mutex.Lock()
 		if n > 0 && aww.bytesWritten != nil {
@@ -0,0 +1,75 @@
+package io
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInstanceClosed 表示阻塞期间 Guest 实例已被关闭。
+	ErrInstanceClosed = errors.New("wasi: instance closed while blocking")
+	// ErrBlockTimeout 表示一次阻塞调用超过了 Host 允许的最长阻塞时间。
+	ErrBlockTimeout = errors.New("wasi: blocking call exceeded the maximum block duration")
+)
+
+// Blocker 让阻塞的 Host 调用可以被打断。
+// 每次等待都会同时关注调用的 context、实例的关闭以及最长阻塞时间。
+type Blocker struct {
+	// MaxBlock 是单次阻塞调用允许等待的最长时间，零值表示不限制。
+	MaxBlock time.Duration
+
+	once sync.Once
+	done chan struct{}
+}
+
+func NewBlocker() *Blocker {
+	return &Blocker{done: make(chan struct{})}
+}
+
+// Close 通知所有正在等待的调用实例已关闭。这个操作是幂等的。
+func (b *Blocker) Close() {
+	b.once.Do(func() { close(b.done) })
+}
+
+// Done 返回实例关闭时被关闭的 channel。
+func (b *Blocker) Done() <-chan struct{} {
+	return b.done
+}
+
+// Context 返回一个在超过 MaxBlock 时被取消的 context，阻塞的 Host 调用在开始等待前调用它。
+// 一次调用中的多次等待共享同一个期限。
+func (b *Blocker) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.MaxBlock <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, b.MaxBlock, ErrBlockTimeout)
+}
+
+// Wait 阻塞直到 ch 被关闭，等待被打断时返回对应的错误。
+func (b *Blocker) Wait(ctx context.Context, ch <-chan struct{}) error {
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-b.done:
+		return ErrInstanceClosed
+	}
+}
+
+// Sleep 等待 d，等待被打断时返回对应的错误。
+// 用于没有订阅机制、只能轮询的流。
+func (b *Blocker) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-b.done:
+		return ErrInstanceClosed
+	}
+}
@@ -11,8 +11,11 @@ type IPollable interface {
 	IsReady() bool
 	// Block 阻塞直到 Pollable 就绪。
 	Block()
-	// Channel 返回内部的 channel，用于 reflect.Select。
+	// Channel 返回内部的 channel，用于等待单个 Pollable。
 	Channel() <-chan struct{}
+	// Register 注册一个唤醒 channel，Pollable 就绪时向它发送一个不阻塞的通知，返回取消注册的函数。
+	// 注册时已经就绪会立即通知。多个 Pollable 可以共用同一个唤醒 channel，poll 借此同时等待任意多个 Pollable。
+	Register(wake chan struct{}) (unregister func())
 	// Close 用于释放与 Pollable 关联的资源，例如取消底层的定时器。
 	Close()
 }
@@ -23,6 +26,9 @@ type ChannelPollable struct {
 	mu        sync.Mutex
 	readyChan chan struct{}
 	cancel    func() // 用于 Close()
+	// external 表示 readyChan 由外部关闭，不经过 SetReady。
+	external bool
+	waiters  map[chan struct{}]struct{}
 }
 
 // NewPollable 创建一个新的 channelPollable 实例。
@@ -33,10 +39,12 @@ func NewPollable(cancel func()) *ChannelPollable {
 	}
 }
 
+// NewPollableByChan 创建一个在 c 被关闭时就绪的 ChannelPollable，c 由调用者负责关闭。
 func NewPollableByChan(c chan struct{}, cancel func()) *ChannelPollable {
 	return &ChannelPollable{
 		readyChan: c,
 		cancel:    cancel,
+		external:  true,
 	}
 }
 
@@ -88,6 +96,9 @@ func (p *ChannelPollable) SetReady() {
 	default:
 		// 尚未关闭，关闭它以将其设置为“就绪”。
 		close(p.readyChan)
+		for wake := range p.waiters {
+			notify(wake)
+		}
 	}
 }
 
@@ -101,6 +112,7 @@ func (p *ChannelPollable) Reset() {
 	case <-p.readyChan:
 		// channel 已关闭（已就绪），因此我们需要创建一个新的 channel。
 		p.readyChan = make(chan struct{})
+		p.external = false
 	default:
 		// channel 仍然是打开的（未就绪），无需任何操作。
 	}
@@ -115,6 +127,52 @@ func (p *ChannelPollable) Channel() <-chan struct{} {
 	return p.readyChan
 }
 
+// Register 实现 IPollable 接口。
+func (p *ChannelPollable) Register(wake chan struct{}) (unregister func()) {
+	p.mu.Lock()
+	ch := p.readyChan
+	select {
+	case <-ch:
+		p.mu.Unlock()
+		notify(wake)
+		return func() {}
+	default:
+	}
+
+	if p.external {
+		// 外部关闭的 channel 无法在 SetReady 中通知，只能单独等待它。
+		p.mu.Unlock()
+		stop := make(chan struct{})
+		go func() {
+			select {
+			case <-ch:
+				notify(wake)
+			case <-stop:
+			}
+		}()
+		return sync.OnceFunc(func() { close(stop) })
+	}
+
+	if p.waiters == nil {
+		p.waiters = make(map[chan struct{}]struct{})
+	}
+	p.waiters[wake] = struct{}{}
+	p.mu.Unlock()
+	return func() {
+		p.mu.Lock()
+		delete(p.waiters, wake)
+		p.mu.Unlock()
+	}
+}
+
+// notify 向唤醒 channel 发送通知，channel 中已有未处理的通知时直接返回。
+func notify(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
 // Close 调用与此 pollable 关联的取消函数（如果存在）。
 func (p *ChannelPollable) Close() {
 	if p.cancel != nil {
@@ -0,0 +1,147 @@
+package sockets
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
+)
+
+const defaultAcceptQueueSize = 128 // 默认最多排队 128 个未被 accept 的连接
+
+// Accept 出错后重试的等待时间，与 net/http.Server 一样从 5ms 开始翻倍，最长 1s。
+const (
+	minAcceptRetryDelay = 5 * time.Millisecond
+	maxAcceptRetryDelay = time.Second
+)
+
+// --- Asynchronous TCP Acceptor ---
+
+// AsyncAcceptor 在后台接受连接并放入队列，使 accept 可以非阻塞地进行。
+// 队列满时暂停接受，新的连接留在内核的 backlog 中。
+type AsyncAcceptor struct {
+	listener net.Listener
+	queue    []TCPConn
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	ready    *manager_io.ChannelPollable
+	err      error
+	closed   bool
+	done     chan struct{}
+}
+
+func NewAsyncAcceptor(listener net.Listener) *AsyncAcceptor {
+	a := &AsyncAcceptor{
+		listener: listener,
+		ready:    manager_io.NewPollable(nil),
+		done:     make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mutex)
+	go a.run()
+	return a
+}
+
+// run 在后台循环接受连接。EMFILE、ECONNABORTED 等错误是暂时的，等待一段时间后重试，
+// 只有监听器被关闭时才停止并向 Guest 报告错误。
+func (a *AsyncAcceptor) run() {
+	var delay time.Duration
+	for {
+		c, err := a.listener.Accept()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			if delay == 0 {
+				delay = minAcceptRetryDelay
+			} else {
+				delay = min(2*delay, maxAcceptRetryDelay)
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+				continue
+			case <-a.done:
+				timer.Stop()
+				return
+			}
+		}
+		delay = 0
+
+		a.mutex.Lock()
+		if a.closed {
+			a.mutex.Unlock()
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if err != nil {
+			a.err = err
+			a.ready.SetReady()
+			a.mutex.Unlock()
+			return
+		}
+
+		conn, ok := c.(TCPConn)
+		if !ok {
+			// 不支持半关闭的连接不能作为 TCP 套接字使用
+			c.Close()
+			a.mutex.Unlock()
+			continue
+		}
+		a.queue = append(a.queue, conn)
+		a.ready.SetReady()
+		for len(a.queue) >= defaultAcceptQueueSize && !a.closed {
+			a.cond.Wait()
+		}
+		a.mutex.Unlock()
+	}
+}
+
+// Accept 非阻塞地取出一个已建立的连接。
+// 没有等待中的连接时返回 (nil, nil)，调用者应该使用 Subscribe 等待。
+func (a *AsyncAcceptor) Accept() (TCPConn, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.queue) > 0 {
+		conn := a.queue[0]
+		a.queue[0] = nil
+		a.queue = a.queue[1:]
+		if len(a.queue) == 0 && a.err == nil {
+			a.ready.Reset()
+		}
+		a.cond.Signal()
+		return conn, nil
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return nil, nil
+}
+
+// Subscribe 返回一个 pollable，有等待中的连接或监听出错时就绪。
+func (a *AsyncAcceptor) Subscribe() manager_io.IPollable {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if len(a.queue) > 0 || a.err != nil {
+		a.ready.SetReady()
+	}
+	return a.ready
+}
+
+// Close 关闭所有还没有被取出的连接。监听器由调用者关闭，关闭后后台 goroutine 随之退出。
+func (a *AsyncAcceptor) Close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+	close(a.done)
+	for _, conn := range a.queue {
+		conn.Close()
+	}
+	a.queue = nil
+	a.ready.SetReady()
+	a.cond.Broadcast()
+}
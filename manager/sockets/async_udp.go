@@ -15,7 +15,7 @@ const defaultUDPBufferSize = 256 // 默认缓冲 256 个数据报
 // --- Asynchronous UDP Reader ---
 
 type AsyncUDPReader struct {
-	conn   *net.UDPConn
+	conn   UDPConn
 	buffer []IncomingDatagram
 	mutex  sync.Mutex
 	ready  *manager_io.ChannelPollable
@@ -24,7 +24,7 @@ type AsyncUDPReader struct {
 	once   sync.Once
 }
 
-func NewAsyncUDPReader(conn *net.UDPConn) *AsyncUDPReader {
+func NewAsyncUDPReader(conn UDPConn) *AsyncUDPReader {
 	wrapper := &AsyncUDPReader{
 		conn:   conn,
 		buffer: make([]IncomingDatagram, 0, 32),
@@ -44,7 +44,7 @@ func (ar *AsyncUDPReader) run() {
 		default:
 		}
 
-		n, remoteAddr, err := ar.conn.ReadFromUDP(buf)
+		n, remoteAddr, err := ar.conn.ReadFrom(buf)
 
 		ar.mutex.Lock()
 		if err != nil {
@@ -123,7 +123,7 @@ func (ar *AsyncUDPReader) Close() {
 // --- Asynchronous UDP Writer with Backpressure ---
 
 type AsyncUDPWriter struct {
-	conn          *net.UDPConn
+	conn          UDPConn
 	buffer        []OutgoingDatagram
 	mutex         sync.Mutex
 	cond          *sync.Cond
@@ -134,7 +134,7 @@ type AsyncUDPWriter struct {
 	once          sync.Once
 }
 
-func NewAsyncUDPWriter(conn *net.UDPConn) *AsyncUDPWriter {
+func NewAsyncUDPWriter(conn UDPConn) *AsyncUDPWriter {
 	wrapper := &AsyncUDPWriter{
 		conn:          conn,
 		buffer:        make([]OutgoingDatagram, 0, defaultUDPBufferSize),
@@ -179,7 +179,7 @@ func (aw *AsyncUDPWriter) run() {
 			}
 
 			if remoteAddr != nil {
-				_, writeErr = aw.conn.WriteToUDP(dg.Data, remoteAddr)
+				_, writeErr = aw.conn.WriteTo(dg.Data, remoteAddr)
 			} else {
 				_, writeErr = aw.conn.Write(dg.Data)
 			}
@@ -0,0 +1,103 @@
+package sockets
+
+import (
+	"net/netip"
+)
+
+// NetworkPolicy 限制 Guest 通过 network 资源可以进行的网络访问，零值不做任何限制。
+// 策略随 instance-network 返回的 network 资源交给 Guest，套接字在绑定或连接时记住它所使用的策略。
+type NetworkPolicy struct {
+	// AllowedConnect 不为空时，只允许 TCP 连接和 UDP 发送到匹配的远端地址。
+	AllowedConnect []AddressRule
+	// DeniedConnect 中匹配的远端地址总是被拒绝，优先于 AllowedConnect。
+	DeniedConnect []AddressRule
+
+	// AllowedBind 不为空时，只允许绑定到匹配的本地地址。
+	AllowedBind []AddressRule
+
+	// DenyUDP 禁止 Guest 使用 UDP。
+	DenyUDP bool
+	// DenyListen 禁止 Guest 监听 TCP 端口。
+	DenyListen bool
+	// DenyNameLookup 禁止 Guest 解析域名。
+	DenyNameLookup bool
+	// DeniedResolve 中的网段不会出现在域名解析的结果中，用于防止域名指向内部地址。
+	DeniedResolve []netip.Prefix
+}
+
+// AddressRule 匹配一个网段和一个端口范围。
+type AddressRule struct {
+	// Prefix 为零值时匹配所有地址。
+	Prefix netip.Prefix
+	// PortMin 和 PortMax 都为零时匹配所有端口，只设置 PortMin 时只匹配这一个端口。
+	PortMin, PortMax uint16
+}
+
+// Match 判断 addr 是否匹配该规则。
+func (r AddressRule) Match(addr netip.AddrPort) bool {
+	if r.Prefix.IsValid() && !r.Prefix.Contains(addr.Addr().Unmap()) {
+		return false
+	}
+	if r.PortMin == 0 && r.PortMax == 0 {
+		return true
+	}
+	port := addr.Port()
+	if r.PortMax == 0 {
+		return port == r.PortMin
+	}
+	return port >= r.PortMin && port <= r.PortMax
+}
+
+// AllowConnect 判断是否允许连接或发送到 addr。nil 的策略允许所有访问。
+func (p *NetworkPolicy) AllowConnect(addr netip.AddrPort) bool {
+	if p == nil {
+		return true
+	}
+	if matchRules(p.DeniedConnect, addr) {
+		return false
+	}
+	return len(p.AllowedConnect) == 0 || matchRules(p.AllowedConnect, addr)
+}
+
+// AllowBind 判断是否允许绑定到本地地址 addr。
+func (p *NetworkPolicy) AllowBind(addr netip.AddrPort) bool {
+	return p == nil || len(p.AllowedBind) == 0 || matchRules(p.AllowedBind, addr)
+}
+
+// AllowUDP 判断是否允许使用 UDP。
+func (p *NetworkPolicy) AllowUDP() bool {
+	return p == nil || !p.DenyUDP
+}
+
+// AllowListen 判断是否允许监听 TCP 端口。
+func (p *NetworkPolicy) AllowListen() bool {
+	return p == nil || !p.DenyListen
+}
+
+// AllowNameLookup 判断是否允许解析域名。
+func (p *NetworkPolicy) AllowNameLookup() bool {
+	return p == nil || !p.DenyNameLookup
+}
+
+// AllowResolved 判断域名解析得到的地址 addr 是否可以交给 Guest。
+func (p *NetworkPolicy) AllowResolved(addr netip.Addr) bool {
+	if p == nil {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.DeniedResolve {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchRules(rules []AddressRule, addr netip.AddrPort) bool {
+	for _, rule := range rules {
+		if rule.Match(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package sockets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Proxy 让 Guest 的 TCP 连接经过 SOCKS5 或 HTTP CONNECT 代理建立。
+// 经过代理的连接由 Host 另行建立，不使用 Guest 在连接前设置的选项；
+// 已经绑定了本地地址的套接字无法经过代理连接，start-connect 返回 not-supported。
+type Proxy struct {
+	url    *url.URL
+	bypass []AddressRule
+}
+
+// NewProxy 解析代理地址，支持 socks5://host:port 和 http://host:port，地址中的用户名和密码用于认证。
+// 目标地址匹配 bypass 中的规则时不经过代理直接连接。
+func NewProxy(rawURL string, bypass ...AddressRule) (*Proxy, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "socks5", "http":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		port := "1080"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return &Proxy{url: u, bypass: bypass}, nil
+}
+
+// Bypassed 判断到 addr 的连接是否不经过代理。
+func (p *Proxy) Bypassed(addr netip.AddrPort) bool {
+	return matchRules(p.bypass, addr)
+}
+
+// DialContext 通过代理连接到 remote。代理拒绝连接时返回的错误包装了对应的 syscall.Errno，
+// 例如目标拒绝连接时为 ECONNREFUSED，代理拒绝访问时为 EACCES。
+func (p *Proxy) DialContext(ctx context.Context, remote netip.AddrPort) (TCPConn, error) {
+	d := net.Dialer{KeepAlive: -1}
+	c, err := d.DialContext(ctx, "tcp", p.url.Host)
+	if err != nil {
+		return nil, err
+	}
+	conn := c.(*net.TCPConn)
+
+	// 握手期间 context 被取消时打断读写
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	if p.url.Scheme == "socks5" {
+		err = p.socks5Connect(conn, remote)
+	} else {
+		err = p.httpConnect(conn, remote)
+	}
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, &net.OpError{Op: "proxy", Net: "tcp", Addr: net.TCPAddrFromAddrPort(remote), Err: err}
+	}
+	conn.SetDeadline(time.Time{})
+	return &proxyConn{TCPConn: conn, remote: net.TCPAddrFromAddrPort(remote)}, nil
+}
+
+// proxyConn 是经过代理建立的连接，RemoteAddr 返回目标地址而不是代理的地址。
+type proxyConn struct {
+	*net.TCPConn
+	remote net.Addr
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// errProxyProtocol 表示代理的响应不符合协议。
+var errProxyProtocol = fmt.Errorf("malformed proxy response: %w", syscall.ECONNABORTED)
+
+// socks5Connect 按 RFC 1928 和 RFC 1929 完成 SOCKS5 握手。
+func (p *Proxy) socks5Connect(conn net.Conn, remote netip.AddrPort) error {
+	methods := []byte{0x00}
+	if p.url.User != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return errProxyProtocol
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if p.url.User == nil {
+			return errProxyProtocol
+		}
+		user := p.url.User.Username()
+		pass, _ := p.url.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return fmt.Errorf("socks5 credentials too long: %w", syscall.EINVAL)
+		}
+		req := append([]byte{0x01, byte(len(user))}, user...)
+		req = append(append(req, byte(len(pass))), pass...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return fmt.Errorf("socks5 authentication failed: %w", syscall.EACCES)
+		}
+	default:
+		return fmt.Errorf("socks5 proxy requires an unsupported authentication method: %w", syscall.EACCES)
+	}
+
+	addr := remote.Addr().Unmap()
+	req := []byte{0x05, 0x01, 0x00, 0x01}
+	if addr.Is6() {
+		req[3] = 0x04
+	}
+	req = append(req, addr.AsSlice()...)
+	req = binary.BigEndian.AppendUint16(req, remote.Port())
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[0] != 0x05 {
+		return errProxyProtocol
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("socks5 connect failed with reply %d: %w", head[1], socks5Errno(head[1]))
+	}
+	// 丢弃代理使用的本地地址
+	var n int
+	switch head[3] {
+	case 0x01:
+		n = 4
+	case 0x04:
+		n = 16
+	case 0x03:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		n = int(l[0])
+	default:
+		return errProxyProtocol
+	}
+	_, err := io.CopyN(io.Discard, conn, int64(n+2))
+	return err
+}
+
+// socks5Errno 将 SOCKS5 的应答码映射到对应的系统错误。
+func socks5Errno(rep byte) syscall.Errno {
+	switch rep {
+	case 0x02:
+		return syscall.EACCES
+	case 0x03:
+		return syscall.ENETUNREACH
+	case 0x04:
+		return syscall.EHOSTUNREACH
+	case 0x06:
+		return syscall.ETIMEDOUT
+	case 0x07, 0x08:
+		return syscall.EOPNOTSUPP
+	default:
+		return syscall.ECONNREFUSED
+	}
+}
+
+const maxConnectResponse = 8 << 10 // CONNECT 响应头的最大长度
+
+// httpConnect 发送 HTTP CONNECT 请求并读取响应头。
+func (p *Proxy) httpConnect(conn net.Conn, remote netip.AddrPort) error {
+	target := remote.String()
+	req := "CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n"
+	if u := p.url.User; u != nil {
+		pass, _ := u.Password()
+		req += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+pass)) + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return err
+	}
+
+	// 逐字节读取响应头，避免读走隧道中的数据
+	var head []byte
+	var b [1]byte
+	for !bytes.HasSuffix(head, []byte("\r\n\r\n")) {
+		if len(head) >= maxConnectResponse {
+			return errProxyProtocol
+		}
+		if _, err := io.ReadFull(conn, b[:]); err != nil {
+			return err
+		}
+		head = append(head, b[0])
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(head)), nil)
+	if err != nil {
+		return errors.Join(errProxyProtocol, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return fmt.Errorf("proxy CONNECT failed with %s: %w", resp.Status, httpConnectErrno(resp.StatusCode))
+}
+
+// httpConnectErrno 将 CONNECT 响应的状态码映射到对应的系统错误。
+func httpConnectErrno(status int) syscall.Errno {
+	switch status {
+	case http.StatusForbidden, http.StatusProxyAuthRequired:
+		return syscall.EACCES
+	case http.StatusGatewayTimeout:
+		return syscall.ETIMEDOUT
+	default:
+		return syscall.ECONNREFUSED
+	}
+}
@@ -0,0 +1,168 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver 解析域名，wasi:sockets 的 resolve-addresses 通过它查询 DNS。
+// network 为 "ip"、"ip4" 或 "ip6"，*net.Resolver 满足这个接口，net.DefaultResolver 即系统解析器。
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// NewDNSResolver 返回一个只向 servers 查询的解析器，不读取系统的 DNS 配置。
+// servers 中的地址没有端口时使用 53 端口，查询失败时依次尝试下一个服务器。
+func NewDNSResolver(servers ...string) *net.Resolver {
+	addrs := make([]string, len(servers))
+	for i, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		addrs[i] = server
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			err := error(&net.DNSError{Err: "no dns servers", IsTemporary: true})
+			for _, addr := range addrs {
+				var conn net.Conn
+				if conn, err = d.DialContext(ctx, network, addr); err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		},
+	}
+}
+
+// HostsResolver 使用静态的域名表解析，表中没有的域名交给 Fallback，Fallback 为 nil 时返回找不到域名。
+type HostsResolver struct {
+	hosts    map[string][]netip.Addr
+	Fallback Resolver
+}
+
+// NewHostsResolver 创建一个静态解析器，域名不区分大小写，末尾的点会被忽略。
+func NewHostsResolver(hosts map[string][]netip.Addr) *HostsResolver {
+	r := &HostsResolver{hosts: make(map[string][]netip.Addr, len(hosts))}
+	for name, addrs := range hosts {
+		name = normalizeHost(name)
+		r.hosts[name] = append(r.hosts[name], addrs...)
+	}
+	return r
+}
+
+func (r *HostsResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, ok := r.hosts[normalizeHost(host)]
+	if !ok {
+		if r.Fallback != nil {
+			return r.Fallback.LookupNetIP(ctx, network, host)
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	var result []netip.Addr
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if (network == "ip4" && !addr.Is4()) || (network == "ip6" && !addr.Is6()) {
+			continue
+		}
+		result = append(result, addr)
+	}
+	if len(result) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return result, nil
+}
+
+const maxCachedNames = 1024 // 缓存超过这么多域名时清理已过期的条目
+
+// CachingResolver 缓存另一个解析器的结果。
+type CachingResolver struct {
+	resolver    Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	addrs   []netip.Addr
+	err     error
+	expires time.Time
+}
+
+// NewCachingResolver 创建一个缓存解析器。Go 的解析器不提供记录的 TTL，
+// 成功的结果缓存 ttl，找不到域名的结果缓存 negativeTTL，为零时不缓存。
+func NewCachingResolver(resolver Resolver, ttl, negativeTTL time.Duration) *CachingResolver {
+	return &CachingResolver{
+		resolver:    resolver,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+func (r *CachingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	key := network + "/" + normalizeHost(host)
+
+	r.mutex.Lock()
+	entry, ok := r.entries[key]
+	if ok && time.Now().Before(entry.expires) {
+		r.mutex.Unlock()
+		return slices.Clone(entry.addrs), entry.err
+	}
+	r.mutex.Unlock()
+
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+	ttl := r.ttl
+	if err != nil {
+		// 只缓存确定的结果，临时故障和被取消的查询下次重试
+		ttl = 0
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			ttl = r.negativeTTL
+		}
+	}
+	if ttl > 0 {
+		r.mutex.Lock()
+		if len(r.entries) >= maxCachedNames {
+			r.purge()
+		}
+		r.entries[key] = cacheEntry{addrs: slices.Clone(addrs), err: err, expires: time.Now().Add(ttl)}
+		r.mutex.Unlock()
+	}
+	return addrs, err
+}
+
+// Flush 清空缓存。
+func (r *CachingResolver) Flush() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	clear(r.entries)
+}
+
+// purge 删除已过期的条目，仍然太多时清空缓存，调用方需要持有锁。
+func (r *CachingResolver) purge() {
+	now := time.Now()
+	for key, entry := range r.entries {
+		if !now.Before(entry.expires) {
+			delete(r.entries, key)
+		}
+	}
+	if len(r.entries) >= maxCachedNames {
+		clear(r.entries)
+	}
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
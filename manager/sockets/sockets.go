@@ -1,7 +1,10 @@
 package sockets
 
 import (
+	"context"
+	"errors"
 	"net"
+	"sync/atomic"
 
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
 )
@@ -51,28 +54,116 @@ type OutgoingDatagram struct {
 	RemoteAddress witgo.Option[IPSocketAddress]
 }
 
-// Network represents the capability to access the network.
-// In this implementation, it's a placeholder struct.
-type Network struct{}
+// Network 代表访问网络的能力，Policy 限制通过它可以进行的访问。
+type Network struct {
+	Policy *NetworkPolicy
+	// Proxy 不为 nil 时 TCP 连接经过它建立
+	Proxy *Proxy
+}
+
+// TCPConn 是 TCP 套接字使用的连接，*net.TCPConn 和虚拟网络中的 *VirtualConn 都实现了它。
+type TCPConn interface {
+	net.Conn
+	CloseRead() error
+	CloseWrite() error
+}
+
+// UDPConn 是 UDP 套接字使用的连接，*net.UDPConn 和虚拟网络中的 *VirtualPacketConn 都实现了它。
+type UDPConn interface {
+	net.PacketConn
+	net.Conn
+}
 
 // ConnectResult 用于在 goroutine 之间传递异步连接的结果。
 type ConnectResult struct {
-	Conn *net.TCPConn
+	Conn TCPConn
 	Err  error
 }
 
 // TCPSocket 代表一个 TCP 套接字资源。
 type TCPSocket struct {
 	Fd       int
-	Listener *net.TCPListener
-	Conn     *net.TCPConn
+	Listener net.Listener
+	Conn     TCPConn
 	Family   IPAddressFamily
 	State    TCPState
 
+	// Policy 是绑定或连接时所用 network 的访问策略。
+	Policy *NetworkPolicy
+	// ListenBacklog 是 Guest 通过 set-listen-backlog-size 设置的队列长度，零值表示使用系统默认值。
+	ListenBacklog int
+	// Acceptor 在 start-listen 之后于后台接受连接，accept 从它的队列中非阻塞地取出连接。
+	Acceptor *AsyncAcceptor
+	// Options 记录连接建立之前设置的套接字选项。
+	// 在不能直接使用 Fd 发起连接的平台上，连接时把它们重新设置到新的套接字上。
+	Options []SocketOption
+
 	// ConnectResult 用于异步 connect 操作。
 	// 当 start-connect 被调用时，一个 goroutine 会开始连接，
 	// 并将结果（一个 ConnectResult）发送到这个 channel。
 	ConnectResult chan ConnectResult
+	// CancelConnect 取消进行中的 connect。
+	CancelConnect context.CancelFunc
+
+	// BytesRead 和 BytesWritten 是连接建立之后通过输入流和输出流收发的字节数。
+	BytesRead    atomic.Uint64
+	BytesWritten atomic.Uint64
+}
+
+// FinishConnect 在取得 connect 的结果后调用，释放 connect 使用的资源。
+func (s *TCPSocket) FinishConnect() {
+	if s.CancelConnect != nil {
+		s.CancelConnect()
+		s.CancelConnect = nil
+	}
+	s.ConnectResult = nil
+}
+
+// CloseFd 关闭还没有连接的套接字占用的系统套接字和绑定，连接改由 Host 另行建立时使用。
+func (s *TCPSocket) CloseFd() error {
+	var errs []error
+	if s.Fd != 0 {
+		errs = append(errs, closeFd(s.Fd))
+		s.Fd = 0
+	}
+	if s.Listener != nil {
+		errs = append(errs, s.Listener.Close())
+		s.Listener = nil
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭套接字持有的文件描述符、连接和监听器。重复调用是安全的。
+func (s *TCPSocket) Close() error {
+	if results := s.ConnectResult; results != nil {
+		s.CancelConnect()
+		// 连接可能在取消之前就已经建立，等结果送达后关闭它
+		go func() {
+			if result := <-results; result.Conn != nil {
+				result.Conn.Close()
+			}
+		}()
+		s.ConnectResult, s.CancelConnect = nil, nil
+	}
+
+	if s.Acceptor != nil {
+		s.Acceptor.Close()
+		s.Acceptor = nil
+	}
+
+	var errs []error
+	if s.Conn != nil {
+		errs = append(errs, s.Conn.Close())
+		s.Conn = nil
+	}
+	errs = append(errs, s.CloseFd())
+	s.State = TCPStateClosed
+	return errors.Join(errs...)
+}
+
+// SocketOption 是一个整数类型的套接字选项。
+type SocketOption struct {
+	Level, Name, Value int
 }
 
 // TCPState represents the state of a TCP socket as defined in the WIT world.
@@ -90,16 +181,40 @@ const (
 // UDPSocket represents a UDP socket resource.
 type UDPSocket struct {
 	Fd int
-	// The Go standard library UDP connection.
-	Conn *net.UDPConn
+	// The Go standard library UDP connection, or a virtual one.
+	Conn UDPConn
 	// The address family of the socket.
 	Family IPAddressFamily
+	// Policy 是绑定时所用 network 的访问策略。
+	Policy *NetworkPolicy
 
 	// 新增字段
 	Reader *AsyncUDPReader
 	Writer *AsyncUDPWriter
 }
 
+// Close 停止数据报流并关闭底层连接。重复调用是安全的。
+func (s *UDPSocket) Close() error {
+	if s.Reader != nil {
+		s.Reader.Close()
+		s.Reader = nil
+	}
+	if s.Writer != nil {
+		s.Writer.Close()
+		s.Writer = nil
+	}
+
+	var err error
+	if s.Conn != nil {
+		err = s.Conn.Close()
+		s.Conn = nil
+	} else if s.Fd != 0 {
+		err = closeFd(s.Fd)
+	}
+	s.Fd = 0
+	return err
+}
+
 // ResolveAddressStreamState 保存了域名解析操作的状态。
 type ResolveAddressStreamState struct {
 	// 存储解析出的 IP 地址列表。
@@ -110,6 +225,15 @@ type ResolveAddressStreamState struct {
 	Error error
 	// 一个 channel，当后台解析任务完成时，它会被关闭。
 	Done chan struct{}
+	// Cancel 取消进行中的解析，资源被丢弃时调用。
+	Cancel context.CancelFunc
+}
+
+func (s *ResolveAddressStreamState) Close() error {
+	if s.Cancel != nil {
+		s.Cancel()
+	}
+	return nil
 }
 
 // --- Resource Managers ---
@@ -123,11 +247,17 @@ func NewNetworkManager() *NetworkManager {
 	return witgo.NewResourceManager[*Network](nil)
 }
 func NewTCPSocketManager() *TCPSocketManager {
-	return witgo.NewResourceManager[*TCPSocket](nil)
+	return witgo.NewResourceManager[*TCPSocket](func(resource *TCPSocket) {
+		resource.Close()
+	})
 }
 func NewUDPSocketManager() *UDPSocketManager {
-	return witgo.NewResourceManager[*UDPSocket](nil)
+	return witgo.NewResourceManager[*UDPSocket](func(resource *UDPSocket) {
+		resource.Close()
+	})
 }
 func NewResolveAddressStreamManager() *ResolveAddressStreamManager {
-	return witgo.NewResourceManager[*ResolveAddressStreamState](nil)
+	return witgo.NewResourceManager[*ResolveAddressStreamState](func(resource *ResolveAddressStreamState) {
+		resource.Close()
+	})
 }
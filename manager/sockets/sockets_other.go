@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package sockets
+
+// 其他平台上不直接持有文件描述符。
+func closeFd(fd int) error {
+	return nil
+}
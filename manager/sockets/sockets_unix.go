@@ -0,0 +1,9 @@
+//go:build unix
+
+package sockets
+
+import "golang.org/x/sys/unix"
+
+func closeFd(fd int) error {
+	return unix.Close(fd)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package sockets
+
+import "golang.org/x/sys/windows"
+
+func closeFd(fd int) error {
+	return windows.Close(windows.Handle(fd))
+}
@@ -0,0 +1,722 @@
+package sockets
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	ephemeralPortMin = 49152
+	ephemeralPortMax = 65535
+
+	defaultVirtualBacklog    = 128       // 未指定时虚拟监听器最多排队 128 个连接
+	defaultVirtualPipeSize   = 64 * 1024 // 虚拟连接每个方向最多缓冲 64KB 未读取的数据
+	defaultVirtualPacketSize = 256       // 虚拟 UDP 套接字最多缓冲 256 个未读取的数据报
+)
+
+// VirtualNetwork 是一个完全在内存中实现的网络，其中的套接字不使用任何系统资源。
+// 任何 IP 和端口都可以绑定，连接和数据报只能到达同一个 VirtualNetwork 中绑定的地址。
+//
+// 通过 wasip2.WithVirtualNetwork 让 Guest 的 wasi:sockets 使用它，
+// Go 代码可以通过 Listen、Dial 和 ListenPacket 接入同一个网络，与 Guest 互相通信。
+type VirtualNetwork struct {
+	mu        sync.Mutex
+	listeners map[netip.AddrPort]*VirtualListener
+	packets   map[netip.AddrPort]*VirtualPacketConn
+	nextPort  uint16
+}
+
+func NewVirtualNetwork() *VirtualNetwork {
+	return &VirtualNetwork{
+		listeners: make(map[netip.AddrPort]*VirtualListener),
+		packets:   make(map[netip.AddrPort]*VirtualPacketConn),
+		nextPort:  ephemeralPortMin,
+	}
+}
+
+// Listen 在虚拟网络中监听 TCP 地址，network 必须是 "tcp"、"tcp4" 或 "tcp6"。
+// address 的主机部分必须是 IP 或 localhost，为空时监听所有地址，端口为 0 时自动分配。
+func (n *VirtualNetwork) Listen(network, address string) (net.Listener, error) {
+	addr, err := parseVirtualAddress("listen", network, "tcp", address, false)
+	if err != nil {
+		return nil, err
+	}
+	l, err := n.BindTCP(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.Listen(0); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Dial 连接到虚拟网络中的 TCP 地址。
+func (n *VirtualNetwork) Dial(network, address string) (net.Conn, error) {
+	return n.DialContext(context.Background(), network, address)
+}
+
+// DialContext 连接到虚拟网络中的 TCP 地址，可以直接用作 http.Transport 的 DialContext。
+func (n *VirtualNetwork) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	addr, err := parseVirtualAddress("dial", network, "tcp", address, true)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := n.DialTCP(ctx, netip.AddrPort{}, addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ListenPacket 在虚拟网络中绑定 UDP 地址，network 必须是 "udp"、"udp4" 或 "udp6"。
+func (n *VirtualNetwork) ListenPacket(network, address string) (net.PacketConn, error) {
+	addr, err := parseVirtualAddress("listen", network, "udp", address, false)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := n.BindUDP(addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// BindTCP 占用一个 TCP 地址，端口为 0 时自动分配。
+// 返回的监听器在调用 Listen 之前拒绝所有连接。
+func (n *VirtualNetwork) BindTCP(addr netip.AddrPort) (*VirtualListener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	addr, err := bindAddress(n, n.listeners, addr)
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "tcp", Addr: net.TCPAddrFromAddrPort(addr), Err: err}
+	}
+	l := &VirtualListener{network: n, addr: addr}
+	l.cond = sync.NewCond(&l.mu)
+	n.listeners[addr] = l
+	return l, nil
+}
+
+// DialTCP 从 local 连接到 remote，local 无效时使用回环地址和一个临时端口。
+func (n *VirtualNetwork) DialTCP(ctx context.Context, local, remote netip.AddrPort) (*VirtualConn, error) {
+	remote = unmapAddrPort(remote)
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Addr: net.TCPAddrFromAddrPort(remote), Err: err}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, opErr(err)
+	}
+
+	n.mu.Lock()
+	l := lookupAddress(n.listeners, remote)
+	if !local.IsValid() {
+		local = netip.AddrPortFrom(loopback(remote.Addr()), ephemeralPort(n, n.listeners, remote.Addr()))
+	} else if local = unmapAddrPort(local); local.Addr().IsUnspecified() {
+		local = netip.AddrPortFrom(loopback(local.Addr()), local.Port())
+	}
+	n.mu.Unlock()
+
+	if l == nil {
+		return nil, opErr(syscall.ECONNREFUSED)
+	}
+	client, server := newVirtualConnPair(local, remote)
+	if err := l.enqueue(server); err != nil {
+		return nil, opErr(err)
+	}
+	return client, nil
+}
+
+// BindUDP 占用一个 UDP 地址，端口为 0 时自动分配。
+func (n *VirtualNetwork) BindUDP(addr netip.AddrPort) (*VirtualPacketConn, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	addr, err := bindAddress(n, n.packets, addr)
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "udp", Addr: net.UDPAddrFromAddrPort(addr), Err: err}
+	}
+	c := &VirtualPacketConn{network: n, addr: addr}
+	c.cond = sync.NewCond(&c.mu)
+	n.packets[addr] = c
+	return c, nil
+}
+
+func (n *VirtualNetwork) releaseTCP(l *VirtualListener) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.listeners[l.addr] == l {
+		delete(n.listeners, l.addr)
+	}
+}
+
+func (n *VirtualNetwork) releaseUDP(c *VirtualPacketConn) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.packets[c.addr] == c {
+		delete(n.packets, c.addr)
+	}
+}
+
+// ephemeralPort 分配一个在 bound 中没有被 addr 占用的临时端口，没有空闲端口时返回 0。调用者必须持有 n.mu。
+func ephemeralPort[T any](n *VirtualNetwork, bound map[netip.AddrPort]T, addr netip.Addr) uint16 {
+	for range ephemeralPortMax - ephemeralPortMin + 1 {
+		port := n.nextPort
+		if n.nextPort == ephemeralPortMax {
+			n.nextPort = ephemeralPortMin
+		} else {
+			n.nextPort++
+		}
+		if !inUse(bound, netip.AddrPortFrom(addr, port)) {
+			return port
+		}
+	}
+	return 0
+}
+
+// bindAddress 检查 addr 是否可以绑定，端口为 0 时分配一个临时端口。调用者必须持有 n.mu。
+func bindAddress[T any](n *VirtualNetwork, bound map[netip.AddrPort]T, addr netip.AddrPort) (netip.AddrPort, error) {
+	addr = unmapAddrPort(addr)
+	if !addr.Addr().IsValid() {
+		return addr, syscall.EINVAL
+	}
+	if addr.Port() == 0 {
+		port := ephemeralPort(n, bound, addr.Addr())
+		if port == 0 {
+			return addr, syscall.EADDRINUSE
+		}
+		return netip.AddrPortFrom(addr.Addr(), port), nil
+	}
+	if inUse(bound, addr) {
+		return addr, syscall.EADDRINUSE
+	}
+	return addr, nil
+}
+
+// inUse 判断 addr 是否与 bound 中已绑定的地址冲突。监听所有地址的套接字与同一地址族中相同端口的套接字冲突。
+func inUse[T any](bound map[netip.AddrPort]T, addr netip.AddrPort) bool {
+	if _, ok := bound[addr]; ok {
+		return true
+	}
+	if _, ok := bound[netip.AddrPortFrom(unspecified(addr.Addr()), addr.Port())]; ok {
+		return true
+	}
+	if addr.Addr().IsUnspecified() {
+		for other := range bound {
+			if other.Port() == addr.Port() && other.Addr().Is4() == addr.Addr().Is4() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupAddress 查找接收发往 addr 的连接或数据报的套接字，调用者必须持有 n.mu。
+func lookupAddress[T any](bound map[netip.AddrPort]*T, addr netip.AddrPort) *T {
+	if v, ok := bound[addr]; ok {
+		return v
+	}
+	return bound[netip.AddrPortFrom(unspecified(addr.Addr()), addr.Port())]
+}
+
+func unmapAddrPort(addr netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+}
+
+func unspecified(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		return netip.IPv4Unspecified()
+	}
+	return netip.IPv6Unspecified()
+}
+
+func loopback(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		return netip.AddrFrom4([4]byte{127, 0, 0, 1})
+	}
+	return netip.IPv6Loopback()
+}
+
+// parseVirtualAddress 解析 Go 代码传入的地址，dial 为 true 时空主机表示回环地址。
+func parseVirtualAddress(op, network, proto, address string, dial bool) (netip.AddrPort, error) {
+	opErr := func(err error) error {
+		return &net.OpError{Op: op, Net: network, Err: err}
+	}
+	if network != proto && network != proto+"4" && network != proto+"6" {
+		return netip.AddrPort{}, opErr(net.UnknownNetworkError(network))
+	}
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return netip.AddrPort{}, opErr(err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, opErr(&net.AddrError{Err: "invalid port", Addr: address})
+	}
+
+	ipv6 := strings.HasSuffix(network, "6")
+	var ip netip.Addr
+	switch host {
+	case "":
+		ip = netip.IPv4Unspecified()
+		if ipv6 {
+			ip = netip.IPv6Unspecified()
+		}
+		if dial {
+			ip = loopback(ip)
+		}
+	case "localhost":
+		ip = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+		if ipv6 {
+			ip = netip.IPv6Loopback()
+		}
+	default:
+		ip, err = netip.ParseAddr(host)
+		if err != nil {
+			return netip.AddrPort{}, opErr(&net.AddrError{Err: "virtual network only supports ip addresses", Addr: host})
+		}
+	}
+	return netip.AddrPortFrom(ip.Unmap(), uint16(port)), nil
+}
+
+// --- Virtual TCP ---
+
+// VirtualListener 是虚拟网络中的 TCP 监听器，实现了 net.Listener。
+type VirtualListener struct {
+	network *VirtualNetwork
+	addr    netip.AddrPort
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queue     []*VirtualConn
+	backlog   int
+	listening bool
+	closed    bool
+}
+
+// Listen 开始接受连接，已经在监听时只更新队列长度。backlog 不大于 0 时使用默认值。
+func (l *VirtualListener) Listen(backlog int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return &net.OpError{Op: "listen", Net: "tcp", Addr: l.Addr(), Err: net.ErrClosed}
+	}
+	if backlog <= 0 {
+		backlog = defaultVirtualBacklog
+	}
+	l.backlog = backlog
+	l.listening = true
+	return nil
+}
+
+// enqueue 将新的连接放入等待 accept 的队列，没有在监听或队列已满时拒绝连接。
+func (l *VirtualListener) enqueue(conn *VirtualConn) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed || !l.listening || len(l.queue) >= l.backlog {
+		return syscall.ECONNREFUSED
+	}
+	l.queue = append(l.queue, conn)
+	l.cond.Signal()
+	return nil
+}
+
+// Accept 阻塞直到有新的连接或监听器被关闭。
+func (l *VirtualListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.queue) == 0 && !l.closed {
+		l.cond.Wait()
+	}
+	if l.closed {
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Addr: l.Addr(), Err: net.ErrClosed}
+	}
+	conn := l.queue[0]
+	l.queue[0] = nil
+	l.queue = l.queue[1:]
+	return conn, nil
+}
+
+// Close 停止监听并释放地址，还没有被 accept 的连接会被关闭。
+func (l *VirtualListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	queue := l.queue
+	l.queue = nil
+	l.cond.Broadcast()
+	l.mu.Unlock()
+
+	for _, conn := range queue {
+		conn.Close()
+	}
+	l.network.releaseTCP(l)
+	return nil
+}
+
+func (l *VirtualListener) Addr() net.Addr {
+	return net.TCPAddrFromAddrPort(l.addr)
+}
+
+// AddrPort 返回监听器绑定的地址。
+func (l *VirtualListener) AddrPort() netip.AddrPort {
+	return l.addr
+}
+
+// VirtualConn 是虚拟网络中的 TCP 连接，实现了 TCPConn。
+type VirtualConn struct {
+	local, remote netip.AddrPort
+	rd, wr        *virtualPipe
+	closed        atomic.Bool
+}
+
+// newVirtualConnPair 创建一对互相连接的虚拟连接。
+func newVirtualConnPair(client, server netip.AddrPort) (*VirtualConn, *VirtualConn) {
+	up, down := newVirtualPipe(), newVirtualPipe()
+	return &VirtualConn{local: client, remote: server, rd: down, wr: up},
+		&VirtualConn{local: server, remote: client, rd: up, wr: down}
+}
+
+func (c *VirtualConn) Read(b []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, c.opError("read", net.ErrClosed)
+	}
+	n, err := c.rd.read(b)
+	if err != nil && err != io.EOF {
+		err = c.opError("read", err)
+	}
+	return n, err
+}
+
+func (c *VirtualConn) Write(b []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, c.opError("write", net.ErrClosed)
+	}
+	n, err := c.wr.write(b)
+	if err != nil {
+		err = c.opError("write", err)
+	}
+	return n, err
+}
+
+// CloseRead 关闭读方向，对端之后的写入会失败。
+func (c *VirtualConn) CloseRead() error {
+	c.rd.closeRead()
+	return nil
+}
+
+// CloseWrite 关闭写方向，对端读完已写入的数据后读到 EOF。
+func (c *VirtualConn) CloseWrite() error {
+	c.wr.closeWrite()
+	return nil
+}
+
+func (c *VirtualConn) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	c.rd.closeRead()
+	c.wr.closeWrite()
+	return nil
+}
+
+func (c *VirtualConn) LocalAddr() net.Addr {
+	return net.TCPAddrFromAddrPort(c.local)
+}
+
+func (c *VirtualConn) RemoteAddr() net.Addr {
+	return net.TCPAddrFromAddrPort(c.remote)
+}
+
+func (c *VirtualConn) SetDeadline(t time.Time) error {
+	c.rd.setReadDeadline(t)
+	c.wr.setWriteDeadline(t)
+	return nil
+}
+
+func (c *VirtualConn) SetReadDeadline(t time.Time) error {
+	c.rd.setReadDeadline(t)
+	return nil
+}
+
+func (c *VirtualConn) SetWriteDeadline(t time.Time) error {
+	c.wr.setWriteDeadline(t)
+	return nil
+}
+
+func (c *VirtualConn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: "tcp", Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: err}
+}
+
+// virtualPipe 是虚拟连接中一个方向的有界缓冲区，缓冲区满时写入阻塞。
+type virtualPipe struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	buf         []byte
+	readClosed  bool
+	writeClosed bool
+	rdeadline   deadline
+	wdeadline   deadline
+}
+
+func newVirtualPipe() *virtualPipe {
+	p := &virtualPipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *virtualPipe) read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		switch {
+		case p.readClosed:
+			return 0, io.EOF
+		case len(p.buf) > 0:
+			n := copy(b, p.buf)
+			p.buf = p.buf[n:]
+			if len(p.buf) == 0 {
+				p.buf = nil
+			}
+			p.cond.Broadcast()
+			return n, nil
+		case p.writeClosed:
+			return 0, io.EOF
+		case p.rdeadline.exceeded():
+			return 0, os.ErrDeadlineExceeded
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *virtualPipe) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	written := 0
+	for written < len(b) {
+		switch {
+		case p.writeClosed, p.readClosed:
+			return written, syscall.EPIPE
+		case p.wdeadline.exceeded():
+			return written, os.ErrDeadlineExceeded
+		}
+		space := defaultVirtualPipeSize - len(p.buf)
+		if space <= 0 {
+			p.cond.Wait()
+			continue
+		}
+		n := min(space, len(b)-written)
+		p.buf = append(p.buf, b[written:written+n]...)
+		written += n
+		p.cond.Broadcast()
+	}
+	return written, nil
+}
+
+func (p *virtualPipe) closeRead() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readClosed = true
+	p.buf = nil
+	p.cond.Broadcast()
+}
+
+func (p *virtualPipe) closeWrite() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.writeClosed = true
+	p.cond.Broadcast()
+}
+
+func (p *virtualPipe) setReadDeadline(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rdeadline.set(t, p.cond)
+}
+
+func (p *virtualPipe) setWriteDeadline(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wdeadline.set(t, p.cond)
+}
+
+// deadline 在到期时唤醒所有等待在 cond 上的 goroutine，由它们自行检查是否超时。
+type deadline struct {
+	t     time.Time
+	timer *time.Timer
+}
+
+// set 更新期限，调用者必须持有 cond.L。
+func (d *deadline) set(t time.Time, cond *sync.Cond) {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.t = t
+	if t.IsZero() {
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		d.timer = time.AfterFunc(dur, func() {
+			cond.L.Lock()
+			cond.Broadcast()
+			cond.L.Unlock()
+		})
+		return
+	}
+	cond.Broadcast()
+}
+
+func (d *deadline) exceeded() bool {
+	return !d.t.IsZero() && !time.Now().Before(d.t)
+}
+
+// --- Virtual UDP ---
+
+type virtualDatagram struct {
+	data   []byte
+	remote netip.AddrPort
+}
+
+// VirtualPacketConn 是虚拟网络中的 UDP 套接字，实现了 UDPConn。
+// 发往没有绑定的地址或者接收队列已满的数据报会被丢弃。
+type VirtualPacketConn struct {
+	network *VirtualNetwork
+	addr    netip.AddrPort
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queue     []virtualDatagram
+	closed    bool
+	rdeadline deadline
+}
+
+func (c *VirtualPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		switch {
+		case c.closed:
+			return 0, nil, c.opError("read", nil, net.ErrClosed)
+		case len(c.queue) > 0:
+			dg := c.queue[0]
+			c.queue[0] = virtualDatagram{}
+			c.queue = c.queue[1:]
+			return copy(b, dg.data), net.UDPAddrFromAddrPort(dg.remote), nil
+		case c.rdeadline.exceeded():
+			return 0, nil, c.opError("read", nil, os.ErrDeadlineExceeded)
+		}
+		c.cond.Wait()
+	}
+}
+
+func (c *VirtualPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	var remote netip.AddrPort
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		remote = a.AddrPort()
+	default:
+		var err error
+		if remote, err = netip.ParseAddrPort(addr.String()); err != nil {
+			return 0, c.opError("write", addr, &net.AddrError{Err: "unsupported address", Addr: addr.String()})
+		}
+	}
+	remote = unmapAddrPort(remote)
+
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return 0, c.opError("write", addr, net.ErrClosed)
+	}
+
+	source := c.addr
+	if source.Addr().IsUnspecified() {
+		source = netip.AddrPortFrom(loopback(source.Addr()), source.Port())
+	}
+	c.network.mu.Lock()
+	dst := lookupAddress(c.network.packets, remote)
+	c.network.mu.Unlock()
+	if dst != nil {
+		dst.deliver(virtualDatagram{data: append([]byte(nil), b...), remote: source})
+	}
+	return len(b), nil
+}
+
+func (c *VirtualPacketConn) deliver(dg virtualDatagram) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.queue) >= defaultVirtualPacketSize {
+		return
+	}
+	c.queue = append(c.queue, dg)
+	c.cond.Signal()
+}
+
+// Read 读取下一个数据报，丢弃它的来源地址。
+func (c *VirtualPacketConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFrom(b)
+	return n, err
+}
+
+// Write 总是失败，虚拟 UDP 套接字没有默认的远端地址，发送时必须指定地址。
+func (c *VirtualPacketConn) Write(b []byte) (int, error) {
+	return 0, c.opError("write", nil, syscall.EDESTADDRREQ)
+}
+
+func (c *VirtualPacketConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.queue = nil
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	c.network.releaseUDP(c)
+	return nil
+}
+
+func (c *VirtualPacketConn) LocalAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(c.addr)
+}
+
+// RemoteAddr 总是返回 nil。
+func (c *VirtualPacketConn) RemoteAddr() net.Addr {
+	return nil
+}
+
+func (c *VirtualPacketConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *VirtualPacketConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rdeadline.set(t, c.cond)
+	return nil
+}
+
+// SetWriteDeadline 不做任何事，虚拟 UDP 套接字的写入从不阻塞。
+func (c *VirtualPacketConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *VirtualPacketConn) opError(op string, addr net.Addr, err error) error {
+	return &net.OpError{Op: op, Net: "udp", Source: c.LocalAddr(), Addr: addr, Err: err}
+}
@@ -1,7 +1,9 @@
 package tls
 
 import (
+	"context"
 	"crypto/tls"
+	"sync"
 	"sync/atomic"
 
 	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
@@ -32,13 +34,44 @@ func (c *ClientConnection) Close() error {
 }
 
 // FutureClientStreams 代表一个尚未完成的 TLS 握手，最终会产生加密流。
+// 后台的握手通过 SetResult 设置结果，Guest 在 Pollable 就绪后读取 Result。
 type FutureClientStreams struct {
 	Pollable *manager_io.ChannelPollable
 	Result   Result
 	Consumed atomic.Bool
+
+	// Cancel 中止进行中的握手，future 被丢弃时调用。
+	Cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// SetResult 设置握手的结果。future 已经被丢弃时，迟到的连接会被直接关闭。
+func (c *FutureClientStreams) SetResult(result Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		if result.TlsConn != nil {
+			result.TlsConn.Close()
+		}
+		return
+	}
+	c.Result = result
+	c.Pollable.SetReady()
 }
 
 func (c *FutureClientStreams) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.Consumed.Load() {
+		c.closed = true
+		return nil
+	}
+	c.closed = true
+	if c.Cancel != nil {
+		c.Cancel()
+	}
 	if c.Result.TlsConn != nil {
 		return c.Result.TlsConn.Close()
 	}
@@ -72,3 +105,10 @@ func NewTLSManager() *TLSManager {
 		}),
 	}
 }
+
+// Clear 释放所有 TLS 资源。
+func (tm *TLSManager) Clear() {
+	tm.FutureClientStreams.Clear()
+	tm.ClientConnections.Clear()
+	tm.ClientHandshakes.Clear()
+}
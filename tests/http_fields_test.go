@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"testing"
+
+	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPFields(t *testing.T) {
+	f := manager_http.NewFields()
+	f.Append("X-Zeta", "1")
+	f.Append("aLpHa", "2")
+	f.Append("x-zeta", "3")
+
+	// 查找不区分大小写
+	require.Equal(t, []string{"1", "3"}, f.Get("X-ZETA"))
+	require.True(t, f.Has("alpha"))
+	require.False(t, f.Has("beta"))
+
+	// Set 替换所有同名条目，并保持第一个条目的位置
+	f.Set("X-Zeta", []string{"4"})
+	require.Equal(t, []manager_http.Field{
+		{Name: "X-Zeta", Value: "4"},
+		{Name: "aLpHa", Value: "2"},
+	}, f.Entries())
+
+	clone := f.Clone()
+	clone.Delete("ALPHA")
+	require.Equal(t, []string{"2"}, f.Get("alpha"))
+	require.Empty(t, clone.Get("alpha"))
+
+	require.Equal(t, []string{"4"}, f.Header()["X-Zeta"])
+	require.Equal(t, uint64(5), *manager_http.ContentLength(manager_http.FieldsFromHeader(map[string][]string{"Content-Length": {"5"}})))
+}
+
+func TestHTTPFieldsImmutable(t *testing.T) {
+	fm := manager_http.NewFieldsManager()
+	mutable := fm.Add(manager_http.NewFields())
+	immutable := fm.AddImmutable(manager_http.NewFields())
+	require.False(t, fm.IsImmutable(mutable))
+	require.True(t, fm.IsImmutable(immutable))
+
+	// 移除句柄时同时清除不可变标记
+	_, ok := fm.Pop(immutable)
+	require.True(t, ok)
+	require.False(t, fm.IsImmutable(immutable))
+
+	immutable = fm.AddImmutable(manager_http.NewFields())
+	require.True(t, fm.Remove(immutable))
+	require.False(t, fm.IsImmutable(immutable))
+}
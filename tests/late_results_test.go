@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
+	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
+	manager_sockets "github.com/OpenListTeam/wazero-wasip2/manager/sockets"
+	manager_tls "github.com/OpenListTeam/wazero-wasip2/manager/tls"
+
+	"github.com/stretchr/testify/require"
+)
+
+// closeRecorder 记录 body 是否被关闭。
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// requireClosedByPeer 检查 conn 的对端在超时之前关闭了连接。
+func requireClosedByPeer(t *testing.T, conn net.Conn) {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err := io.Copy(io.Discard, conn)
+	require.NoError(t, err)
+}
+
+// tcpPair 返回一对通过回环地址连接的 TCP 连接。
+func tcpPair(t *testing.T) (*net.TCPConn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	server, err := listener.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client.(*net.TCPConn), server
+}
+
+func TestLateResultsAreClosed(t *testing.T) {
+	t.Run("http response", func(t *testing.T) {
+		var cancelled bool
+		future := &manager_http.FutureIncomingResponse{
+			Pollable: manager_io.NewPollable(nil),
+			Cancel:   func() { cancelled = true },
+		}
+		require.NoError(t, future.Close())
+		require.True(t, cancelled)
+
+		// 取消之前已经发出的请求返回的响应被直接关闭
+		body := &closeRecorder{Reader: strings.NewReader("late")}
+		future.SetResult(manager_http.Result{Response: &http.Response{Body: body}})
+		require.True(t, body.closed)
+		require.False(t, future.Pollable.IsReady())
+	})
+
+	t.Run("tls connection", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		future := &manager_tls.FutureClientStreams{
+			Pollable: manager_io.NewPollable(nil),
+			Cancel:   cancel,
+		}
+		require.NoError(t, future.Close())
+		require.Error(t, ctx.Err())
+
+		client, server := tcpPair(t)
+		future.SetResult(manager_tls.Result{TlsConn: tls.Client(client, &tls.Config{})})
+		require.False(t, future.Pollable.IsReady())
+		requireClosedByPeer(t, server)
+	})
+
+	t.Run("tcp connection", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		results := make(chan manager_sockets.ConnectResult, 1)
+		sock := &manager_sockets.TCPSocket{
+			State:         manager_sockets.TCPStateConnecting,
+			ConnectResult: results,
+			CancelConnect: cancel,
+		}
+		require.NoError(t, sock.Close())
+		require.Error(t, ctx.Err())
+
+		// 连接在取消之前就已经建立，结果送达后被关闭
+		client, server := tcpPair(t)
+		results <- manager_sockets.ConnectResult{Conn: client}
+		requireClosedByPeer(t, server)
+	})
+}
@@ -0,0 +1,304 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+	wasi_cli "github.com/OpenListTeam/wazero-wasip2/wasip2/cli"
+	wasi_io "github.com/OpenListTeam/wazero-wasip2/wasip2/io"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// cliRetImports 是通过返回区指针返回结果的 wasi:cli 函数，cliGuest 以相同的名称导出对它们的封装。
+var cliRetImports = [][2]string{
+	{"wasi:cli/environment@0.2.0", "get-arguments"},
+	{"wasi:cli/environment@0.2.0", "get-environment"},
+	{"wasi:cli/environment@0.2.0", "initial-cwd"},
+	{"wasi:cli/terminal-stdin@0.2.0", "get-terminal-stdin"},
+	{"wasi:cli/terminal-stdout@0.2.0", "get-terminal-stdout"},
+	{"wasi:cli/terminal-stderr@0.2.0", "get-terminal-stderr"},
+}
+
+// cliRetPtr 是 cliGuest 中返回区所在的地址。
+const cliRetPtr = 128
+
+func uleb128(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func wasmName(s string) []byte {
+	return append(uleb128(uint32(len(s))), s...)
+}
+
+func wasmSection(id byte, items ...[]byte) []byte {
+	content := uleb128(uint32(len(items)))
+	for _, item := range items {
+		content = append(content, item...)
+	}
+	return append(append([]byte{id}, uleb128(uint32(len(content)))...), content...)
+}
+
+func wasmBody(code ...byte) []byte {
+	return append(uleb128(uint32(len(code))), code...)
+}
+
+// cliGuest 构造一个直接导入 wasi:cli 的核心模块：
+//   - run(status) 向 stdout 写入 "hello"，向 stderr 写入 "error"，然后以 status 调用 exit；
+//   - read-stdin(ret) 从 stdin 读取最多 64 字节，blocking-read 的结果写在返回区；
+//   - cliRetImports 中的每个函数都被原样导出，结果写在调用方给出的返回区；
+//   - cabi_realloc 是一个只增不减的分配器，供 Host 返回列表和字符串。
+func cliGuest() []byte {
+	const (
+		typeRet   = 0 // () -> i32
+		typeWrite = 1 // (i32, i32, i32, i32) -> ()
+		typeParam = 2 // (i32) -> ()
+		typeAlloc = 3 // (i32, i32, i32, i32) -> i32
+		typeRead  = 4 // (i32, i64, i32) -> ()
+	)
+	i32 := byte(0x7f)
+	types := wasmSection(1,
+		[]byte{0x60, 0, 1, i32},
+		[]byte{0x60, 4, i32, i32, i32, i32, 0},
+		[]byte{0x60, 1, i32, 0},
+		[]byte{0x60, 4, i32, i32, i32, i32, 1, i32},
+		[]byte{0x60, 3, i32, 0x7e, i32, 0},
+	)
+
+	importFunc := func(module, name string, typ byte) []byte {
+		return append(append(wasmName(module), wasmName(name)...), 0x00, typ)
+	}
+	imports := [][]byte{
+		importFunc("wasi:cli/stdout@0.2.0", "get-stdout", typeRet),
+		importFunc("wasi:cli/stderr@0.2.0", "get-stderr", typeRet),
+		importFunc("wasi:io/streams@0.2.0", "[method]output-stream.blocking-write-and-flush", typeWrite),
+		importFunc("wasi:cli/exit@0.2.0", "exit", typeParam),
+		importFunc("wasi:cli/stdin@0.2.0", "get-stdin", typeRet),
+		importFunc("wasi:io/streams@0.2.0", "[method]input-stream.blocking-read", typeRead),
+	}
+	for _, imp := range cliRetImports {
+		imports = append(imports, importFunc(imp[0], imp[1], typeParam))
+	}
+
+	const (
+		getStdout = 0
+		getStderr = 1
+		write     = 2
+		exit      = 3
+		getStdin  = 4
+		read      = 5
+		imported  = 6
+	)
+	n := byte(len(cliRetImports))
+	run := imported + n
+	readStdin := run + 1
+	alloc := readStdin + 1 + n
+
+	funcs := [][]byte{{typeParam}, {typeParam}}
+	exports := [][]byte{
+		append(wasmName("memory"), 0x02, 0),
+		append(wasmName("run"), 0x00, run),
+		append(wasmName("read-stdin"), 0x00, readStdin),
+		append(wasmName("cabi_realloc"), 0x00, alloc),
+	}
+	bodies := [][]byte{wasmBody(0,
+		0x10, getStdout, 0x41, 0, 0x41, 5, 0x41, 0xc0, 0, 0x10, write,
+		0x10, getStderr, 0x41, 8, 0x41, 5, 0x41, 0xc0, 0, 0x10, write,
+		0x20, 0, 0x10, exit,
+		0x0b,
+	), wasmBody(0,
+		0x10, getStdin, 0x42, 0xc0, 0, 0x20, 0, 0x10, read,
+		0x0b,
+	)}
+	for j, imp := range cliRetImports {
+		funcs = append(funcs, []byte{typeParam})
+		exports = append(exports, append(wasmName(imp[1]), 0x00, readStdin+1+byte(j)))
+		bodies = append(bodies, wasmBody(0, 0x20, 0, 0x10, imported+byte(j), 0x0b))
+	}
+	// 返回当前位置，并将其增加 new_size 后按 8 字节对齐
+	funcs = append(funcs, []byte{typeAlloc})
+	bodies = append(bodies, wasmBody(0,
+		0x23, 0, 0x23, 0, 0x20, 3, 0x6a, 0x41, 7, 0x6a, 0x41, 0x78, 0x71, 0x24, 0,
+		0x0b,
+	))
+
+	var wasm []byte
+	wasm = append(wasm, "\x00asm\x01\x00\x00\x00"...)
+	wasm = append(wasm, types...)
+	wasm = append(wasm, wasmSection(2, imports...)...)
+	wasm = append(wasm, wasmSection(3, funcs...)...)
+	wasm = append(wasm, wasmSection(5, []byte{0, 1})...)
+	wasm = append(wasm, wasmSection(6, []byte{i32, 1, 0x41, 0x80, 0x08, 0x0b})...)
+	wasm = append(wasm, wasmSection(7, exports...)...)
+	wasm = append(wasm, wasmSection(10, bodies...)...)
+	data := "hello\x00\x00\x00error"
+	wasm = append(wasm, wasmSection(11, append([]byte{0, 0x41, 0, 0x0b}, wasmName(data)...))...)
+	return wasm
+}
+
+// newCLIGuest 使用独立的运行时实例化 cliGuest，返回模块和它的标准输出、标准错误。
+func newCLIGuest(t *testing.T, opts ...wasi_cli.Option) (api.Module, *bytes.Buffer, *bytes.Buffer) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	t.Cleanup(func() { r.Close(ctx) })
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	opts = append(opts, wasi_cli.WithStdout(stdout), wasi_cli.WithStderr(stderr))
+	h := wasip2.NewHost(wasi_io.Module("0.2.0"), wasi_cli.Module("0.2.0", opts...))
+	require.NoError(t, h.Instantiate(ctx, r))
+
+	mod, err := r.Instantiate(ctx, cliGuest())
+	require.NoError(t, err)
+	return mod, stdout, stderr
+}
+
+// callRet 调用 cliGuest 导出的 name，返回 Host 写入返回区的内容。
+func callRet(t *testing.T, mod api.Module, name string, size uint32) []byte {
+	_, err := mod.ExportedFunction(name).Call(context.Background(), cliRetPtr)
+	require.NoError(t, err)
+	ret, ok := mod.Memory().Read(cliRetPtr, size)
+	require.True(t, ok)
+	return ret
+}
+
+// readStrings 读取内存中由 (ptr, len) 组成的 n 个字符串。
+func readStrings(t *testing.T, mem api.Memory, ptr, n uint32) []string {
+	var strs []string
+	for j := uint32(0); j < n; j++ {
+		desc, ok := mem.Read(ptr+8*j, 8)
+		require.True(t, ok)
+		s, ok := mem.Read(binary.LittleEndian.Uint32(desc), binary.LittleEndian.Uint32(desc[4:]))
+		require.True(t, ok)
+		strs = append(strs, string(s))
+	}
+	return strs
+}
+
+func TestCLIExit(t *testing.T) {
+	for _, status := range []uint32{0, 1} {
+		mod, stdout, stderr := newCLIGuest(t)
+
+		// exit 关闭模块并以 *sys.ExitError 结束调用，退出前已写入的输出都会被刷新
+		_, err := mod.ExportedFunction("run").Call(context.Background(), uint64(status))
+		var exitErr *sys.ExitError
+		require.True(t, errors.As(err, &exitErr), "%v", err)
+		require.Equal(t, status, exitErr.ExitCode())
+		require.True(t, mod.IsClosed())
+		require.Equal(t, "hello", stdout.String())
+		require.Equal(t, "error", stderr.String())
+	}
+}
+
+func TestCLIStdin(t *testing.T) {
+	mod, _, _ := newCLIGuest(t, wasi_cli.WithStdin(strings.NewReader("input")))
+
+	// 读取的内容由 cabi_realloc 分配，返回区中是 result<list<u8>, stream-error>
+	var data []byte
+	for len(data) < len("input") {
+		ret := callRet(t, mod, "read-stdin", 12)
+		require.Equal(t, byte(0), ret[0])
+		chunk, ok := mod.Memory().Read(binary.LittleEndian.Uint32(ret[4:]), binary.LittleEndian.Uint32(ret[8:]))
+		require.True(t, ok)
+		data = append(data, chunk...)
+	}
+	require.Equal(t, "input", string(data))
+
+	// stdin 读完之后报告 closed
+	ret := callRet(t, mod, "read-stdin", 12)
+	require.Equal(t, byte(1), ret[0])
+	require.Equal(t, byte(1), ret[4])
+}
+
+func TestCLIEnvironment(t *testing.T) {
+	mod, _, _ := newCLIGuest(t,
+		wasi_cli.WithArgs("guest", "-v"),
+		wasi_cli.WithEnv([]string{"A=1", "B=x=y", "EMPTY"}),
+		wasi_cli.WithInitialCwd("/work"),
+		wasi_cli.WithTerminal(false, true, false),
+	)
+	mem := mod.Memory()
+
+	ret := callRet(t, mod, "get-arguments", 8)
+	require.Equal(t, []string{"guest", "-v"}, readStrings(t, mem, binary.LittleEndian.Uint32(ret), binary.LittleEndian.Uint32(ret[4:])))
+
+	// 环境变量按第一个 "=" 拆分为名称和值
+	ret = callRet(t, mod, "get-environment", 8)
+	require.Equal(t, []string{"A", "1", "B", "x=y", "EMPTY", ""}, readStrings(t, mem, binary.LittleEndian.Uint32(ret), 2*binary.LittleEndian.Uint32(ret[4:])))
+
+	ret = callRet(t, mod, "initial-cwd", 12)
+	require.Equal(t, byte(1), ret[0])
+	require.Equal(t, []string{"/work"}, readStrings(t, mem, cliRetPtr+4, 1))
+
+	// 只有声明为终端的标准流才会返回 terminal 句柄
+	require.Equal(t, byte(0), callRet(t, mod, "get-terminal-stdin", 8)[0])
+	require.Equal(t, byte(1), callRet(t, mod, "get-terminal-stdout", 8)[0])
+	require.Equal(t, byte(0), callRet(t, mod, "get-terminal-stderr", 8)[0])
+}
+
+func TestCLIStdioPerInstance(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	// 每个实例从 StdioFactory 得到自己的标准流
+	var stdouts []*bytes.Buffer
+	factory := func() (io.Reader, io.Writer, io.Writer) {
+		stdout := &bytes.Buffer{}
+		stdouts = append(stdouts, stdout)
+		return strings.NewReader(fmt.Sprintf("input%d", len(stdouts))), stdout, io.Discard
+	}
+	h := wasip2.NewHost(wasi_io.Module("0.2.0"), wasi_cli.Module("0.2.0",
+		wasi_cli.WithStdin(strings.NewReader("shared")),
+		wasi_cli.WithStdioFactory(factory),
+	))
+	compiled, err := r.CompileModule(ctx, cliGuest())
+	require.NoError(t, err)
+
+	var mods []api.Module
+	for range 2 {
+		mod, err := h.InstantiateModule(ctx, r, compiled, wazero.NewModuleConfig().WithName(""))
+		require.NoError(t, err)
+		mods = append(mods, mod)
+	}
+	// 标准流在 Guest 第一次获取时才创建
+	require.Empty(t, stdouts)
+
+	for j, mod := range mods {
+		want := fmt.Sprintf("input%d", j+1)
+		var data []byte
+		for len(data) < len(want) {
+			ret := callRet(t, mod, "read-stdin", 12)
+			require.Equal(t, byte(0), ret[0])
+			chunk, ok := mod.Memory().Read(binary.LittleEndian.Uint32(ret[4:]), binary.LittleEndian.Uint32(ret[8:]))
+			require.True(t, ok)
+			data = append(data, chunk...)
+		}
+		require.Equal(t, want, string(data))
+	}
+
+	// exit 刷新的是调用它的实例自己的 stdout
+	_, err = mods[1].ExportedFunction("run").Call(ctx, 0)
+	var exitErr *sys.ExitError
+	require.True(t, errors.As(err, &exitErr), "%v", err)
+	require.Len(t, stdouts, 2)
+	require.Empty(t, stdouts[0].String())
+	require.Equal(t, "hello", stdouts[1].String())
+}
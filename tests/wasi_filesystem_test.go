@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/filesystem"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSBackendSandbox(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "secret"), []byte("secret"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "file"), []byte("hello"), 0644))
+	require.NoError(t, os.Symlink("../secret", filepath.Join(root, "escape")))
+	require.NoError(t, os.Symlink("sub/file", filepath.Join(root, "link")))
+
+	b := filesystem.NewOSBackend(root)
+	dir, err := b.Root()
+	require.NoError(t, err)
+	defer dir.Close()
+
+	// 越出根目录的路径和符号链接都会被拒绝。
+	_, err = b.OpenAt(dir, "../secret", true, os.O_RDONLY, 0)
+	require.ErrorIs(t, err, filesystem.ErrNotPermitted)
+	_, err = b.OpenAt(dir, "escape", true, os.O_RDONLY, 0)
+	require.ErrorIs(t, err, filesystem.ErrNotPermitted)
+	_, err = b.StatAt(dir, "/etc", true)
+	require.ErrorIs(t, err, filesystem.ErrNotPermitted)
+
+	// 根目录内的符号链接可以被跟随，不跟随时无法打开。
+	f, err := b.OpenAt(dir, "link", true, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	buf := make([]byte, 5)
+	_, err = f.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+	_, err = b.OpenAt(dir, "link", false, os.O_RDONLY, 0)
+	require.Error(t, err)
+
+	info, err := b.StatAt(dir, "escape", false)
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&fs.ModeSymlink)
+
+	t.Run("renamed directory", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("打开的目录在 Windows 上不能被重命名")
+		}
+		// 已打开的目录被重命名，原来的名字被替换为指向根目录外的符号链接后，
+		// 旧描述符上的操作仍然作用于原来的目录。
+		sub, err := b.OpenAt(dir, "sub", false, os.O_RDONLY, 0)
+		require.NoError(t, err)
+		defer sub.Close()
+		require.NoError(t, b.RenameAt(dir, "sub", dir, "moved"))
+		require.NoError(t, os.Symlink("..", filepath.Join(root, "sub")))
+
+		entries, err := sub.ReadDirectory()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "file", entries[0].Name())
+		require.True(t, entries[0].Type().IsRegular())
+
+		_, err = b.StatAt(sub, "file", false)
+		require.NoError(t, err)
+		_, err = b.StatAt(sub, "secret", false)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = b.OpenAt(sub, "secret", true, os.O_RDONLY, 0)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+
+		baseInfo, err := os.Stat(base)
+		require.NoError(t, err)
+		mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+		require.NoError(t, sub.Chtimes(mtime, mtime))
+		info, err := os.Stat(filepath.Join(root, "moved"))
+		require.NoError(t, err)
+		require.True(t, info.ModTime().Equal(mtime))
+		info, err = os.Stat(base)
+		require.NoError(t, err)
+		require.Equal(t, baseInfo.ModTime(), info.ModTime())
+	})
+}
+
+func TestFSBackend(t *testing.T) {
+	b := filesystem.NewFSBackend(fstest.MapFS{
+		"assets/data.txt": {Data: []byte("bundled")},
+	})
+	dir, err := b.Root()
+	require.NoError(t, err)
+
+	f, err := b.OpenAt(dir, "assets/data.txt", true, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	n, err := f.ReadAt(buf, 3)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, "dled", string(buf[:n]))
+
+	_, err = b.OpenAt(dir, "assets/new.txt", true, os.O_CREATE|os.O_WRONLY, 0644)
+	require.ErrorIs(t, err, filesystem.ErrReadOnly)
+	_, err = b.OpenAt(dir, "assets/../../data.txt", true, os.O_RDONLY, 0)
+	require.ErrorIs(t, err, filesystem.ErrNotPermitted)
+
+	entries, err := dir.ReadDirectory()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "assets", entries[0].Name())
+}
+
+func TestMemoryFS(t *testing.T) {
+	m := filesystem.NewMemoryFS()
+	dir, err := m.Root()
+	require.NoError(t, err)
+
+	require.NoError(t, m.MkdirAt(dir, "out", 0755))
+	f, err := m.OpenAt(dir, "out/result.txt", true, os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("hello"), 0)
+	require.NoError(t, err)
+
+	require.NoError(t, m.SymlinkAt("out/result.txt", dir, "latest"))
+	require.ErrorIs(t, m.SymlinkAt("/etc/passwd", dir, "bad"), filesystem.ErrNotPermitted)
+	require.NoError(t, m.RenameAt(dir, "out/result.txt", dir, "out/final.txt"))
+	_, err = m.StatAt(dir, "latest", true)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.ErrorIs(t, m.RmdirAt(dir, "out"), filesystem.ErrNotEmpty)
+	require.ErrorIs(t, m.UnlinkAt(dir, "out"), filesystem.ErrIsDirectory)
+	_, err = m.OpenAt(dir, "out/../../x", true, os.O_RDONLY, 0)
+	require.ErrorIs(t, err, filesystem.ErrNotPermitted)
+
+	// Host 可以通过 fs.FS 接口读取 Guest 写入的内容。
+	data, err := fs.ReadFile(m, "out/final.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+}
+
+func TestMemoryFSLimits(t *testing.T) {
+	m := filesystem.NewMemoryFS(filesystem.WithMaxSize(16), filesystem.WithMaxFileSize(10))
+	dir, err := m.Root()
+	require.NoError(t, err)
+	a, err := m.OpenAt(dir, "a", true, os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	b, err := m.OpenAt(dir, "b", true, os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+
+	// Guest 给出的偏移量和大小不可信，越界时返回错误而不是分配内存或 panic
+	_, err = a.WriteAt([]byte("x"), 1<<40)
+	require.ErrorIs(t, err, filesystem.ErrFileTooLarge)
+	require.ErrorIs(t, a.Truncate(1<<40), filesystem.ErrFileTooLarge)
+	_, err = a.WriteAt([]byte("x"), -1)
+	require.ErrorIs(t, err, fs.ErrInvalid)
+	_, err = a.ReadAt(make([]byte, 1), -1)
+	require.ErrorIs(t, err, fs.ErrInvalid)
+	require.ErrorIs(t, a.Truncate(-1), fs.ErrInvalid)
+
+	// 单个文件和总量的限制
+	_, err = a.WriteAt(make([]byte, 10), 0)
+	require.NoError(t, err)
+	_, err = a.WriteAt([]byte("x"), 10)
+	require.ErrorIs(t, err, filesystem.ErrFileTooLarge)
+	require.NoError(t, b.Truncate(6))
+	require.ErrorIs(t, b.Truncate(7), filesystem.ErrNoSpace)
+
+	// 删除和缩小文件后空间被归还
+	require.NoError(t, m.UnlinkAt(dir, "a"))
+	require.NoError(t, b.Truncate(10))
+	require.NoError(t, b.Truncate(0))
+	c, err := m.OpenAt(dir, "c", true, os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	require.NoError(t, c.Truncate(10))
+}
+
+func TestOverlayFS(t *testing.T) {
+	base := filesystem.NewFSBackend(fstest.MapFS{
+		"etc/config":   {Data: []byte("base")},
+		"etc/old":      {Data: []byte("old")},
+		"data/a/b.txt": {Data: []byte("b")},
+	})
+	o, err := filesystem.NewOverlayFS(base, filesystem.NewMemoryFS())
+	require.NoError(t, err)
+	defer o.Close()
+	dir, err := o.Root()
+	require.NoError(t, err)
+
+	// 修改基础层中的文件会先复制到上层，基础层保持不变。
+	f, err := o.OpenAt(dir, "etc/config", true, os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("upper"), 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	data, err := fs.ReadFile(o.Upper().(fs.FS), "etc/config")
+	require.NoError(t, err)
+	require.Equal(t, "upper", string(data))
+	f, err = base.OpenAt(mustRoot(t, base), "etc/config", true, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = f.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, "base", string(buf))
+
+	// 删除基础层中的条目后不再可见，删除后重建的目录不会带出基础层的内容。
+	require.NoError(t, o.UnlinkAt(dir, "etc/old"))
+	_, err = o.StatAt(dir, "etc/old", false)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, o.UnlinkAt(dir, "data/a/b.txt"))
+	require.NoError(t, o.RmdirAt(dir, "data/a"))
+	require.NoError(t, o.MkdirAt(dir, "data/a", 0755))
+	sub, err := o.OpenAt(dir, "data/a", true, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	entries, err := sub.ReadDirectory()
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	changes, err := o.Changes()
+	require.NoError(t, err)
+	require.Contains(t, changes, filesystem.Change{Path: "etc/config", Kind: filesystem.ChangeModify})
+	require.Contains(t, changes, filesystem.Change{Path: "etc/old", Kind: filesystem.ChangeDelete})
+
+	var out bytes.Buffer
+	require.NoError(t, o.ExportTar(&out))
+	var names []string
+	tr := tar.NewReader(&out)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	require.Contains(t, names, "etc/config")
+	require.Contains(t, names, "etc/.wh.old")
+	require.Contains(t, names, "data/a/.wh..wh..opq")
+}
+
+func mustRoot(t *testing.T, b filesystem.Backend) filesystem.File {
+	dir, err := b.Root()
+	require.NoError(t, err)
+	return dir
+}
+
+func TestOSBackendCrossDevice(t *testing.T) {
+	// 两个后端位于同一个挂载点，内核不会返回 EXDEV
+	base := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(base, "a"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(base, "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "a", "file"), []byte("hello"), 0644))
+
+	a, b := filesystem.NewOSBackend(filepath.Join(base, "a")), filesystem.NewOSBackend(filepath.Join(base, "b"))
+	dirA, err := a.Root()
+	require.NoError(t, err)
+	defer dirA.Close()
+	dirB, err := b.Root()
+	require.NoError(t, err)
+	defer dirB.Close()
+
+	// 其他后端打开的目录无论作为源还是目标都会被拒绝
+	require.ErrorIs(t, a.LinkAt(dirA, "file", false, dirB, "link"), filesystem.ErrCrossDevice)
+	require.ErrorIs(t, b.LinkAt(dirA, "file", false, dirB, "link"), filesystem.ErrCrossDevice)
+	require.ErrorIs(t, a.RenameAt(dirA, "file", dirB, "moved"), filesystem.ErrCrossDevice)
+	require.ErrorIs(t, b.RenameAt(dirA, "file", dirB, "moved"), filesystem.ErrCrossDevice)
+	_, err = b.OpenAt(dirA, "file", false, os.O_RDONLY, 0)
+	require.ErrorIs(t, err, filesystem.ErrCrossDevice)
+
+	entries, err := os.ReadDir(filepath.Join(base, "b"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	require.FileExists(t, filepath.Join(base, "a", "file"))
+
+	// 同一个后端内的操作不受影响
+	require.NoError(t, a.LinkAt(dirA, "file", false, dirA, "link"))
+}
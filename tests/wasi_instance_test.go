@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+
+	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
+	wasi_clocks "github.com/OpenListTeam/wazero-wasip2/wasip2/clocks"
+	wasi_filesystem "github.com/OpenListTeam/wazero-wasip2/wasip2/filesystem"
+	wasi_io "github.com/OpenListTeam/wazero-wasip2/wasip2/io"
+	wasi_random "github.com/OpenListTeam/wazero-wasip2/wasip2/random"
+	wasi_sockets "github.com/OpenListTeam/wazero-wasip2/wasip2/sockets"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+func TestInstanceIsolation(t *testing.T) {
+	wasm, err := os.ReadFile("guest.wasm")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	h := wasip2.NewHost(
+		wasi_io.Module("0.2.0"),
+		wasi_random.Module("0.2.0"),
+		wasi_clocks.Module("0.2.0"),
+		wasi_filesystem.Module("0.2.0"),
+		wasi_sockets.Module("0.2.0"),
+	)
+
+	compiled, err := r.CompileModule(ctx, wasm)
+	require.NoError(t, err)
+
+	// 两个 Guest 分别链接到各自的资源上下文。
+	modA, err := h.InstantiateModule(ctx, r, compiled, wazero.NewModuleConfig().WithName("guest-a"))
+	require.NoError(t, err)
+	modB, err := h.InstantiateModule(ctx, r, compiled, wazero.NewModuleConfig().WithName("guest-b"))
+	require.NoError(t, err)
+
+	instA, ok := h.Instance(modA)
+	require.True(t, ok)
+	instB, ok := h.Instance(modB)
+	require.True(t, ok)
+
+	prA, pwA := io.Pipe()
+	handleA := instA.StreamManager().Add(manager_io.NewAsyncStreamForReader(prA))
+	handleB := instB.StreamManager().Add(manager_io.NewAsyncStreamForReader(strings.NewReader("Hello from B!")))
+
+	// 句柄编号各自独立，互不可见。
+	require.Equal(t, handleA, handleB)
+	_, ok = h.StreamManager().Get(handleA)
+	require.False(t, ok)
+
+	go func() {
+		pwA.Write([]byte("Hello from A!"))
+		pwA.Close()
+	}()
+
+	guestA, err := witgo.NewHost(modA)
+	require.NoError(t, err)
+	guestB, err := witgo.NewHost(modB)
+	require.NoError(t, err)
+
+	var result string
+	require.NoError(t, guestA.Call(ctx, "test-read-stream", &result, handleA))
+	require.Equal(t, "Hello from A!", result)
+	require.NoError(t, guestB.Call(ctx, "test-read-stream", &result, handleB))
+	require.Equal(t, "Hello from B!", result)
+
+	// 关闭 Guest 后，它的资源上下文被释放。
+	prB, pwB := io.Pipe()
+	instB.StreamManager().Add(manager_io.NewAsyncStreamForReader(prB))
+	require.NoError(t, modB.Close(ctx))
+
+	_, ok = h.Instance(modB)
+	require.False(t, ok)
+	_, err = pwB.Write([]byte("unused"))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestPreopenErrors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	for name, hostPath := range map[string]string{
+		"missing": filepath.Join(dir, "missing"),
+		"file":    file,
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := wazero.NewRuntime(ctx)
+			defer r.Close(ctx)
+
+			h := wasip2.NewHost(wasi_filesystem.Module("0.2.0", wasi_filesystem.WithPreopen(hostPath, "/data", false)))
+			err := h.Instantiate(ctx, r)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "/data")
+
+			// 每个 Guest 的预打开目录在实例化时打开，同样会返回错误。
+			compiled, err := r.CompileModule(ctx, []byte("\x00asm\x01\x00\x00\x00"))
+			require.NoError(t, err)
+			_, err = h.InstantiateModule(ctx, r, compiled, wazero.NewModuleConfig())
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "/data")
+		})
+	}
+}
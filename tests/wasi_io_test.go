@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,6 +22,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 )
 
@@ -86,3 +89,172 @@ func TestWasiIO(t *testing.T) {
 		require.Equal(t, "Hello from Host!", result)
 	})
 }
+
+func TestWasiIOBlockingInterrupt(t *testing.T) {
+	wasm, err := os.ReadFile("guest.wasm")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	compiled, err := r.CompileModule(ctx, wasm)
+	require.NoError(t, err)
+
+	newHost := func(opts ...wasip2.ModuleOption) *wasip2.Host {
+		return wasip2.NewHost(append([]wasip2.ModuleOption{
+			wasi_io.Module("0.2.0"),
+			wasi_random.Module("0.2.0"),
+			wasi_clocks.Module("0.2.0"),
+			wasi_filesystem.Module("0.2.0"),
+			wasi_sockets.Module("0.2.0"),
+		}, opts...)...)
+	}
+
+	// callBlocked 让 Guest 阻塞在一个永远没有数据的流上，返回调用的结果。
+	callBlocked := func(ctx context.Context, h *wasip2.Host, name string) (api.Module, <-chan error) {
+		mod, err := h.InstantiateModule(ctx, r, compiled, wazero.NewModuleConfig().WithName(name))
+		require.NoError(t, err)
+		inst, ok := h.Instance(mod)
+		require.True(t, ok)
+
+		pr, _ := io.Pipe()
+		handle := inst.StreamManager().Add(manager_io.NewAsyncStreamForReader(pr))
+
+		guest, err := witgo.NewHost(mod)
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			var result string
+			done <- guest.Call(ctx, "test-read-stream", &result, handle)
+		}()
+		return mod, done
+	}
+
+	wait := func(t *testing.T, done <-chan error) error {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("blocking call was not interrupted")
+			return nil
+		}
+	}
+
+	t.Run("context cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, done := callBlocked(ctx, newHost(), "guest-ctx")
+		require.ErrorContains(t, wait(t, done), context.DeadlineExceeded.Error())
+	})
+
+	t.Run("module close", func(t *testing.T) {
+		mod, done := callBlocked(ctx, newHost(), "guest-close")
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, mod.Close(ctx))
+		require.Error(t, wait(t, done))
+	})
+
+	t.Run("max block duration", func(t *testing.T) {
+		_, done := callBlocked(ctx, newHost(wasip2.WithMaxBlockDuration(50*time.Millisecond)), "guest-max-block")
+		require.ErrorContains(t, wait(t, done), manager_io.ErrBlockTimeout.Error())
+	})
+}
+
+func TestPollableRegister(t *testing.T) {
+	wake := make(chan struct{}, 1)
+
+	a := manager_io.NewPollable(nil)
+	b := manager_io.NewPollable(nil)
+	unregisterA := a.Register(wake)
+	unregisterB := b.Register(wake)
+	defer unregisterB()
+
+	// 多个 Pollable 就绪只会留下一个通知
+	a.SetReady()
+	b.SetReady()
+	require.Len(t, wake, 1)
+	<-wake
+
+	// 取消注册后不再通知
+	unregisterA()
+	a.Reset()
+	a.SetReady()
+	require.Len(t, wake, 0)
+
+	// 注册时已经就绪会立即通知
+	manager_io.ReadyPollable.Register(wake)
+	require.Len(t, wake, 1)
+	<-wake
+
+	// 外部关闭的 channel 同样可以唤醒
+	ch := make(chan struct{})
+	c := manager_io.NewPollableByChan(ch, nil)
+	defer c.Register(wake)()
+	close(ch)
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("pollable created by channel did not wake")
+	}
+}
+
+func TestAsyncReadWrapperBackpressure(t *testing.T) {
+	pr, pw := io.Pipe()
+	var bytesRead atomic.Uint64
+	wrapper := manager_io.NewAsyncReadWrapper(pr, manager_io.WithMaxReadBufferSize(16), manager_io.ReaderRead(&bytesRead))
+	defer wrapper.Close()
+
+	// 写入的数据超过高水位，后台读取在缓冲 16 字节后暂停
+	written := make(chan struct{})
+	go func() {
+		pw.Write(make([]byte, 64))
+		close(written)
+	}()
+	require.Eventually(t, func() bool { return bytesRead.Load() == 16 }, 5*time.Second, time.Millisecond)
+	select {
+	case <-written:
+		t.Fatal("reader did not apply backpressure")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Guest 取走数据后继续读取
+	buf := make([]byte, 64)
+	total := 0
+	require.Eventually(t, func() bool {
+		n, err := wrapper.Read(buf)
+		require.NoError(t, err)
+		total += n
+		return total == 64
+	}, 5*time.Second, time.Millisecond)
+	<-written
+	require.Equal(t, uint64(64), bytesRead.Load())
+}
+
+func TestAsyncReadWrapperClose(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var bytesRead atomic.Uint64
+	wrapper := manager_io.NewAsyncReadWrapper(pr, manager_io.ReaderRead(&bytesRead))
+	go pw.Write([]byte("buffered"))
+	require.Eventually(t, func() bool { return bytesRead.Load() == 8 }, 5*time.Second, time.Millisecond)
+
+	// 关闭后缓冲区归还给 pool，之后的读取不再返回旧数据
+	require.NoError(t, wrapper.Close())
+	n, err := wrapper.Read(make([]byte, 16))
+	require.Zero(t, n)
+	require.NoError(t, err)
+
+	// 新的 wrapper 可能拿到归还的缓冲区，它必须是空的
+	other := manager_io.NewAsyncReadWrapper(strings.NewReader("fresh"))
+	defer other.Close()
+	buf := make([]byte, 16)
+	require.Eventually(t, func() bool {
+		n, _ = other.Read(buf)
+		return n > 0
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(t, "fresh", string(buf[:n]))
+}
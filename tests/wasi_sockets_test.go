@@ -1,12 +1,19 @@
 package tests
 
 import (
+	"bufio"
 	"context"
+	"io"
 	"net"
+	"net/http"
+	"net/netip"
 	"os"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 
+	manager_sockets "github.com/OpenListTeam/wazero-wasip2/manager/sockets"
 	"github.com/OpenListTeam/wazero-wasip2/wasip2"
 	wasi_clocks "github.com/OpenListTeam/wazero-wasip2/wasip2/clocks"
 	wasi_io "github.com/OpenListTeam/wazero-wasip2/wasip2/io"
@@ -19,7 +26,12 @@ import (
 )
 
 // setupSocketsTest a helper function to initialize the wazero runtime and our wasip2 host.
-func setupSocketsTest(t *testing.T) (context.Context, *wasip2.Host, *witgo.Host) {
+func setupSocketsTest(t *testing.T, opts ...wasi_sockets.Option) (context.Context, *wasip2.Host, *witgo.Host) {
+	return setupSocketsHost(t, wasi_sockets.Module("0.2.0", opts...))
+}
+
+// setupSocketsHost 与 setupSocketsTest 相同，但可以传入额外的 Host 选项。
+func setupSocketsHost(t *testing.T, opts ...wasip2.ModuleOption) (context.Context, *wasip2.Host, *witgo.Host) {
 	wasm, err := os.ReadFile("guest.wasm")
 	require.NoError(t, err)
 
@@ -30,11 +42,10 @@ func setupSocketsTest(t *testing.T) (context.Context, *wasip2.Host, *witgo.Host)
 	wasi_snapshot_preview1.MustInstantiate(ctx, r)
 
 	// Enable all necessary WASI modules for sockets and I/O
-	h := wasip2.NewHost(
+	h := wasip2.NewHost(append([]wasip2.ModuleOption{
 		wasi_clocks.Module("0.2.0"),
 		wasi_io.Module("0.2.0"),
-		wasi_sockets.Module("0.2.0"),
-	)
+	}, opts...)...)
 	err = h.Instantiate(ctx, r)
 	require.NoError(t, err)
 
@@ -138,3 +149,388 @@ func TestWasiUDPSockets(t *testing.T) {
 	// 5. Wait for the server goroutine to finish.
 	wg.Wait()
 }
+
+func TestAsyncAcceptor(t *testing.T) {
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	acceptor := manager_sockets.NewAsyncAcceptor(listener)
+	defer acceptor.Close()
+
+	// 没有等待的连接时不阻塞
+	conn, err := acceptor.Accept()
+	require.NoError(t, err)
+	require.Nil(t, conn)
+	ready := acceptor.Subscribe()
+	require.False(t, ready.IsReady())
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	select {
+	case <-ready.Channel():
+	case <-time.After(5 * time.Second):
+		t.Fatal("acceptor did not become ready")
+	}
+	conn, err = acceptor.Accept()
+	require.NoError(t, err)
+	require.NotNil(t, conn)
+	conn.Close()
+	require.False(t, acceptor.Subscribe().IsReady())
+
+	// 监听器关闭后报告错误
+	listener.Close()
+	select {
+	case <-acceptor.Subscribe().Channel():
+	case <-time.After(5 * time.Second):
+		t.Fatal("acceptor did not report the closed listener")
+	}
+	_, err = acceptor.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+// flakyListener 在前几次 Accept 时返回暂时性的错误。
+type flakyListener struct {
+	net.Listener
+	failures int
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+	}
+	return l.Listener.Accept()
+}
+
+func TestAsyncAcceptorTemporaryErrors(t *testing.T) {
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	acceptor := manager_sockets.NewAsyncAcceptor(&flakyListener{Listener: listener, failures: 3})
+	defer acceptor.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// 暂时性的错误不会报告给 Guest，重试后仍能接受连接
+	select {
+	case <-acceptor.Subscribe().Channel():
+	case <-time.After(5 * time.Second):
+		t.Fatal("acceptor did not recover from temporary errors")
+	}
+	conn, err := acceptor.Accept()
+	require.NoError(t, err)
+	require.NotNil(t, conn)
+	conn.Close()
+
+	listener.Close()
+	select {
+	case <-acceptor.Subscribe().Channel():
+	case <-time.After(5 * time.Second):
+		t.Fatal("acceptor did not report the closed listener")
+	}
+	_, err = acceptor.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestWasiSocketsPolicy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+
+	policy := manager_sockets.NetworkPolicy{
+		AllowedConnect: []manager_sockets.AddressRule{{Prefix: netip.MustParsePrefix("127.0.0.0/8"), PortMin: 1, PortMax: port - 1}},
+		DenyUDP:        true,
+	}
+	require.True(t, policy.AllowConnect(netip.MustParseAddrPort("127.0.0.1:1")))
+	require.False(t, policy.AllowConnect(netip.MustParseAddrPort("10.0.0.1:1")))
+	require.False(t, policy.AllowConnect(netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), port)))
+
+	ctx, _, guest := setupSocketsTest(t, wasi_sockets.WithNetworkPolicy(policy))
+
+	// 连接被拒绝，Guest 的 expect 会使调用失败，Host 的监听器不会收到连接
+	accepted := make(chan struct{})
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+			close(accepted)
+		}
+	}()
+	var result string
+	require.Error(t, guest.Call(ctx, "test-tcp-sockets", &result, port, "denied"))
+	select {
+	case <-accepted:
+		t.Fatal("connection was not denied")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// UDP 被禁用
+	require.Error(t, guest.Call(ctx, "test-udp-sockets", &result, port, "denied"))
+}
+
+func TestWasiVirtualNetwork(t *testing.T) {
+	vn := manager_sockets.NewVirtualNetwork()
+	ctx, _, guest := setupSocketsHost(t,
+		wasi_sockets.Module("0.2.0"),
+		wasip2.WithVirtualNetwork(vn),
+	)
+
+	// 虚拟网络中的端口不占用系统端口，可以使用固定的端口号
+	listener, err := vn.Listen("tcp", "127.0.0.1:8080")
+	require.NoError(t, err)
+	defer listener.Close()
+	_, err = vn.Listen("tcp", ":8080")
+	require.Error(t, err)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buffer := make([]byte, 64)
+		n, _ := conn.Read(buffer)
+		conn.Write([]byte("echo: " + string(buffer[:n])))
+	}()
+
+	var result string
+	require.NoError(t, guest.Call(ctx, "test-tcp-sockets", &result, uint16(8080), "virtual tcp"))
+	require.Equal(t, "echo: virtual tcp", result)
+
+	// 没有监听的地址拒绝连接
+	require.Error(t, guest.Call(ctx, "test-tcp-sockets", &result, uint16(8081), "refused"))
+	_, err = vn.Dial("tcp", "127.0.0.1:8081")
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	packetConn, err := vn.ListenPacket("udp", "127.0.0.1:9090")
+	require.NoError(t, err)
+	defer packetConn.Close()
+
+	go func() {
+		buffer := make([]byte, 64)
+		n, remote, err := packetConn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		packetConn.WriteTo([]byte("echo: "+string(buffer[:n])), remote)
+	}()
+
+	require.NoError(t, guest.Call(ctx, "test-udp-sockets", &result, uint16(9090), "virtual udp"))
+	require.Equal(t, "echo: virtual udp", result)
+}
+
+func TestVirtualConn(t *testing.T) {
+	vn := manager_sockets.NewVirtualNetwork()
+	listener, err := vn.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	client, err := vn.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	server, err := listener.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+	require.Equal(t, client.LocalAddr().String(), server.RemoteAddr().String())
+
+	// 关闭写方向后对端读到 EOF
+	_, err = client.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, client.(manager_sockets.TCPConn).CloseWrite())
+	data, err := io.ReadAll(server)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	// 读取超时
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = client.Read(make([]byte, 1))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+// lookupFunc 让普通函数可以作为解析器使用。
+type lookupFunc func(ctx context.Context, network, host string) ([]netip.Addr, error)
+
+func (f lookupFunc) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return f(ctx, network, host)
+}
+
+func TestResolvers(t *testing.T) {
+	ctx := context.Background()
+
+	hosts := manager_sockets.NewHostsResolver(map[string][]netip.Addr{
+		"Plugin.Test": {netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fd00::1")},
+	})
+	addrs, err := hosts.LookupNetIP(ctx, "ip", "plugin.test.")
+	require.NoError(t, err)
+	require.Len(t, addrs, 2)
+	addrs, err = hosts.LookupNetIP(ctx, "ip6", "plugin.test")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("fd00::1")}, addrs)
+	_, err = hosts.LookupNetIP(ctx, "ip", "other.test")
+	var dnsErr *net.DNSError
+	require.ErrorAs(t, err, &dnsErr)
+	require.True(t, dnsErr.IsNotFound)
+
+	// 成功和找不到域名的结果被缓存，被取消的查询不会被缓存
+	var lookups int
+	hosts.Fallback = lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		lookups++
+		if host == "slow.test" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	})
+	cache := manager_sockets.NewCachingResolver(hosts, time.Minute, time.Minute)
+	for range 2 {
+		_, err = cache.LookupNetIP(ctx, "ip", "plugin.test")
+		require.NoError(t, err)
+		_, err = cache.LookupNetIP(ctx, "ip", "missing.test")
+		require.ErrorAs(t, err, &dnsErr)
+	}
+	require.Equal(t, 1, lookups)
+	for range 2 {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = cache.LookupNetIP(cancelCtx, "ip", "slow.test")
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	require.Equal(t, 3, lookups)
+	cache.Flush()
+	_, err = cache.LookupNetIP(ctx, "ip", "missing.test")
+	require.Error(t, err)
+	require.Equal(t, 4, lookups)
+
+	policy := &manager_sockets.NetworkPolicy{DeniedResolve: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	require.False(t, policy.AllowResolved(netip.MustParseAddr("::ffff:10.1.2.3")))
+	require.True(t, policy.AllowResolved(netip.MustParseAddr("fd00::1")))
+}
+
+// serveConnectProxy 启动一个 HTTP CONNECT 代理，到 refused 的连接返回 502。
+func serveConnectProxy(t *testing.T, refused string) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	targets := make(chan string, 8)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				targets <- req.Host
+				if req.Host == refused {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(upstream, br)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return listener.Addr().String(), targets
+}
+
+// serveSocks5Proxy 启动一个只会以 rep 应答 CONNECT 请求的 SOCKS5 代理。
+func serveSocks5Proxy(t *testing.T, rep byte) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// 无认证的问候和 IPv4 的 CONNECT 请求
+			buf := make([]byte, 10)
+			if _, err := io.ReadFull(conn, buf[:3]); err == nil {
+				conn.Write([]byte{0x05, 0x00})
+				if _, err := io.ReadFull(conn, buf); err == nil {
+					conn.Write([]byte{0x05, rep, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestWasiSocketsProxy(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 64)
+				n, _ := conn.Read(buf)
+				conn.Write(buf[:n])
+			}()
+		}
+	}()
+	echoAddr := echo.Addr().(*net.TCPAddr)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().(*net.TCPAddr)
+	closed.Close()
+
+	proxyAddr, targets := serveConnectProxy(t, closedAddr.String())
+	proxy, err := manager_sockets.NewProxy("http://"+proxyAddr,
+		manager_sockets.AddressRule{Prefix: netip.MustParsePrefix("192.0.2.0/24")})
+	require.NoError(t, err)
+	require.True(t, proxy.Bypassed(netip.MustParseAddrPort("192.0.2.1:80")))
+	require.False(t, proxy.Bypassed(netip.MustParseAddrPort("127.0.0.1:80")))
+
+	ctx, _, guest := setupSocketsTest(t, wasi_sockets.WithProxy(proxy))
+
+	var result string
+	require.NoError(t, guest.Call(ctx, "test-tcp-sockets", &result, uint16(echoAddr.Port), "via proxy"))
+	require.Equal(t, "via proxy", result)
+	require.Equal(t, echoAddr.String(), <-targets)
+
+	// 代理无法连接目标时 Guest 的连接失败
+	require.Error(t, guest.Call(ctx, "test-tcp-sockets", &result, uint16(closedAddr.Port), "refused"))
+	require.Equal(t, closedAddr.String(), <-targets)
+	_, err = proxy.DialContext(ctx, closedAddr.AddrPort())
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	// SOCKS5 的应答码映射为对应的错误
+	socks, err := manager_sockets.NewProxy("socks5://" + serveSocks5Proxy(t, 0x02))
+	require.NoError(t, err)
+	_, err = socks.DialContext(ctx, echoAddr.AddrPort())
+	require.ErrorIs(t, err, syscall.EACCES)
+	socks, err = manager_sockets.NewProxy("socks5://" + serveSocks5Proxy(t, 0x00))
+	require.NoError(t, err)
+	conn, err := socks.DialContext(ctx, echoAddr.AddrPort())
+	require.NoError(t, err)
+	require.Equal(t, echoAddr.String(), conn.RemoteAddr().String())
+	conn.Close()
+}
@@ -0,0 +1,118 @@
+package wasi_cli
+
+import (
+	"io"
+	"strings"
+
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+	v0_2 "github.com/OpenListTeam/wazero-wasip2/wasip2/cli/v0_2"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+// Option 用于配置 wasi:cli 模块向 Guest 暴露的进程环境。
+type Option func(*v0_2.Config)
+
+// WithStdin 设置 Guest 的标准输入。默认情况下 stdin 为空。
+// 同一个 Host 的所有 Guest 共享 r，需要为每个实例提供独立的标准流时使用 WithStdioFactory。
+func WithStdin(r io.Reader) Option {
+	return func(c *v0_2.Config) {
+		c.Stdin = r
+	}
+}
+
+// WithStdout 设置 Guest 的标准输出。默认情况下输出会被丢弃。
+func WithStdout(w io.Writer) Option {
+	return func(c *v0_2.Config) {
+		c.Stdout = w
+	}
+}
+
+// WithStderr 设置 Guest 的标准错误输出。默认情况下输出会被丢弃。
+func WithStderr(w io.Writer) Option {
+	return func(c *v0_2.Config) {
+		c.Stderr = w
+	}
+}
+
+// WithStdioFactory 让每个 Guest 实例使用独立的标准流：实例第一次获取标准流时调用 f 得到它的
+// stdin、stdout 和 stderr，实例释放时这些流随之关闭。设置后 WithStdin、WithStdout 和 WithStderr 不生效。
+func WithStdioFactory(f func() (stdin io.Reader, stdout, stderr io.Writer)) Option {
+	return func(c *v0_2.Config) {
+		c.StdioFactory = f
+	}
+}
+
+// WithArgs 设置 Guest 的命令行参数，通常第一个参数是程序名。
+func WithArgs(args ...string) Option {
+	return func(c *v0_2.Config) {
+		c.Args = args
+	}
+}
+
+// WithEnv 设置 Guest 的环境变量，格式与 os.Environ() 相同，即 "KEY=VALUE"。
+func WithEnv(environ []string) Option {
+	return func(c *v0_2.Config) {
+		c.Env = make([]witgo.Tuple[string, string], 0, len(environ))
+		for _, kv := range environ {
+			key, value, _ := strings.Cut(kv, "=")
+			c.Env = append(c.Env, witgo.Tuple[string, string]{F0: key, F1: value})
+		}
+	}
+}
+
+// WithInitialCwd 设置 initial-cwd 返回的初始工作目录。
+func WithInitialCwd(cwd string) Option {
+	return func(c *v0_2.Config) {
+		c.InitialCwd = cwd
+	}
+}
+
+// WithTerminal 声明 stdin、stdout 和 stderr 是否连接到终端。
+func WithTerminal(stdin, stdout, stderr bool) Option {
+	return func(c *v0_2.Config) {
+		c.TerminalStdin = stdin
+		c.TerminalStdout = stdout
+		c.TerminalStderr = stderr
+	}
+}
+
+// Module 返回一个配置好的 wasi:cli 模块选项。
+func Module(version string, opts ...Option) wasip2.ModuleOption {
+	return func(h *wasip2.Host) {
+		cfg := &v0_2.Config{}
+		for _, opt := range opts {
+			opt(cfg)
+		}
+
+		var environmentImpl, exitImpl, stdinImpl, stdoutImpl, stderrImpl wasip2.Implementation
+		var terminalInputImpl, terminalOutputImpl, terminalStdinImpl, terminalStdoutImpl, terminalStderrImpl wasip2.Implementation
+
+		switch version {
+		case "0.2", "0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7":
+			// 标准流在 stdin/stdout/stderr 与 exit 之间共享，exit 时需要刷新它们。
+			stdio := v0_2.NewStdio(cfg)
+			environmentImpl = v0_2.NewEnvironment(cfg)
+			exitImpl = v0_2.NewExit(stdio)
+			stdinImpl = v0_2.NewStdin(stdio)
+			stdoutImpl = v0_2.NewStdout(stdio)
+			stderrImpl = v0_2.NewStderr(stdio)
+			terminalInputImpl = v0_2.NewTerminalInput(cfg)
+			terminalOutputImpl = v0_2.NewTerminalOutput(cfg)
+			terminalStdinImpl = v0_2.NewTerminalStdin(cfg)
+			terminalStdoutImpl = v0_2.NewTerminalStdout(cfg)
+			terminalStderrImpl = v0_2.NewTerminalStderr(cfg)
+		default:
+			return
+		}
+		h.AddImplementation(environmentImpl)
+		h.AddImplementation(exitImpl)
+		h.AddImplementation(stdinImpl)
+		h.AddImplementation(stdoutImpl)
+		h.AddImplementation(stderrImpl)
+		h.AddImplementation(terminalInputImpl)
+		h.AddImplementation(terminalOutputImpl)
+		h.AddImplementation(terminalStdinImpl)
+		h.AddImplementation(terminalStdoutImpl)
+		h.AddImplementation(terminalStderrImpl)
+	}
+}
@@ -0,0 +1,33 @@
+package v0_2
+
+import (
+	"context"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+type environmentImpl struct {
+	cfg *Config
+}
+
+func newEnvironmentImpl(cfg *Config) *environmentImpl {
+	return &environmentImpl{cfg: cfg}
+}
+
+// GetEnvironment 返回 Host 配置的环境变量列表。
+func (i *environmentImpl) GetEnvironment(_ context.Context) []witgo.Tuple[string, string] {
+	return i.cfg.Env
+}
+
+// GetArguments 返回 Host 配置的命令行参数。
+func (i *environmentImpl) GetArguments(_ context.Context) []string {
+	return i.cfg.Args
+}
+
+// InitialCwd 返回 Guest 的初始工作目录。
+func (i *environmentImpl) InitialCwd(_ context.Context) witgo.Option[string] {
+	if i.cfg.InitialCwd == "" {
+		return witgo.None[string]()
+	}
+	return witgo.Some(i.cfg.InitialCwd)
+}
@@ -0,0 +1,43 @@
+package v0_2
+
+import (
+	"context"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/cli"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+type exitImpl struct {
+	stdio *cli.Stdio
+}
+
+func newExitImpl(stdio *cli.Stdio) *exitImpl {
+	return &exitImpl{stdio: stdio}
+}
+
+// Exit 以 result 的判别值作为退出码（ok 为 0，err 为 1）终止 Guest。
+// 与 wasi_snapshot_preview1 的 proc_exit 相同，它会关闭模块并以 *sys.ExitError 触发 trap，
+// 调用方可以通过 errors.As 取得退出码。
+func (i *exitImpl) Exit(ctx context.Context, mod api.Module, status uint32) {
+	var code uint32
+	if status != 0 {
+		code = 1
+	}
+	i.exit(ctx, mod, code)
+}
+
+// ExitWithCode 以指定的退出码终止 Guest。
+func (i *exitImpl) ExitWithCode(ctx context.Context, mod api.Module, code uint32) {
+	i.exit(ctx, mod, code&0xff)
+}
+
+func (i *exitImpl) exit(ctx context.Context, mod api.Module, code uint32) {
+	// 退出前确保 Guest 已写入的标准输出不会丢失。
+	if i.stdio != nil {
+		i.stdio.Flush()
+	}
+	_ = mod.CloseWithExitCode(ctx, code)
+	panic(sys.NewExitError(code))
+}
@@ -0,0 +1,113 @@
+package v0_2
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/cli"
+	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+)
+
+// Stdio 为 wasi:cli 的各个接口提供标准流。
+// 每次 get-stdin/get-stdout/get-stderr 都会返回一个新句柄，
+// 但这些句柄共享同一个底层异步封装器，从而保证数据不会因句柄的丢弃而丢失或乱序。
+//
+// 没有设置 Config.StdioFactory 时，所有资源上下文共享同一组由 Config.Stdin/Stdout/Stderr 创建的流；
+// 否则每个资源上下文在第一次获取标准流时调用 StdioFactory，得到只属于自己的一组流。
+type Stdio struct {
+	cfg    *Config
+	shared *cli.Stdio
+}
+
+// NewStdio 根据配置创建标准流。底层流在第一次被 Guest 获取时才会创建。
+func NewStdio(cfg *Config) *Stdio {
+	s := &Stdio{cfg: cfg}
+	if cfg.StdioFactory == nil {
+		s.shared = newStdio(func() (io.Reader, io.Writer, io.Writer) {
+			return cfg.Stdin, cfg.Stdout, cfg.Stderr
+		})
+	}
+	return s
+}
+
+// streams 返回资源上下文 h 使用的标准流。
+func (s *Stdio) streams(h *wasip2.Host) *cli.Stdio {
+	if s.shared != nil {
+		return s.shared
+	}
+	return h.Stdio(func() *cli.Stdio {
+		return newStdio(s.cfg.StdioFactory)
+	})
+}
+
+// newStdio 创建一组标准流，open 在第一个流被创建时调用一次。
+func newStdio(open func() (io.Reader, io.Writer, io.Writer)) *cli.Stdio {
+	var (
+		once           sync.Once
+		stdin          io.Reader
+		stdout, stderr io.Writer
+	)
+	get := func() {
+		once.Do(func() { stdin, stdout, stderr = open() })
+	}
+	return cli.NewStdio(func() *manager_io.Stream {
+		get()
+		if stdin == nil {
+			// 没有配置 stdin 时，Guest 读取会直接得到 closed。
+			return &manager_io.Stream{Reader: eofReader{}}
+		}
+		return manager_io.NewAsyncStreamForReader(stdin, manager_io.DontCloseReader())
+	}, func() *manager_io.Stream {
+		get()
+		return newOutputStream(stdout)
+	}, func() *manager_io.Stream {
+		get()
+		return newOutputStream(stderr)
+	})
+}
+
+func newOutputStream(w io.Writer) *manager_io.Stream {
+	if w == nil {
+		w = io.Discard
+	}
+	return manager_io.NewAsyncStreamForWriter(w, manager_io.DontCloseWriter())
+}
+
+// handleOf 为共享的流创建一个不带 Closer 的视图，丢弃句柄时不会关闭共享的流。
+func handleOf(s *manager_io.Stream) *manager_io.Stream {
+	return &manager_io.Stream{
+		Reader:      s.Reader,
+		Writer:      s.Writer,
+		Seeker:      s.Seeker,
+		Flusher:     s.Flusher,
+		CheckWriter: s.CheckWriter,
+		OnSubscribe: s.OnSubscribe,
+	}
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+type stdioImpl struct {
+	stdio *cli.Stdio
+	sm    *manager_io.StreamManager
+}
+
+func newStdioImpl(stdio *cli.Stdio, sm *manager_io.StreamManager) *stdioImpl {
+	return &stdioImpl{stdio: stdio, sm: sm}
+}
+
+func (i *stdioImpl) GetStdin(_ context.Context) InputStream {
+	return i.sm.Add(handleOf(i.stdio.Stdin()))
+}
+
+func (i *stdioImpl) GetStdout(_ context.Context) OutputStream {
+	return i.sm.Add(handleOf(i.stdio.Stdout()))
+}
+
+func (i *stdioImpl) GetStderr(_ context.Context) OutputStream {
+	return i.sm.Add(handleOf(i.stdio.Stderr()))
+}
@@ -0,0 +1,49 @@
+package v0_2
+
+import (
+	"context"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/cli"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+type terminalImpl struct {
+	cfg *Config
+	tim *cli.TerminalInputManager
+	tom *cli.TerminalOutputManager
+}
+
+func newTerminalImpl(cfg *Config, tim *cli.TerminalInputManager, tom *cli.TerminalOutputManager) *terminalImpl {
+	return &terminalImpl{cfg: cfg, tim: tim, tom: tom}
+}
+
+func (i *terminalImpl) DropTerminalInput(_ context.Context, handle TerminalInput) {
+	i.tim.Remove(handle)
+}
+
+func (i *terminalImpl) DropTerminalOutput(_ context.Context, handle TerminalOutput) {
+	i.tom.Remove(handle)
+}
+
+// GetTerminalStdin 仅当 Host 声明 stdin 是终端时返回 terminal-input。
+func (i *terminalImpl) GetTerminalStdin(_ context.Context) witgo.Option[TerminalInput] {
+	if !i.cfg.TerminalStdin {
+		return witgo.None[TerminalInput]()
+	}
+	return witgo.Some(i.tim.Add(&cli.TerminalInput{}))
+}
+
+func (i *terminalImpl) GetTerminalStdout(_ context.Context) witgo.Option[TerminalOutput] {
+	return i.terminalOutput(i.cfg.TerminalStdout)
+}
+
+func (i *terminalImpl) GetTerminalStderr(_ context.Context) witgo.Option[TerminalOutput] {
+	return i.terminalOutput(i.cfg.TerminalStderr)
+}
+
+func (i *terminalImpl) terminalOutput(isTerminal bool) witgo.Option[TerminalOutput] {
+	if !isTerminal {
+		return witgo.None[TerminalOutput]()
+	}
+	return witgo.Some(i.tom.Add(&cli.TerminalOutput{}))
+}
@@ -0,0 +1,41 @@
+package v0_2
+
+import (
+	"io"
+
+	io_v0_2 "github.com/OpenListTeam/wazero-wasip2/wasip2/io/v0_2"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+// 从 wasi:io 导入的类型
+type InputStream = io_v0_2.InputStream
+type OutputStream = io_v0_2.OutputStream
+
+// --- wasi:cli/terminal-* types ---
+type TerminalInput = uint32
+type TerminalOutput = uint32
+
+// Config 保存 wasi:cli 模块向 Guest 暴露的进程环境。
+type Config struct {
+	// Stdin 为 nil 时，Guest 读取 stdin 会直接得到 closed。
+	// Stdin、Stdout 和 Stderr 由同一个 Host 的所有 Guest（包括 InstantiateModule 创建的各个实例）共享：
+	// 它们从同一个 stdin 读取，输出也会交错在一起。需要隔离时使用 StdioFactory。
+	Stdin io.Reader
+	// Stdout/Stderr 为 nil 时，输出会被丢弃。
+	Stdout io.Writer
+	Stderr io.Writer
+	// StdioFactory 不为 nil 时，每个资源上下文在 Guest 第一次获取标准流时调用它，
+	// 得到只属于自己的 stdin、stdout 和 stderr，此时 Stdin、Stdout 和 Stderr 不生效。
+	// 返回值为 nil 时的含义与对应字段为 nil 时相同。
+	StdioFactory func() (stdin io.Reader, stdout, stderr io.Writer)
+
+	Args []string
+	Env  []witgo.Tuple[string, string]
+	// InitialCwd 为空时，initial-cwd 返回 none。
+	InitialCwd string
+
+	// 是否向 Guest 报告对应的标准流连接到了终端。
+	TerminalStdin  bool
+	TerminalStdout bool
+	TerminalStderr bool
+}
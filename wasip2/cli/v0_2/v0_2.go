@@ -0,0 +1,183 @@
+package v0_2
+
+import (
+	"context"
+
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// --- wasi:cli/environment ---
+type wasiEnvironment struct{ cfg *Config }
+
+func NewEnvironment(cfg *Config) wasip2.Implementation {
+	return &wasiEnvironment{cfg: cfg}
+}
+func (i *wasiEnvironment) Name() string { return "wasi:cli/environment" }
+func (i *wasiEnvironment) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiEnvironment) Instantiate(_ context.Context, _ *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newEnvironmentImpl(i.cfg)
+	exporter := witgo.NewExporter(b)
+	exporter.Export("get-environment", handler.GetEnvironment)
+	exporter.Export("get-arguments", handler.GetArguments)
+	exporter.Export("initial-cwd", handler.InitialCwd)
+	return nil
+}
+
+// --- wasi:cli/exit ---
+type wasiExit struct{ stdio *Stdio }
+
+func NewExit(stdio *Stdio) wasip2.Implementation {
+	return &wasiExit{stdio: stdio}
+}
+func (i *wasiExit) Name() string { return "wasi:cli/exit" }
+func (i *wasiExit) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiExit) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newExitImpl(i.stdio.streams(h))
+	// exit 需要访问调用方模块以关闭它，因此直接使用 wazero 的函数构建器导出。
+	b.NewFunctionBuilder().WithFunc(handler.Exit).Export("exit")
+	b.NewFunctionBuilder().WithFunc(handler.ExitWithCode).Export("exit-with-code")
+	return nil
+}
+
+// --- wasi:cli/stdin ---
+type wasiStdin struct{ stdio *Stdio }
+
+func NewStdin(stdio *Stdio) wasip2.Implementation {
+	return &wasiStdin{stdio: stdio}
+}
+func (i *wasiStdin) Name() string { return "wasi:cli/stdin" }
+func (i *wasiStdin) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiStdin) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newStdioImpl(i.stdio.streams(h), h.StreamManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("get-stdin", handler.GetStdin)
+	return nil
+}
+
+// --- wasi:cli/stdout ---
+type wasiStdout struct{ stdio *Stdio }
+
+func NewStdout(stdio *Stdio) wasip2.Implementation {
+	return &wasiStdout{stdio: stdio}
+}
+func (i *wasiStdout) Name() string { return "wasi:cli/stdout" }
+func (i *wasiStdout) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiStdout) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newStdioImpl(i.stdio.streams(h), h.StreamManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("get-stdout", handler.GetStdout)
+	return nil
+}
+
+// --- wasi:cli/stderr ---
+type wasiStderr struct{ stdio *Stdio }
+
+func NewStderr(stdio *Stdio) wasip2.Implementation {
+	return &wasiStderr{stdio: stdio}
+}
+func (i *wasiStderr) Name() string { return "wasi:cli/stderr" }
+func (i *wasiStderr) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiStderr) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newStdioImpl(i.stdio.streams(h), h.StreamManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("get-stderr", handler.GetStderr)
+	return nil
+}
+
+// --- wasi:cli/terminal-input ---
+type wasiTerminalInput struct{ cfg *Config }
+
+func NewTerminalInput(cfg *Config) wasip2.Implementation {
+	return &wasiTerminalInput{cfg: cfg}
+}
+func (i *wasiTerminalInput) Name() string { return "wasi:cli/terminal-input" }
+func (i *wasiTerminalInput) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiTerminalInput) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newTerminalImpl(i.cfg, h.TerminalInputManager(), h.TerminalOutputManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("[resource-drop]terminal-input", handler.DropTerminalInput)
+	return nil
+}
+
+// --- wasi:cli/terminal-output ---
+type wasiTerminalOutput struct{ cfg *Config }
+
+func NewTerminalOutput(cfg *Config) wasip2.Implementation {
+	return &wasiTerminalOutput{cfg: cfg}
+}
+func (i *wasiTerminalOutput) Name() string { return "wasi:cli/terminal-output" }
+func (i *wasiTerminalOutput) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiTerminalOutput) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newTerminalImpl(i.cfg, h.TerminalInputManager(), h.TerminalOutputManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("[resource-drop]terminal-output", handler.DropTerminalOutput)
+	return nil
+}
+
+// --- wasi:cli/terminal-stdin ---
+type wasiTerminalStdin struct{ cfg *Config }
+
+func NewTerminalStdin(cfg *Config) wasip2.Implementation {
+	return &wasiTerminalStdin{cfg: cfg}
+}
+func (i *wasiTerminalStdin) Name() string { return "wasi:cli/terminal-stdin" }
+func (i *wasiTerminalStdin) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiTerminalStdin) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newTerminalImpl(i.cfg, h.TerminalInputManager(), h.TerminalOutputManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("get-terminal-stdin", handler.GetTerminalStdin)
+	return nil
+}
+
+// --- wasi:cli/terminal-stdout ---
+type wasiTerminalStdout struct{ cfg *Config }
+
+func NewTerminalStdout(cfg *Config) wasip2.Implementation {
+	return &wasiTerminalStdout{cfg: cfg}
+}
+func (i *wasiTerminalStdout) Name() string { return "wasi:cli/terminal-stdout" }
+func (i *wasiTerminalStdout) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiTerminalStdout) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newTerminalImpl(i.cfg, h.TerminalInputManager(), h.TerminalOutputManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("get-terminal-stdout", handler.GetTerminalStdout)
+	return nil
+}
+
+// --- wasi:cli/terminal-stderr ---
+type wasiTerminalStderr struct{ cfg *Config }
+
+func NewTerminalStderr(cfg *Config) wasip2.Implementation {
+	return &wasiTerminalStderr{cfg: cfg}
+}
+func (i *wasiTerminalStderr) Name() string { return "wasi:cli/terminal-stderr" }
+func (i *wasiTerminalStderr) Versions() []string {
+	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
+}
+func (i *wasiTerminalStderr) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	handler := newTerminalImpl(i.cfg, h.TerminalInputManager(), h.TerminalOutputManager())
+	exporter := witgo.NewExporter(b)
+	exporter.Export("get-terminal-stderr", handler.GetTerminalStderr)
+	return nil
+}
@@ -1,19 +1,104 @@
 package wasi_filesystem
 
 import (
+	"io/fs"
+	"path/filepath"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/filesystem"
 	"github.com/OpenListTeam/wazero-wasip2/wasip2"
 	v0_2 "github.com/OpenListTeam/wazero-wasip2/wasip2/filesystem/v0_2"
 )
 
+// Option 用于配置 wasi:filesystem 模块。
+type Option func(*v0_2.Config)
+
+// WithPreopen 将 Host 上的 hostPath 目录以 guestPath 的名字预打开给 Guest。
+// readOnly 为 true 时，Guest 只能读取该目录下的内容。
+func WithPreopen(hostPath, guestPath string, readOnly bool) Option {
+	return func(c *v0_2.Config) {
+		// 使用绝对路径，避免 Host 之后切换工作目录影响路径解析。
+		if abs, err := filepath.Abs(hostPath); err == nil {
+			hostPath = abs
+		}
+		c.Preopens = append(c.Preopens, v0_2.Preopen{
+			Backend:   filesystem.NewOSBackend(hostPath),
+			GuestPath: guestPath,
+			ReadOnly:  readOnly,
+		})
+	}
+}
+
+// WithFS 将任意 fs.FS（例如 embed.FS）以 guestPath 的名字只读地预打开给 Guest。
+func WithFS(fsys fs.FS, guestPath string) Option {
+	return WithBackend(filesystem.NewFSBackend(fsys), guestPath, true)
+}
+
+// WithBackend 将自定义的后端以 guestPath 的名字预打开给 Guest。
+// 例如使用 filesystem.NewMemoryFS() 为 Guest 提供一个一次性的临时目录。
+func WithBackend(backend filesystem.Backend, guestPath string, readOnly bool) Option {
+	return func(c *v0_2.Config) {
+		c.Preopens = append(c.Preopens, v0_2.Preopen{
+			Backend:   backend,
+			GuestPath: guestPath,
+			ReadOnly:  readOnly,
+		})
+	}
+}
+
+// WithOverlay 以写时复制的方式将 base 以 guestPath 的名字预打开给 Guest。
+// Guest 可以像普通目录一样读写，但所有修改都只会写入上层，base 永远不会被修改。
+//
+// 每个资源上下文（见 wasip2.Host.InstantiateModule）都会调用 newUpper 创建独立的上层，
+// newUpper 为 nil 时使用内存中的上层，也可以使用 TempDirUpper 将上层放在 Host 的临时目录中。
+// onCreate 不为 nil 时会收到新建的 OverlayFS，Host 可以在 Guest 结束后查看或导出改动，
+// 并在不再需要时调用它的 Close。
+func WithOverlay(base filesystem.Backend, guestPath string, newUpper func() (filesystem.Backend, error), onCreate func(*filesystem.OverlayFS)) Option {
+	if newUpper == nil {
+		newUpper = func() (filesystem.Backend, error) { return filesystem.NewMemoryFS(), nil }
+	}
+	return func(c *v0_2.Config) {
+		c.Preopens = append(c.Preopens, v0_2.Preopen{
+			NewBackend: func() (filesystem.Backend, error) {
+				upper, err := newUpper()
+				if err != nil {
+					return nil, err
+				}
+				overlay, err := filesystem.NewOverlayFS(base, upper)
+				if err != nil {
+					return nil, err
+				}
+				if onCreate != nil {
+					onCreate(overlay)
+				}
+				return overlay, nil
+			},
+			GuestPath: guestPath,
+		})
+	}
+}
+
+// TempDirUpper 返回一个在 Host 目录 dir 下为 WithOverlay 创建临时上层的函数。
+// dir 为空时使用系统临时目录；OverlayFS.Close 会删除该临时目录。
+func TempDirUpper(dir string) func() (filesystem.Backend, error) {
+	return func() (filesystem.Backend, error) {
+		return filesystem.NewTempDirBackend(dir, "wasi-overlay-*")
+	}
+}
+
 // Module 返回一个配置好的 wasi:filesystem 模块选项。
-func Module(version string) wasip2.ModuleOption {
+func Module(version string, opts ...Option) wasip2.ModuleOption {
 	return func(h *wasip2.Host) {
+		cfg := &v0_2.Config{}
+		for _, opt := range opts {
+			opt(cfg)
+		}
+
 		var typesImpl, preopensImpl wasip2.Implementation
 
 		switch version {
 		case "0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7":
 			typesImpl = v0_2.NewTypes()
-			preopensImpl = v0_2.NewPreopens()
+			preopensImpl = v0_2.NewPreopens(cfg)
 		default:
 			return
 		}
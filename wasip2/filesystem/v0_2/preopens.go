@@ -2,31 +2,122 @@ package v0_2
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
 
 	"github.com/OpenListTeam/wazero-wasip2/manager/filesystem"
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
 )
 
+// Preopen 描述一个由 Host 预先打开并暴露给 Guest 的目录。
+type Preopen struct {
+	// Backend 提供该目录的内容，可以是 Host 目录、fs.FS 或内存文件系统。
+	Backend filesystem.Backend
+	// NewBackend 不为 nil 时，每个资源上下文会在实例化时调用它创建独立的后端，
+	// 此时 Backend 被忽略。
+	NewBackend func() (filesystem.Backend, error)
+	// GuestPath 是 Guest 通过 get-directories 看到的路径。
+	GuestPath string
+	// ReadOnly 为 true 时，Guest 无法通过该目录进行任何写入或修改。
+	ReadOnly bool
+}
+
+// Config 保存 wasi:filesystem 模块的 Host 侧配置。
+type Config struct {
+	Preopens []Preopen
+}
+
 type preopensImpl struct {
+	cfg *Config
 	fsm *filesystem.Manager
+
+	// roots 是实例化时打开的各个预打开目录，与 cfg.Preopens 一一对应。
+	// 它们不在 fsm 中，Guest 无法使用或丢弃它们，只用于为 get-directories 打开新的描述符，
+	// 由 Host 在资源上下文释放时关闭。
+	roots []*filesystem.Descriptor
+}
+
+func newPreopensImpl(cfg *Config, h *wasip2.Host) (*preopensImpl, error) {
+	i := &preopensImpl{cfg: cfg, fsm: h.FilesystemManager()}
+	roots, err := h.Preopens(i.open)
+	if err != nil {
+		return nil, err
+	}
+	i.roots = roots
+	return i, nil
+}
+
+// open 创建并打开所有预打开目录。任何一个目录无法打开或不是目录时返回错误，
+// 这样配置错误会在实例化时暴露给 Host，而不是让 Guest 看到一个缺少目录的列表。
+func (i *preopensImpl) open() ([]*filesystem.Descriptor, error) {
+	var roots []*filesystem.Descriptor
+	for _, preopen := range i.cfg.Preopens {
+		root, err := openRoot(preopen)
+		if err != nil {
+			for _, root := range roots {
+				root.File.Close()
+			}
+			return nil, fmt.Errorf("failed to open preopen %q: %w", preopen.GuestPath, err)
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
 }
 
-func newPreopensImpl(fsm *filesystem.Manager) *preopensImpl {
-	return &preopensImpl{fsm: fsm}
+func openRoot(preopen Preopen) (*filesystem.Descriptor, error) {
+	backend := preopen.Backend
+	if preopen.NewBackend != nil {
+		var err error
+		if backend, err = preopen.NewBackend(); err != nil {
+			return nil, err
+		}
+	}
+	if backend == nil {
+		return nil, errors.New("no backend")
+	}
+	root, err := backend.Root()
+	if err != nil {
+		return nil, err
+	}
+	info, err := root.Stat()
+	if err == nil && !info.IsDir() {
+		err = filesystem.ErrNotDirectory
+	}
+	if err != nil {
+		root.Close()
+		return nil, err
+	}
+	return &filesystem.Descriptor{
+		File:     root,
+		Backend:  backend,
+		Path:     preopen.GuestPath,
+		ReadOnly: preopen.ReadOnly,
+	}, nil
 }
 
 // GetDirectories returns the list of pre-opened directories.
+// 只返回 Host 配置的预打开目录，Guest 之后通过 open-at 打开的描述符不会出现在这里。
+// 每次调用都会从实例化时打开的目录重新打开一个描述符，Guest 丢弃它们不会影响后续调用，
+// Host 上的目录之后被移动或替换也不会改变 Guest 看到的目录。
 func (i *preopensImpl) GetDirectories(_ context.Context) []witgo.Tuple[Descriptor, string] {
 	var results []witgo.Tuple[Descriptor, string]
-	// Note: In a real implementation, this would iterate over pre-opened
-	// directories configured by the host environment. For this example,
-	// we assume the manager contains only pre-opens.
-	i.fsm.Range(func(handle uint32, desc *filesystem.Descriptor) bool {
+	for _, root := range i.roots {
+		file, err := root.Backend.OpenAt(root.File, ".", true, os.O_RDONLY, 0)
+		if err != nil {
+			// 只有描述符耗尽之类的系统错误会走到这里，无法向 Guest 报告。
+			continue
+		}
 		results = append(results, witgo.Tuple[Descriptor, string]{
-			F0: handle,
-			F1: desc.Path,
+			F0: i.fsm.Add(&filesystem.Descriptor{
+				File:     file,
+				Backend:  root.Backend,
+				Path:     root.Path,
+				ReadOnly: root.ReadOnly,
+			}),
+			F1: root.Path,
 		})
-		return true
-	})
+	}
 	return results
 }
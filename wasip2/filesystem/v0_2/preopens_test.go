@@ -0,0 +1,156 @@
+package v0_2
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/filesystem"
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyPreopen(t *testing.T) {
+	ctx := context.Background()
+	backend := filesystem.NewMemoryFS()
+	root, err := backend.Root()
+	require.NoError(t, err)
+	f, err := backend.OpenAt(root, "file", false, os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("hello"), 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, backend.MkdirAt(root, "dir", 0755))
+	require.NoError(t, root.Close())
+
+	h := wasip2.NewHost()
+	preopens, err := newPreopensImpl(&Config{Preopens: []Preopen{
+		{Backend: backend, GuestPath: "/ro", ReadOnly: true},
+		{Backend: filesystem.NewMemoryFS(), GuestPath: "/rw"},
+	}}, h)
+	require.NoError(t, err)
+	dirs := preopens.GetDirectories(ctx)
+	require.Len(t, dirs, 2)
+	require.Equal(t, "/ro", dirs[0].F1)
+	ro, rw := dirs[0].F0, dirs[1].F0
+	types := newTypesImpl(h)
+
+	// 读取不受影响
+	flags := types.GetFlags(ctx, ro)
+	require.NotNil(t, flags.Ok)
+	require.True(t, flags.Ok.Read)
+	require.False(t, flags.Ok.Write)
+	require.False(t, flags.Ok.MutateDirectory)
+	file := types.OpenAt(ctx, ro, PathFlags{}, "file", OpenFlags{}, DescriptorFlags{Read: true})
+	require.NotNil(t, file.Ok)
+	read := types.Read(ctx, *file.Ok, 16, 0)
+	require.NotNil(t, read.Ok)
+	require.Equal(t, "hello", string(read.Ok.F0))
+
+	now := NewTimestamp{Now: &witgo.Unit{}}
+	readOnly := map[string]*ErrorCode{
+		"open-at write":     types.OpenAt(ctx, ro, PathFlags{}, "file", OpenFlags{}, DescriptorFlags{Read: true, Write: true}).Err,
+		"open-at create":    types.OpenAt(ctx, ro, PathFlags{}, "new", OpenFlags{Create: true}, DescriptorFlags{Read: true}).Err,
+		"open-at truncate":  types.OpenAt(ctx, ro, PathFlags{}, "file", OpenFlags{Truncate: true}, DescriptorFlags{Read: true}).Err,
+		"open-at mutate":    types.OpenAt(ctx, ro, PathFlags{}, "dir", OpenFlags{Directory: true}, DescriptorFlags{MutateDirectory: true}).Err,
+		"write":             types.Write(ctx, *file.Ok, []byte("x"), 0).Err,
+		"write-via-stream":  types.WriteViaStream(ctx, *file.Ok, 0).Err,
+		"append-via-stream": types.AppendViaStream(ctx, *file.Ok).Err,
+		"set-size":          types.SetSize(ctx, *file.Ok, 0).Err,
+		"set-times":         types.SetTimes(ctx, *file.Ok, now, now).Err,
+		"set-times-at":      types.SetTimesAt(ctx, ro, PathFlags{}, "file", now, now).Err,
+		"create-directory":  types.CreateDirectoryAt(ctx, ro, "new").Err,
+		"remove-directory":  types.RemoveDirectoryAt(ctx, ro, "dir").Err,
+		"unlink-file":       types.UnlinkFileAt(ctx, ro, "file").Err,
+		"symlink":           types.SymlinkAt(ctx, ro, "file", "link").Err,
+		"rename":            types.RenameAt(ctx, ro, "file", ro, "moved").Err,
+		"rename out":        types.RenameAt(ctx, ro, "file", rw, "moved").Err,
+		"link":              types.LinkAt(ctx, ro, PathFlags{}, "file", ro, "hard").Err,
+		"link into":         types.LinkAt(ctx, rw, PathFlags{}, "missing", ro, "hard").Err,
+		"link out":          types.LinkAt(ctx, ro, PathFlags{}, "file", rw, "hard").Err,
+	}
+	for op, code := range readOnly {
+		require.NotNil(t, code, op)
+		require.Equal(t, ErrorCodeReadOnly, *code, op)
+	}
+
+	// 从只读目录打开的子目录同样是只读的
+	dir := types.OpenAt(ctx, ro, PathFlags{}, "dir", OpenFlags{Directory: true}, DescriptorFlags{Read: true})
+	require.NotNil(t, dir.Ok)
+	code := types.CreateDirectoryAt(ctx, *dir.Ok, "sub").Err
+	require.NotNil(t, code)
+	require.Equal(t, ErrorCodeReadOnly, *code)
+
+	// 后端没有被修改
+	entries := types.ReadDirectory(ctx, ro)
+	require.NotNil(t, entries.Ok)
+	var names []string
+	for {
+		entry := types.ReadDirectoryEntry(ctx, *entries.Ok)
+		require.NotNil(t, entry.Ok)
+		if entry.Ok.IsNone() {
+			break
+		}
+		names = append(names, entry.Ok.Some.Name)
+	}
+	require.ElementsMatch(t, []string{"file", "dir"}, names)
+}
+
+func TestLinkOutOfReadOnlyPreopen(t *testing.T) {
+	ctx := context.Background()
+	backend := filesystem.NewMemoryFS()
+	root, err := backend.Root()
+	require.NoError(t, err)
+	f, err := backend.OpenAt(root, "file", false, os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, root.Close())
+
+	// 同一个后端分别以只读和可写的方式预打开
+	h := wasip2.NewHost()
+	preopens, err := newPreopensImpl(&Config{Preopens: []Preopen{
+		{Backend: backend, GuestPath: "/ro", ReadOnly: true},
+		{Backend: backend, GuestPath: "/rw"},
+	}}, h)
+	require.NoError(t, err)
+	dirs := preopens.GetDirectories(ctx)
+	require.Len(t, dirs, 2)
+	types := newTypesImpl(h)
+
+	// 链接到可写目录后就能修改只读目录中的文件，因此必须拒绝
+	code := types.LinkAt(ctx, dirs[0].F0, PathFlags{}, "file", dirs[1].F0, "hard").Err
+	require.NotNil(t, code)
+	require.Equal(t, ErrorCodeReadOnly, *code)
+	require.NotNil(t, types.StatAt(ctx, dirs[1].F0, PathFlags{}, "hard").Err)
+}
+
+func TestPreopenRootsAreNotGuestHandles(t *testing.T) {
+	ctx := context.Background()
+	h := wasip2.NewHost()
+	preopens, err := newPreopensImpl(&Config{Preopens: []Preopen{
+		{Backend: filesystem.NewMemoryFS(), GuestPath: "/a"},
+		{Backend: filesystem.NewMemoryFS(), GuestPath: "/b"},
+	}}, h)
+	require.NoError(t, err)
+
+	// 调用 get-directories 之前 Guest 看不到任何描述符
+	_, ok := h.FilesystemManager().Get(1)
+	require.False(t, ok)
+	types := newTypesImpl(h)
+	types.DropDescriptor(ctx, 1)
+	types.DropDescriptor(ctx, 2)
+
+	// 丢弃 get-directories 返回的描述符不影响之后的调用
+	for range 2 {
+		dirs := preopens.GetDirectories(ctx)
+		require.Len(t, dirs, 2)
+		require.Equal(t, "/a", dirs[0].F1)
+		require.Equal(t, "/b", dirs[1].F1)
+		for _, dir := range dirs {
+			require.NotNil(t, types.Stat(ctx, dir.F0).Ok)
+			types.DropDescriptor(ctx, dir.F0)
+		}
+	}
+}
@@ -2,11 +2,10 @@ package v0_2
 
 import (
 	"context"
+	"errors"
 	"io"
-	"io/fs"
+	"math"
 	"os"
-	"path/filepath"
-	"syscall"
 	"time"
 
 	"github.com/OpenListTeam/wazero-wasip2/manager/filesystem"
@@ -15,6 +14,8 @@ import (
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
 )
 
+const maxReadSize = 1 << 20 // read 单次最多返回的字节数
+
 type typesImpl struct {
 	host *wasip2.Host
 }
@@ -24,8 +25,7 @@ func newTypesImpl(h *wasip2.Host) *typesImpl {
 }
 
 /// TODO:
-/// 1. 完善沙盒机制
-/// 2. 完善poll机制(可能没有必要)
+/// 1. 完善poll机制(可能没有必要)
 
 // --- descriptor resource methods ---
 
@@ -38,6 +38,9 @@ func (i *typesImpl) ReadViaStream(_ context.Context, this Descriptor, offset Fil
 	if !ok {
 		return witgo.Err[InputStream, ErrorCode](ErrorCodeBadDescriptor)
 	}
+	if offset > math.MaxInt64 {
+		return witgo.Err[InputStream, ErrorCode](ErrorCodeInvalid)
+	}
 	reader := io.NewSectionReader(d.File, int64(offset), -1)
 	stream := &manager_io.Stream{Reader: reader, Seeker: reader}
 	handle := i.host.StreamManager().Add(stream)
@@ -49,6 +52,12 @@ func (i *typesImpl) WriteViaStream(_ context.Context, this Descriptor, offset Fi
 	if !ok {
 		return witgo.Err[OutputStream, ErrorCode](ErrorCodeBadDescriptor)
 	}
+	if d.ReadOnly {
+		return witgo.Err[OutputStream, ErrorCode](ErrorCodeReadOnly)
+	}
+	if offset > math.MaxInt64 {
+		return witgo.Err[OutputStream, ErrorCode](ErrorCodeInvalid)
+	}
 	writer := &sectionWriter{d.File, int64(offset)}
 	stream := &manager_io.Stream{Writer: writer}
 	handle := i.host.StreamManager().Add(stream)
@@ -60,7 +69,10 @@ func (i *typesImpl) AppendViaStream(_ context.Context, this Descriptor) witgo.Re
 	if !ok {
 		return witgo.Err[OutputStream, ErrorCode](ErrorCodeBadDescriptor)
 	}
-	stream := &manager_io.Stream{Writer: d.File, Flusher: &OsFileFlusher{w: d.File}}
+	if d.ReadOnly {
+		return witgo.Err[OutputStream, ErrorCode](ErrorCodeReadOnly)
+	}
+	stream := &manager_io.Stream{Writer: &appendWriter{d.File}, Flusher: &OsFileFlusher{w: d.File}}
 	handle := i.host.StreamManager().Add(stream)
 	return witgo.Ok[OutputStream, ErrorCode](handle)
 }
@@ -86,6 +98,12 @@ func (i *typesImpl) SetSize(ctx context.Context, this Descriptor, size Filesize)
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
+	if d.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
+	if size > math.MaxInt64 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeFileTooLarge)
+	}
 	err := d.File.Truncate(int64(size))
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
@@ -98,14 +116,15 @@ func (i *typesImpl) SetTimes(ctx context.Context, this Descriptor, data_access_t
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
-
-	path := d.File.Name()
+	if d.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
 
 	var atime, mtime time.Time
 
 	// 为了处理 NoChange，我们需要先获取文件的当前时间戳
 	if data_access_timestamp.NoChange != nil || data_modification_timestamp.NoChange != nil {
-		info, err := os.Stat(path)
+		info, err := d.File.Stat()
 		if err != nil {
 			return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 		}
@@ -129,7 +148,7 @@ func (i *typesImpl) SetTimes(ctx context.Context, this Descriptor, data_access_t
 		mtime = time.Now()
 	}
 
-	err := os.Chtimes(path, atime, mtime)
+	err := d.File.Chtimes(atime, mtime)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -141,7 +160,11 @@ func (i *typesImpl) Read(ctx context.Context, this Descriptor, length Filesize,
 	if !ok {
 		return witgo.Err[witgo.Tuple[[]byte, bool], ErrorCode](ErrorCodeBadDescriptor)
 	}
-	buf := make([]byte, length)
+	if offset > math.MaxInt64 {
+		return witgo.Err[witgo.Tuple[[]byte, bool], ErrorCode](ErrorCodeInvalid)
+	}
+	// length 由 Guest 给出，一次最多读取 maxReadSize 字节，Guest 会继续读取剩余的部分
+	buf := make([]byte, min(length, maxReadSize))
 	n, err := d.File.ReadAt(buf, int64(offset))
 	endOfFile := err == io.EOF
 	if err != nil && err != io.EOF {
@@ -156,6 +179,12 @@ func (i *typesImpl) Write(ctx context.Context, this Descriptor, buffer []byte, o
 	if !ok {
 		return witgo.Err[Filesize, ErrorCode](ErrorCodeBadDescriptor)
 	}
+	if d.ReadOnly {
+		return witgo.Err[Filesize, ErrorCode](ErrorCodeReadOnly)
+	}
+	if offset > math.MaxInt64 {
+		return witgo.Err[Filesize, ErrorCode](ErrorCodeInvalid)
+	}
 	n, err := d.File.WriteAt(buffer, int64(offset))
 	if err != nil {
 		return witgo.Err[Filesize, ErrorCode](mapOsError(err))
@@ -169,7 +198,7 @@ func (i *typesImpl) ReadDirectory(ctx context.Context, this Descriptor) witgo.Re
 		return witgo.Err[DirectoryEntryStream, ErrorCode](ErrorCodeBadDescriptor)
 	}
 
-	entries, err := os.ReadDir(d.File.Name())
+	entries, err := d.File.ReadDirectory()
 	if err != nil {
 		return witgo.Err[DirectoryEntryStream, ErrorCode](mapOsError(err))
 	}
@@ -199,11 +228,12 @@ func (i *typesImpl) CreateDirectoryAt(ctx context.Context, this Descriptor, path
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
-
-	fullPath := filepath.Join(d.File.Name(), path)
+	if d.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
 
 	// 默认权限 0755
-	err := os.Mkdir(fullPath, 0755)
+	err := d.Backend.MkdirAt(d.File, path, 0755)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -228,20 +258,7 @@ func (i *typesImpl) StatAt(ctx context.Context, this Descriptor, pathFlags PathF
 		return witgo.Err[DescriptorStat, ErrorCode](ErrorCodeBadDescriptor)
 	}
 
-	// 警告：这是一个简化的、不安全的实现，将在下一步中被沙箱化路径解析替换。
-	fullPath := filepath.Join(d.File.Name(), path)
-
-	var info fs.FileInfo
-	var err error
-
-	if pathFlags.SymlinkFollow {
-		// os.Stat 会跟随符号链接
-		info, err = os.Stat(fullPath)
-	} else {
-		// os.Lstat 不会跟随符号链接
-		info, err = os.Lstat(fullPath)
-	}
-
+	info, err := d.Backend.StatAt(d.File, path, pathFlags.SymlinkFollow)
 	if err != nil {
 		return witgo.Err[DescriptorStat, ErrorCode](mapOsError(err))
 	}
@@ -254,8 +271,9 @@ func (i *typesImpl) SetTimesAt(ctx context.Context, this Descriptor, pathFlags P
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
-
-	fullPath := filepath.Join(d.File.Name(), path)
+	if d.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
 
 	atime := time.Now() // 默认
 	mtime := time.Now() // 默认
@@ -269,7 +287,7 @@ func (i *typesImpl) SetTimesAt(ctx context.Context, this Descriptor, pathFlags P
 		mtime = time.Unix(int64(data_modification_timestamp.Timestamp.Seconds), int64(data_modification_timestamp.Timestamp.Nanoseconds))
 	}
 
-	err := os.Chtimes(fullPath, atime, mtime)
+	err := d.Backend.ChtimesAt(d.File, path, pathFlags.SymlinkFollow, atime, mtime)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -286,11 +304,12 @@ func (i *typesImpl) LinkAt(ctx context.Context, this Descriptor, oldPathFlags Pa
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
+	// 硬链接与原文件共享内容，从只读目录链接出去后就可以通过链接修改原文件。
+	if oldDir.ReadOnly || newDir.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
 
-	oldFullPath := filepath.Join(oldDir.File.Name(), oldPath)
-	newFullPath := filepath.Join(newDir.File.Name(), newPath)
-
-	err := os.Link(oldFullPath, newFullPath)
+	err := oldDir.Backend.LinkAt(oldDir.File, oldPath, oldPathFlags.SymlinkFollow, newDir.File, newPath)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -303,7 +322,10 @@ func (i *typesImpl) OpenAt(ctx context.Context, this Descriptor, pathFlags PathF
 		return witgo.Err[Descriptor, ErrorCode](ErrorCodeBadDescriptor)
 	}
 
-	fullPath := filepath.Join(d.File.Name(), path)
+	// 只读目录下不允许以任何可能修改文件系统的方式打开。
+	if d.ReadOnly && (flags.Write || flags.MutateDirectory || openFlags.Create || openFlags.Truncate) {
+		return witgo.Err[Descriptor, ErrorCode](ErrorCodeReadOnly)
+	}
 
 	var osFlags int
 	if flags.Read && flags.Write {
@@ -325,14 +347,17 @@ func (i *typesImpl) OpenAt(ctx context.Context, this Descriptor, pathFlags PathF
 	}
 
 	// 默认权限 0644
-	file, err := os.OpenFile(fullPath, osFlags, 0644)
+	file, err := d.Backend.OpenAt(d.File, path, pathFlags.SymlinkFollow, osFlags, 0644)
 	if err != nil {
 		return witgo.Err[Descriptor, ErrorCode](mapOsError(err))
 	}
 
 	newDesc := &filesystem.Descriptor{
-		File: file,
-		Path: path,
+		File:    file,
+		Backend: d.Backend,
+		Path:    path,
+		// 从只读目录打开的描述符同样是只读的。
+		ReadOnly: d.ReadOnly,
 	}
 	handle := i.host.FilesystemManager().Add(newDesc)
 	return witgo.Ok[Descriptor, ErrorCode](handle)
@@ -343,9 +368,7 @@ func (i *typesImpl) ReadlinkAt(ctx context.Context, this Descriptor, path string
 	if !ok {
 		return witgo.Err[string, ErrorCode](ErrorCodeBadDescriptor)
 	}
-	fullPath := filepath.Join(d.File.Name(), path)
-
-	target, err := os.Readlink(fullPath)
+	target, err := d.Backend.ReadlinkAt(d.File, path)
 	if err != nil {
 		return witgo.Err[string, ErrorCode](mapOsError(err))
 	}
@@ -357,10 +380,10 @@ func (i *typesImpl) RemoveDirectoryAt(ctx context.Context, this Descriptor, path
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
-	fullPath := filepath.Join(d.File.Name(), path)
-
-	// os.Remove 会删除空目录，但为了精确对应 rmdir，我们使用 syscall
-	err := syscall.Rmdir(fullPath)
+	if d.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
+	err := d.Backend.RmdirAt(d.File, path)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -376,11 +399,11 @@ func (i *typesImpl) RenameAt(ctx context.Context, this Descriptor, oldPath strin
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
+	if oldDir.ReadOnly || newDir.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
 
-	oldFullPath := filepath.Join(oldDir.File.Name(), oldPath)
-	newFullPath := filepath.Join(newDir.File.Name(), newPath)
-
-	err := os.Rename(oldFullPath, newFullPath)
+	err := oldDir.Backend.RenameAt(oldDir.File, oldPath, newDir.File, newPath)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -392,9 +415,10 @@ func (i *typesImpl) SymlinkAt(ctx context.Context, this Descriptor, oldPath stri
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
-	newFullPath := filepath.Join(d.File.Name(), newPath)
-
-	err := os.Symlink(oldPath, newFullPath)
+	if d.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
+	err := d.Backend.SymlinkAt(oldPath, d.File, newPath)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -406,14 +430,11 @@ func (i *typesImpl) UnlinkFileAt(ctx context.Context, this Descriptor, path stri
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeBadDescriptor)
 	}
-	fullPath := filepath.Join(d.File.Name(), path)
-
-	err := os.Remove(fullPath) // os.Remove 可以删除文件或空目录
+	if d.ReadOnly {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeReadOnly)
+	}
+	err := d.Backend.UnlinkAt(d.File, path)
 	if err != nil {
-		// 检查是否是目录，以返回正确的错误码
-		if stat, statErr := os.Stat(fullPath); statErr == nil && stat.IsDir() {
-			return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeIsDirectory)
-		}
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
@@ -426,13 +447,7 @@ func (i *typesImpl) IsSameObject(ctx context.Context, this Descriptor, other Des
 		return false
 	}
 
-	info1, err1 := d1.File.Stat()
-	info2, err2 := d2.File.Stat()
-	if err1 != nil || err2 != nil {
-		return false
-	}
-
-	return os.SameFile(info1, info2)
+	return filesystem.SameFile(d1.File, d2.File)
 }
 
 func (i *typesImpl) MetadataHash(ctx context.Context, this Descriptor) witgo.Result[MetadataHashValue, ErrorCode] {
@@ -486,9 +501,50 @@ func (i *typesImpl) FilesystemErrorCode(ctx context.Context, err WasiError) witg
 	return witgo.None[ErrorCode]()
 }
 
+// applyPermissions 根据描述符的只读属性修正从系统获取的 flags。
+// 可写的目录描述符才允许 Guest 修改目录内容（mutate-directory）。
+func applyPermissions(d *filesystem.Descriptor, flags DescriptorFlags) DescriptorFlags {
+	if d.ReadOnly {
+		flags.Write = false
+		flags.MutateDirectory = false
+		return flags
+	}
+	if info, err := d.File.Stat(); err == nil && info.IsDir() {
+		flags.MutateDirectory = true
+	}
+	return flags
+}
+
+// mapBackendError 将后端返回的错误映射为 ErrorCode。
+func mapBackendError(err error) (ErrorCode, bool) {
+	switch {
+	case errors.Is(err, filesystem.ErrNotPermitted):
+		return ErrorCodeNotPermitted, true
+	case errors.Is(err, filesystem.ErrLoop):
+		return ErrorCodeLoop, true
+	case errors.Is(err, filesystem.ErrIsDirectory):
+		return ErrorCodeIsDirectory, true
+	case errors.Is(err, filesystem.ErrNotDirectory):
+		return ErrorCodeNotDirectory, true
+	case errors.Is(err, filesystem.ErrNotEmpty):
+		return ErrorCodeNotEmpty, true
+	case errors.Is(err, filesystem.ErrReadOnly):
+		return ErrorCodeReadOnly, true
+	case errors.Is(err, filesystem.ErrCrossDevice):
+		return ErrorCodeCrossDevice, true
+	case errors.Is(err, filesystem.ErrBadDescriptor):
+		return ErrorCodeBadDescriptor, true
+	case errors.Is(err, filesystem.ErrFileTooLarge):
+		return ErrorCodeFileTooLarge, true
+	case errors.Is(err, filesystem.ErrNoSpace):
+		return ErrorCodeInsufficientSpace, true
+	}
+	return 0, false
+}
+
 // --- Helper: sectionWriter for WriteViaStream ---
 type sectionWriter struct {
-	w      *os.File
+	w      io.WriterAt
 	offset int64
 }
 
@@ -498,6 +554,19 @@ func (s *sectionWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// appendWriter 总是写入到文件末尾，用于 AppendViaStream。
+type appendWriter struct {
+	f filesystem.File
+}
+
+func (a *appendWriter) Write(p []byte) (int, error) {
+	info, err := a.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return a.f.WriteAt(p, info.Size())
+}
+
 type OsFileFlusher struct {
 	w interface{ Sync() error }
 }
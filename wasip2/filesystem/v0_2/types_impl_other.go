@@ -13,7 +13,12 @@ import (
 )
 
 func (i *typesImpl) GetFlags(ctx context.Context, this Descriptor) witgo.Result[DescriptorFlags, ErrorCode] {
-	return witgo.Err[DescriptorFlags, ErrorCode](ErrorCodeUnsupported)
+	d, ok := i.host.FilesystemManager().Get(this)
+	if !ok {
+		return witgo.Err[DescriptorFlags, ErrorCode](ErrorCodeBadDescriptor)
+	}
+	// 无法获取系统层面的打开模式，假设可读写，再根据只读属性修正。
+	return witgo.Ok[DescriptorFlags, ErrorCode](applyPermissions(d, DescriptorFlags{Read: true, Write: true}))
 }
 
 func goFileInfoToDescriptorStat(info fs.FileInfo) DescriptorStat {
@@ -61,6 +66,9 @@ func mapOsError(err error) ErrorCode {
 	if err == nil {
 		return 0
 	}
+	if code, ok := mapBackendError(err); ok {
+		return code
+	}
 	if errors.Is(err, fs.ErrPermission) {
 		return ErrorCodeAccess
 	}
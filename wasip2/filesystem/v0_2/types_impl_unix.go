@@ -9,6 +9,7 @@ import (
 	"os"
 	"syscall"
 
+	"github.com/OpenListTeam/wazero-wasip2/manager/filesystem"
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
 
 	"golang.org/x/sys/unix"
@@ -21,7 +22,13 @@ func (i *typesImpl) GetFlags(ctx context.Context, this Descriptor) witgo.Result[
 		return witgo.Err[DescriptorFlags, ErrorCode](ErrorCodeBadDescriptor)
 	}
 
-	flags, err := unix.FcntlInt(d.File.Fd(), unix.F_GETFL, 0)
+	file, ok := d.File.(*filesystem.OSFile)
+	if !ok {
+		// 虚拟后端没有系统层面的打开模式，假设可读写，再根据只读属性修正。
+		return witgo.Ok[DescriptorFlags, ErrorCode](applyPermissions(d, DescriptorFlags{Read: true, Write: true}))
+	}
+
+	flags, err := unix.FcntlInt(file.Fd(), unix.F_GETFL, 0)
 	if err != nil {
 		return witgo.Err[DescriptorFlags, ErrorCode](mapOsError(err))
 	}
@@ -45,7 +52,7 @@ func (i *typesImpl) GetFlags(ctx context.Context, this Descriptor) witgo.Result[
 		wasiFlags.RequestedWriteSync = true
 	}
 
-	return witgo.Ok[DescriptorFlags, ErrorCode](wasiFlags)
+	return witgo.Ok[DescriptorFlags, ErrorCode](applyPermissions(d, wasiFlags))
 }
 
 func timeToDatetime(ts syscall.Timespec) Datetime {
@@ -81,6 +88,9 @@ func mapOsError(err error) ErrorCode {
 	if err == nil {
 		return 0
 	}
+	if code, ok := mapBackendError(err); ok {
+		return code
+	}
 	if errors.Is(err, fs.ErrPermission) {
 		return ErrorCodeAccess
 	}
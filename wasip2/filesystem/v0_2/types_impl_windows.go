@@ -44,7 +44,7 @@ func (i *typesImpl) GetFlags(ctx context.Context, this Descriptor) witgo.Result[
 	wasiFlags.DataIntegritySync = false
 	wasiFlags.RequestedWriteSync = false
 
-	return witgo.Ok[DescriptorFlags, ErrorCode](wasiFlags)
+	return witgo.Ok[DescriptorFlags, ErrorCode](applyPermissions(d, wasiFlags))
 }
 
 // timeToDatetime has been corrected to prevent precision loss during conversion.
@@ -112,6 +112,9 @@ func mapOsError(err error) ErrorCode {
 	if err == nil {
 		return 0
 	}
+	if code, ok := mapBackendError(err); ok {
+		return code
+	}
 	if errors.Is(err, fs.ErrPermission) {
 		return ErrorCodeAccess
 	}
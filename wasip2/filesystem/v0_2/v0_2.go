@@ -66,10 +66,10 @@ func (i *wasiTypes) Instantiate(_ context.Context, h *wasip2.Host, b wazero.Host
 }
 
 // --- wasi:filesystem/preopens implementation ---
-type wasiPreopens struct{}
+type wasiPreopens struct{ cfg *Config }
 
-func NewPreopens() wasip2.Implementation {
-	return &wasiPreopens{}
+func NewPreopens(cfg *Config) wasip2.Implementation {
+	return &wasiPreopens{cfg: cfg}
 }
 
 func (i *wasiPreopens) Name() string { return "wasi:filesystem/preopens" }
@@ -78,7 +78,10 @@ func (i *wasiPreopens) Versions() []string {
 }
 
 func (i *wasiPreopens) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
-	handler := newPreopensImpl(h.FilesystemManager())
+	handler, err := newPreopensImpl(i.cfg, h)
+	if err != nil {
+		return err
+	}
 	exporter := witgo.NewExporter(b)
 	exporter.Export("get-directories", handler.GetDirectories)
 	return nil
@@ -1,21 +1,64 @@
 package wasi_http
 
 import (
+	"net/http"
+
 	"github.com/OpenListTeam/wazero-wasip2/wasip2"
 	v0_2 "github.com/OpenListTeam/wazero-wasip2/wasip2/http/v0_2"
 )
 
+// Option 用于配置 wasi:http 模块。
+type Option func(*v0_2.Config)
+
+// WithTransport 使用自定义的 RoundTripper 发送 Guest 的出站请求。
+// 此时 TLS、代理和连接池均由 rt 自己负责，WithClientConfig 中的对应设置不再生效。
+// EgressPolicy.BlockPrivateIPs 只能作用于 *http.Transport，rt 是其他类型时所有请求都会失败。
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *v0_2.Config) {
+		c.Transport = rt
+	}
+}
+
+// WithClientConfig 设置出站请求使用的 HTTP 客户端配置，例如根证书、客户端证书、代理、重定向策略和连接池大小。
+// 默认会校验服务端证书，并且不跟随重定向。
+func WithClientConfig(cfg v0_2.ClientConfig) Option {
+	return func(c *v0_2.Config) {
+		c.Client = cfg
+	}
+}
+
+// WithEgressPolicy 限制 Guest 可以发出的出站请求，例如只允许访问指定的域名，或禁止访问内网地址。
+// 被拒绝的请求会以 HTTP-request-denied 等错误码返回给 Guest。
+func WithEgressPolicy(policy v0_2.EgressPolicy) Option {
+	return func(c *v0_2.Config) {
+		c.Policy = policy
+	}
+}
+
+// WithForbiddenHeaders 禁止 Guest 设置指定的头部，例如 Host 或 Connection。
+// Guest 在 fields 中设置或删除这些头部时会得到 header-error.forbidden。
+func WithForbiddenHeaders(names ...string) Option {
+	return func(c *v0_2.Config) {
+		c.ForbiddenHeaders = append(c.ForbiddenHeaders, names...)
+	}
+}
+
 // Module 返回一个配置好的 wasi:http 模块选项。
-func Module(version string) wasip2.ModuleOption {
+func Module(version string, opts ...Option) wasip2.ModuleOption {
 	return func(h *wasip2.Host) {
+		cfg := &v0_2.Config{}
+		for _, opt := range opts {
+			opt(cfg)
+		}
+
 		var typesImpl, outgoingHandlerImpl, incomingHandlerImpl wasip2.Implementation
 
 		switch version {
 		case "0.2", "0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7":
 			// 创建 types 和 outgoing-handler 的实现实例，
-			// 并将 Host 中的管理器注入进去。
-			typesImpl = v0_2.NewTypes(h.HTTPManager())
-			outgoingHandlerImpl = v0_2.NewOutgoingHandler(h.HTTPManager())
+			// 并将 Host 中的管理器和配置注入进去。
+			typesImpl = v0_2.NewTypes(h.HTTPManager(), cfg)
+			outgoingHandlerImpl = v0_2.NewOutgoingHandler(h.HTTPManager(), cfg)
 			incomingHandlerImpl = v0_2.NewIncomingHandler(h.HTTPManager())
 		default:
 			return
@@ -2,102 +2,288 @@ package wasi_http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"maps"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
 	"github.com/OpenListTeam/wazero-wasip2/wasip2"
 	v0_2 "github.com/OpenListTeam/wazero-wasip2/wasip2/http/v0_2"
-	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
 
+	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 )
 
+const handleExport = "wasi:http/incoming-handler#handle"
+
 // Server 实现了 http.Handler，将传入的 HTTP 请求转发给 wasi-http guest 模块处理。
+//
+// 每个请求独占一个 Guest 实例：实例从池中取出，请求结束后归还；
+// Guest trap 或请求被取消后，实例的状态不再可信，会被关闭并在需要时重新创建。
 type Server struct {
-	guest      api.Module
-	wasiHost   *wasip2.Host
-	witHost    *witgo.Host
-	handleFunc api.Function
+	ctx      context.Context
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	wasiHost *wasip2.Host
+
+	moduleConfig   wazero.ModuleConfig
+	requestTimeout time.Duration
+
+	// idle 保存空闲的实例。
+	idle chan *serverInstance
+	// slots 限制同时存在的实例数量，为 nil 时不会创建新实例。
+	slots chan struct{}
+}
+
+// serverInstance 是池中的一个 Guest 实例。
+type serverInstance struct {
+	mod    api.Module
+	host   *wasip2.Host
+	handle api.Function
+}
+
+// ServerOption 用于配置 Server。
+type ServerOption func(*Server)
+
+// WithRequestTimeout 限制单个请求的处理时间，包括 Guest 写出响应体的时间。
+// 运行时需要使用 wazero.RuntimeConfig.WithCloseOnContextDone(true) 创建，超时才能中断正在执行的 Guest。
+func WithRequestTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.requestTimeout = d
+	}
+}
+
+// WithModuleConfig 设置创建 Guest 实例时使用的模块配置。
+// 池中的实例都是匿名的，配置中的模块名会被忽略。
+func WithModuleConfig(config wazero.ModuleConfig) ServerOption {
+	return func(s *Server) {
+		s.moduleConfig = config
+	}
+}
+
+// NewPoolServer 创建一个可以并发处理请求的 wasi-http 服务器。
+// compiled 必须导出 `wasi:http/incoming-handler.handle` 函数，最多同时存在 poolSize 个实例，
+// 每个实例都通过 wasiHost.InstantiateModule 创建，拥有独立的资源上下文。
+//
+// 客户端断开连接或请求超时会取消 Guest 的执行，这要求运行时使用
+// wazero.RuntimeConfig.WithCloseOnContextDone(true) 创建。
+func NewPoolServer(ctx context.Context, r wazero.Runtime, compiled wazero.CompiledModule, wasiHost *wasip2.Host, poolSize int, opts ...ServerOption) (*Server, error) {
+	if _, ok := compiled.ExportedFunctions()[handleExport]; !ok {
+		return nil, fmt.Errorf("guest module must export %s function", handleExport)
+	}
+	if poolSize <= 0 {
+		return nil, fmt.Errorf("invalid pool size %d", poolSize)
+	}
+
+	s := &Server{
+		ctx:          ctx,
+		runtime:      r,
+		compiled:     compiled,
+		wasiHost:     wasiHost,
+		moduleConfig: wazero.NewModuleConfig().WithStartFunctions("_initialize"),
+		idle:         make(chan *serverInstance, poolSize),
+		slots:        make(chan struct{}, poolSize),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
-// NewServer 创建一个新的 wasi-http 服务器实例。
+// NewServer 创建一个使用单个 Guest 实例的 wasi-http 服务器，请求会被逐个处理。
 // guest 模块必须导出一个 `wasi:http/incoming-handler.handle` 函数。
-func NewServer(guest api.Module, wasiHost *wasip2.Host) (*Server, error) {
-	handleFunc := guest.ExportedFunction("wasi:http/incoming-handler#handle")
+// 如果 guest 是通过 wasiHost.InstantiateModule 创建的，请求资源会被放入它独享的资源上下文中。
+//
+// 该实例无法被重新创建，即使 Guest trap 也会继续使用；需要并发处理请求时请使用 NewPoolServer。
+func NewServer(guest api.Module, wasiHost *wasip2.Host, opts ...ServerOption) (*Server, error) {
+	if inst, ok := wasiHost.Instance(guest); ok {
+		wasiHost = inst
+	}
+
+	handleFunc := guest.ExportedFunction(handleExport)
 	if handleFunc == nil {
-		return nil, fmt.Errorf("guest module must export wasi:http/incoming-handler#handle function")
+		return nil, fmt.Errorf("guest module must export %s function", handleExport)
+	}
+
+	s := &Server{
+		ctx:  context.Background(),
+		idle: make(chan *serverInstance, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.idle <- &serverInstance{mod: guest, host: wasiHost, handle: handleFunc}
+	return s, nil
+}
 
-	witHost, err := witgo.NewHost(guest)
+// Close 关闭池中所有空闲的实例。正在处理请求的实例会在请求结束后被归还，不受影响。
+func (s *Server) Close(ctx context.Context) error {
+	var err error
+	for {
+		select {
+		case inst := <-s.idle:
+			if s.slots != nil {
+				err = errors.Join(err, inst.mod.Close(ctx))
+				<-s.slots
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// acquire 取出一个空闲的实例，池未满时创建新实例，否则等待其它请求归还。
+func (s *Server) acquire(ctx context.Context) (*serverInstance, error) {
+	select {
+	case inst := <-s.idle:
+		return inst, nil
+	default:
+	}
+
+	select {
+	case inst := <-s.idle:
+		return inst, nil
+	case s.slots <- struct{}{}:
+		inst, err := s.instantiate()
+		if err != nil {
+			<-s.slots
+			return nil, err
+		}
+		return inst, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *Server) instantiate() (*serverInstance, error) {
+	mod, err := s.wasiHost.InstantiateModule(s.ctx, s.runtime, s.compiled, s.moduleConfig.WithName(""))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create wit-go host: %w", err)
+		return nil, fmt.Errorf("failed to instantiate guest: %w", err)
+	}
+	host, ok := s.wasiHost.Instance(mod)
+	if !ok {
+		mod.Close(s.ctx)
+		return nil, fmt.Errorf("guest exited during instantiation")
 	}
+	return &serverInstance{mod: mod, host: host, handle: mod.ExportedFunction(handleExport)}, nil
+}
 
-	return &Server{
-		guest:      guest,
-		wasiHost:   wasiHost,
-		witHost:    witHost,
-		handleFunc: handleFunc,
-	}, nil
+// release 在 Guest 的 handle 调用结束后归还实例；调用失败时实例会被丢弃。
+func (s *Server) release(inst *serverInstance, callErr error) {
+	if s.slots != nil && (callErr != nil || inst.mod.IsClosed()) {
+		inst.mod.Close(s.ctx)
+		<-s.slots
+		return
+	}
+	s.idle <- inst
 }
 
 // ServeHTTP 是 http.Handler 接口的实现。
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	hm := s.wasiHost.HTTPManager()
+	if s.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.requestTimeout)
+		defer cancel()
+	}
 
-	// 1. 将 http.Request 转换为 wasi:http/types.incoming-request
-	reqHandle, err := s.createIncomingRequest(ctx, r)
+	inst, err := s.acquire(ctx)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to create incoming-request: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("no guest instance available: %v", err), http.StatusServiceUnavailable)
 		return
 	}
+	hm := inst.host.HTTPManager()
+
+	// 1. 将 http.Request 转换为 wasi:http/types.incoming-request
+	reqHandle := s.createIncomingRequest(hm, r)
 
 	// 2. 创建一个 response-outparam
 	respChan := make(chan any, 1)
-	outparam := &manager_http.ResponseOutparam{ResultChan: respChan}
-	outparamHandle := hm.ResponseOutparams.Add(outparam)
-	defer hm.ResponseOutparams.Remove(outparamHandle)
+	outparamHandle := hm.ResponseOutparams.Add(&manager_http.ResponseOutparam{ResultChan: respChan})
 
-	// 3. 调用 guest 的 handle 函数
-	_, err = s.handleFunc.Call(ctx, uint64(reqHandle), uint64(outparamHandle))
-	if err != nil {
-		// Guest 模块执行出错 (trap)
-		http.Error(w, fmt.Sprintf("guest handle function trapped: %v", err), http.StatusInternalServerError)
-		return
-	}
+	// 3. 在后台调用 guest 的 handle 函数，响应头在 response-outparam.set 时就写出，
+	//    响应体随后在 Guest 写入的同时流式发送。
+	done := make(chan error, 1)
+	go func() {
+		_, err := inst.handle.Call(ctx, uint64(reqHandle), uint64(outparamHandle))
+		// handle 返回（包括 trap）之后 Guest 不会再调用 finish，关闭未完成的 Body 让正在转发的响应中止，
+		// 也避免它们留在被回收的实例中。已经 finish 的 Body 不在表中，不受影响。
+		hm.Bodies.Clear()
+		done <- err
+	}()
+
+	// 无论请求如何结束，都要等 Guest 返回后再归还实例；提前返回时在后台等待。
+	finished := false
+	var callErr error
+	defer func() {
+		if finished {
+			hm.ResponseOutparams.Remove(outparamHandle)
+			s.release(inst, callErr)
+			return
+		}
+		go func() {
+			err := <-done
+			hm.ResponseOutparams.Remove(outparamHandle)
+			s.release(inst, errors.Join(err, ctx.Err()))
+		}()
+	}()
 
 	// 4. 等待 guest 通过 response-outparam.set 返回结果
 	select {
 	case <-ctx.Done():
-		http.Error(w, "context cancelled", http.StatusServiceUnavailable)
-	case respResult := <-respChan:
-		switch result := respResult.(type) {
-		case v0_2.OutgoingResponse:
-			s.writeOutgoingResponse(w, result)
-		case v0_2.ErrorCode:
-			// Guest 返回了一个错误
-			http.Error(w, fmt.Sprintf("guest returned an error code: %+v", result), http.StatusInternalServerError)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			http.Error(w, "guest handle timed out", http.StatusGatewayTimeout)
+		} else {
+			http.Error(w, "context cancelled", http.StatusServiceUnavailable)
+		}
+	case callErr = <-done:
+		finished = true
+		select {
+		case result := <-respChan:
+			// Guest 在返回之前设置了响应
+			s.writeResult(ctx, w, hm, result)
 		default:
-			http.Error(w, "internal error: unknown type from response channel", http.StatusInternalServerError)
+			if callErr != nil {
+				// Guest 模块执行出错 (trap)
+				http.Error(w, fmt.Sprintf("guest handle function trapped: %v", callErr), http.StatusInternalServerError)
+			} else {
+				http.Error(w, "guest returned without setting a response", http.StatusInternalServerError)
+			}
+		}
+	case result := <-respChan:
+		s.writeResult(ctx, w, hm, result)
+		select {
+		case callErr = <-done:
+			finished = true
+		case <-ctx.Done():
 		}
 	}
 }
 
-// createIncomingRequest 将 http.Request 转换为 wasi:http/types.incoming-request 资源
-func (s *Server) createIncomingRequest(ctx context.Context, r *http.Request) (v0_2.IncomingRequest, error) {
-	hm := s.wasiHost.HTTPManager()
+// writeResult 根据 response-outparam.set 的结果写出响应。
+func (s *Server) writeResult(ctx context.Context, w http.ResponseWriter, hm *manager_http.HTTPManager, result any) {
+	switch result := result.(type) {
+	case v0_2.OutgoingResponse:
+		s.writeOutgoingResponse(ctx, w, hm, result)
+	case v0_2.ErrorCode:
+		// Guest 返回了一个错误
+		http.Error(w, fmt.Sprintf("guest returned an error code: %+v", result), http.StatusInternalServerError)
+	case nil:
+		// response-outparam 在 set 之前被丢弃
+		http.Error(w, "guest dropped response-outparam without setting a response", http.StatusInternalServerError)
+	default:
+		http.Error(w, "internal error: unknown type from response channel", http.StatusInternalServerError)
+	}
+}
 
+// createIncomingRequest 将 http.Request 转换为 wasi:http/types.incoming-request 资源
+func (s *Server) createIncomingRequest(hm *manager_http.HTTPManager, r *http.Request) v0_2.IncomingRequest {
 	// 创建 Headers
-	headers := make(manager_http.Fields)
-	for k, v := range r.Header {
-		headers[strings.ToLower(k)] = v
-	}
-	headersHandle := hm.Fields.Add(headers)
+	headersHandle := hm.Fields.AddImmutable(manager_http.FieldsFromHeader(r.Header))
 
 	// 构造最终的 IncomingRequest
 	scheme := "http"
@@ -116,27 +302,89 @@ func (s *Server) createIncomingRequest(ctx context.Context, r *http.Request) (v0
 
 		Body: r.Body,
 	}
-	reqHandle := hm.IncomingRequests.Add(req)
-	return reqHandle, nil
+	return hm.IncomingRequests.Add(req)
 }
 
 // writeOutgoingResponse 将 guest 返回的 OutgoingResponse 写入 http.ResponseWriter
-func (s *Server) writeOutgoingResponse(w http.ResponseWriter, respHandle v0_2.OutgoingResponse) {
-	hm := s.wasiHost.HTTPManager()
+func (s *Server) writeOutgoingResponse(ctx context.Context, w http.ResponseWriter, hm *manager_http.HTTPManager, respHandle v0_2.OutgoingResponse) {
 	resp, ok := hm.OutgoingResponses.Pop(respHandle)
 	if !ok {
 		http.Error(w, "internal error: invalid outgoing-response handle", http.StatusInternalServerError)
 		return
 	}
+	// 停止读取后关闭管道，让 Guest 之后的写入失败而不是阻塞。
+	// 请求被取消时同样关闭管道，以免在 Guest 不再写入时一直等待。
+	defer resp.Close()
+	stop := context.AfterFunc(ctx, func() { resp.Close() })
+	defer stop()
 	resp.Response = w
 
-	maps.Copy(w.Header(), resp.Headers)
+	// 头部名称经由 Add 规范化，net/http 才能识别 Content-Length 和 Trailer。
+	// 声明了长度的响应不会使用 chunked 编码，在 Trailer 头中声明的 trailers 由 net/http 负责发送。
+	announced := make(map[string]bool)
+	for _, e := range resp.Headers.Entries() {
+		switch {
+		case strings.EqualFold(e.Name, "Content-Length"):
+			continue
+		case strings.EqualFold(e.Name, "Trailer"):
+			for _, key := range strings.Split(e.Value, ",") {
+				announced[http.CanonicalHeaderKey(strings.TrimSpace(key))] = true
+			}
+		}
+		w.Header().Add(e.Name, e.Value)
+	}
+	if cl := manager_http.ContentLength(resp.Headers); cl != nil {
+		w.Header().Set("Content-Length", strconv.FormatUint(*cl, 10))
+	}
 
-	// 写入 Status Code
+	// 立即写出状态码和响应头
 	w.WriteHeader(resp.StatusCode)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
 
-	// 写入 Body
+	// 边读边写 Body
 	if resp.Body != nil {
-		io.Copy(w, resp.Body)
+		if err := copyAndFlush(ctx, w, flusher, resp.Body); err != nil {
+			// Body 没有正常结束（Guest 没有调用 finish 或者 trap），中止响应，
+			// 避免客户端把不完整的 Body 当作完整的。
+			panic(http.ErrAbortHandler)
+		}
+	}
+
+	// Body 结束之前 Guest 已经通过 outgoing-body.finish 设置了 trailers。
+	// 声明过的直接写入，否则使用 http.TrailerPrefix 在发送响应头之后追加。
+	for _, e := range resp.Trailers.Entries() {
+		key := http.CanonicalHeaderKey(e.Name)
+		if !announced[key] {
+			key = http.TrailerPrefix + key
+		}
+		w.Header().Add(key, e.Value)
+	}
+}
+
+// copyAndFlush 将 Guest 写入的 Body 转发给客户端，每次写入后立即 Flush。
+func copyAndFlush(ctx context.Context, w io.Writer, flusher http.Flusher, body io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 	}
 }
@@ -0,0 +1,315 @@
+package wasi_http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
+	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+)
+
+// testHandle 在 Host 侧代替 Guest 处理一个请求，hm 是该实例的资源上下文。
+type testHandle func(ctx context.Context, hm *manager_http.HTTPManager, req, out uint32)
+
+// testHandler 是一个 wasip2.Implementation，导出的 handle 调用 testHandle。
+// 它记录了每个实例的资源上下文，用于检查实例是否被复用。
+type testHandler struct {
+	handle testHandle
+
+	mu        sync.Mutex
+	instances []*wasip2.Host
+}
+
+func (i *testHandler) Name() string       { return "test:http/handler" }
+func (i *testHandler) Versions() []string { return []string{"0.1.0"} }
+func (i *testHandler) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
+	i.mu.Lock()
+	i.instances = append(i.instances, h)
+	i.mu.Unlock()
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, req, out uint32) {
+		i.handle(ctx, h.HTTPManager(), req, out)
+	}).Export("handle")
+	return nil
+}
+
+func (i *testHandler) instanceCount() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.instances)
+}
+
+// handlerGuest 是一个只导出 incoming-handler 的 Guest，handle 直接转发给 test:http/handler。
+var handlerGuest = func() []byte {
+	section := func(id byte, content ...byte) []byte {
+		return append([]byte{id, byte(len(content))}, content...)
+	}
+	name := func(s string) []byte {
+		return append([]byte{byte(len(s))}, s...)
+	}
+	var wasm []byte
+	wasm = append(wasm, "\x00asm\x01\x00\x00\x00"...)
+	// (i32, i32) -> ()
+	wasm = append(wasm, section(1, 1, 0x60, 2, 0x7f, 0x7f, 0)...)
+	imp := append(append([]byte{1}, name("test:http/handler@0.1.0")...), name("handle")...)
+	wasm = append(wasm, section(2, append(imp, 0x00, 0)...)...)
+	wasm = append(wasm, section(3, 1, 0)...)
+	exp := append([]byte{1}, name(handleExport)...)
+	wasm = append(wasm, section(7, append(exp, 0x00, 1)...)...)
+	wasm = append(wasm, section(10, 1, 8, 0, 0x20, 0, 0x20, 1, 0x10, 0, 0x0b)...)
+	return wasm
+}()
+
+// newPoolServer 创建一个由 handle 处理请求的 Server，并通过 httptest 启动它。
+func newPoolServer(t *testing.T, handle testHandle, poolSize int, opts ...ServerOption) (*httptest.Server, *testHandler) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	t.Cleanup(func() { r.Close(ctx) })
+	compiled, err := r.CompileModule(ctx, handlerGuest)
+	require.NoError(t, err)
+
+	handler := &testHandler{handle: handle}
+	h := wasip2.NewHost(func(h *wasip2.Host) { h.AddImplementation(handler) })
+	s, err := NewPoolServer(ctx, r, compiled, h, poolSize, opts...)
+	require.NoError(t, err)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return srv, handler
+}
+
+// respond 像 Guest 一样通过 response-outparam 设置响应，返回响应体的写入端和 outgoing-body 句柄。
+func respond(hm *manager_http.HTTPManager, out uint32) (*io.PipeWriter, uint32) {
+	resp := &manager_http.OutgoingResponse{StatusCode: http.StatusOK, Headers: manager_http.NewFields()}
+	resp.BodyHandle, resp.Body, resp.BodyWriter = hm.NewOutgoingBody(nil, true, nil)
+	outparam, _ := hm.ResponseOutparams.Pop(out)
+	outparam.ResultChan <- hm.OutgoingResponses.Add(resp)
+	return resp.BodyWriter, resp.BodyHandle
+}
+
+// finish 像 outgoing-body.finish 一样结束响应体。
+func finish(hm *manager_http.HTTPManager, body uint32) {
+	b, _ := hm.Bodies.Pop(body)
+	b.BodyWriter.Close()
+}
+
+// respondText 以 text 作为完整的响应体。
+func respondText(hm *manager_http.HTTPManager, out uint32, text string) {
+	w, body := respond(hm, out)
+	io.WriteString(w, text)
+	finish(hm, body)
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestWriteOutgoingResponseTrailers(t *testing.T) {
+	sm, pm, _ := manager_io.NewManager()
+	hm := manager_http.NewHTTPManager(sm, pm)
+	t.Cleanup(hm.Clear)
+
+	s := &Server{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers := manager_http.NewFields()
+		headers.Append("trailer", "x-announced")
+		resp := &manager_http.OutgoingResponse{StatusCode: http.StatusOK, Headers: headers}
+		_, resp.Body, resp.BodyWriter = hm.NewOutgoingBody(nil, true, nil)
+
+		// 像 outgoing-body.finish 一样，在关闭 Body 之前设置 trailers
+		go func() {
+			io.WriteString(resp.BodyWriter, "hello")
+			trailers := manager_http.NewFields()
+			trailers.Append("x-announced", "1")
+			trailers.Append("x-extra", "2")
+			resp.Trailers = trailers
+			resp.BodyWriter.Close()
+		}()
+		s.writeOutgoingResponse(r.Context(), w, hm, hm.OutgoingResponses.Add(resp))
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(body))
+
+	// 声明过的和没有声明的 trailers 都在 Body 之后发送
+	require.Equal(t, "1", resp.Trailer.Get("X-Announced"))
+	require.Equal(t, "2", resp.Trailer.Get("X-Extra"))
+}
+
+func TestPoolServerReusesInstances(t *testing.T) {
+	var active, maxActive atomic.Int32
+	srv, handler := newPoolServer(t, func(ctx context.Context, hm *manager_http.HTTPManager, req, out uint32) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		respondText(hm, out, "ok")
+	}, 2)
+
+	// 超过池大小的请求等待空闲的实例，而不是创建新实例
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, body := get(t, srv.URL)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, "ok", body)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), maxActive.Load())
+	require.Equal(t, 2, handler.instanceCount())
+}
+
+func TestPoolServerReplacesTrappedInstance(t *testing.T) {
+	var hosts []*manager_http.HTTPManager
+	srv, handler := newPoolServer(t, func(ctx context.Context, hm *manager_http.HTTPManager, req, out uint32) {
+		hosts = append(hosts, hm)
+		if len(hosts) == 1 {
+			panic("trap")
+		}
+		respondText(hm, out, "ok")
+	}, 1)
+
+	status, _ := get(t, srv.URL)
+	require.Equal(t, http.StatusInternalServerError, status)
+
+	// trap 之后的实例被丢弃，下一个请求使用新创建的实例
+	status, body := get(t, srv.URL)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ok", body)
+	require.Equal(t, 2, handler.instanceCount())
+	require.NotSame(t, hosts[0], hosts[1])
+}
+
+func TestPoolServerRequestTimeout(t *testing.T) {
+	srv, _ := newPoolServer(t, func(ctx context.Context, hm *manager_http.HTTPManager, req, out uint32) {
+		<-ctx.Done()
+	}, 1, WithRequestTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	status, _ := get(t, srv.URL)
+	require.Equal(t, http.StatusGatewayTimeout, status)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestPoolServerStreamsBody(t *testing.T) {
+	next := make(chan struct{})
+	srv, _ := newPoolServer(t, func(ctx context.Context, hm *manager_http.HTTPManager, req, out uint32) {
+		w, body := respond(hm, out)
+		io.WriteString(w, "first")
+		// 客户端收到第一段之后才写入第二段
+		select {
+		case <-next:
+		case <-ctx.Done():
+			return
+		}
+		io.WriteString(w, "second")
+		finish(hm, body)
+	}, 1)
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	buf := make([]byte, 16)
+	n, err := resp.Body.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "first", string(buf[:n]))
+	close(next)
+	rest, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(rest))
+}
+
+func TestPoolServerClientDisconnect(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	var calls atomic.Int32
+	srv, _ := newPoolServer(t, func(ctx context.Context, hm *manager_http.HTTPManager, req, out uint32) {
+		if calls.Add(1) > 1 {
+			respondText(hm, out, "ok")
+			return
+		}
+		close(started)
+		select {
+		case <-ctx.Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	require.NoError(t, err)
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err = http.DefaultClient.Do(req)
+	require.Error(t, err)
+
+	// 客户端断开后 Guest 的调用被取消，实例被回收后可以继续处理请求
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("guest was not cancelled after the client disconnected")
+	}
+	status, body := get(t, srv.URL)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ok", body)
+}
+
+func TestPoolServerUnfinishedBody(t *testing.T) {
+	var hosts []*manager_http.HTTPManager
+	srv, _ := newPoolServer(t, func(ctx context.Context, hm *manager_http.HTTPManager, req, out uint32) {
+		hosts = append(hosts, hm)
+		if len(hosts) > 1 {
+			respondText(hm, out, "ok")
+			return
+		}
+		// 没有 finish 也没有丢弃 outgoing-body 就返回
+		w, _ := respond(hm, out)
+		io.WriteString(w, "partial")
+	}, 1)
+
+	// Body 没有被关闭时读取会一直阻塞到客户端超时
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// 响应中止时未完成的 Body 已经从实例中清除，实例可以继续处理请求
+	hosts[0].Bodies.Range(func(uint32, *manager_http.OutgoingBody) bool {
+		t.Error("unfinished body left in the instance")
+		return false
+	})
+	status, body := get(t, srv.URL)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ok", body)
+}
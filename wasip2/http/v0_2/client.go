@@ -0,0 +1,201 @@
+package v0_2
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	gohttp "net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+// Config 保存 wasi:http 模块的 Host 端配置。
+type Config struct {
+	// Transport 不为 nil 时用于发送 Guest 的所有出站请求，此时 Client 中的 TLS、代理和连接池设置不生效。
+	// request-options 中的连接和首字节超时依赖 net/http/httptrace，自定义的 Transport 需要支持它才能生效。
+	Transport gohttp.RoundTripper
+	// Client 在 Transport 为 nil 时用于构建默认的 Transport。
+	Client ClientConfig
+	// Policy 限制 Guest 可以发出的出站请求。
+	Policy EgressPolicy
+	// ForbiddenHeaders 中的头部不允许 Guest 通过 fields 设置或删除，名称不区分大小写。
+	ForbiddenHeaders []string
+
+	once   sync.Once
+	client *gohttp.Client
+}
+
+// ClientConfig 配置 Host 代替 Guest 发送出站请求时使用的 HTTP 客户端。
+// 零值即为安全的默认配置：校验服务端证书、按环境变量使用代理、不跟随重定向。
+type ClientConfig struct {
+	// RootCAs 为 nil 时使用系统根证书。
+	RootCAs *x509.CertPool
+	// Certificates 是双向 TLS 时向服务端出示的客户端证书。
+	Certificates []tls.Certificate
+	// InsecureSkipVerify 关闭服务端证书校验，只应在测试环境中使用。
+	InsecureSkipVerify bool
+
+	// Proxy 为 nil 时使用 http.ProxyFromEnvironment。
+	// 使用 http.ProxyURL(u) 指定固定的代理，http.ProxyURL(nil) 表示不使用代理。
+	Proxy func(*gohttp.Request) (*url.URL, error)
+
+	// CheckRedirect 为 nil 时不跟随重定向，3xx 响应原样交给 Guest 处理。
+	// 被跟随的重定向在 CheckRedirect 通过后还会经过 Config.Policy 的检查。
+	CheckRedirect func(req *gohttp.Request, via []*gohttp.Request) error
+
+	// 连接池设置，零值表示使用 http.DefaultTransport 的默认值。
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+
+	// PreserveHeaderOrder 为 true 时按 Guest 设置的顺序和大小写发送请求头，包括跟随重定向产生的请求。
+	// 默认使用的 net/http 总是会规范化头部名称并按字母顺序发送，只保证头部的值和同名头部之间的顺序。
+	// 开启后改用内置的 HTTP/1.1 实现：仍然使用 Proxy 指定的 http 或 https 代理，
+	// 但不支持 HTTP/2，每个请求使用一个新的连接，连接池设置不再生效。
+	PreserveHeaderOrder bool
+}
+
+// FollowRedirects 返回一个最多跟随 max 次重定向的 CheckRedirect。
+func FollowRedirects(max int) func(req *gohttp.Request, via []*gohttp.Request) error {
+	return func(req *gohttp.Request, via []*gohttp.Request) error {
+		if len(via) > max {
+			return gohttp.ErrUseLastResponse
+		}
+		return nil
+	}
+}
+
+// newTransport 按 ClientConfig 构建 Transport。
+func (c *ClientConfig) newTransport() *gohttp.Transport {
+	transport := gohttp.DefaultTransport.(*gohttp.Transport).Clone()
+
+	transport.Proxy = c.proxy()
+	transport.TLSClientConfig = c.tlsConfig()
+
+	if c.MaxIdleConns > 0 {
+		transport.MaxIdleConns = c.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	if c.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = c.MaxConnsPerHost
+	}
+	if c.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = c.IdleConnTimeout
+	}
+	return transport
+}
+
+func (c *ClientConfig) proxy() func(*gohttp.Request) (*url.URL, error) {
+	if c.Proxy == nil {
+		return gohttp.ProxyFromEnvironment
+	}
+	return c.Proxy
+}
+
+func (c *ClientConfig) tlsConfig() *tls.Config {
+	return &tls.Config{
+		RootCAs:            c.RootCAs,
+		Certificates:       c.Certificates,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
+
+// errBlockPrivateIPsUnsupported 表示 BlockPrivateIPs 无法作用于 Config.Transport。
+var errBlockPrivateIPsUnsupported = &codeError{
+	msg:  "wasi-http: BlockPrivateIPs requires Transport to be nil or an *http.Transport",
+	code: ErrorCode{ConfigurationError: &witgo.Unit{}},
+}
+
+// errTransport 是一个总是返回 err 的 RoundTripper。
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(req *gohttp.Request) (*gohttp.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// httpClient 返回发送出站请求使用的客户端，所有请求共用同一个客户端，超时按请求单独处理。
+func (c *Config) httpClient() *gohttp.Client {
+	c.once.Do(func() {
+		var transport gohttp.RoundTripper
+		switch t := c.Transport.(type) {
+		case nil:
+			dialer := &net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}
+			if c.Policy.BlockPrivateIPs {
+				dialer.Control = c.Policy.control
+			}
+			if c.Client.PreserveHeaderOrder {
+				transport = &rawTransport{dialer: dialer, tlsConfig: c.Client.tlsConfig(), proxy: c.Client.proxy()}
+				break
+			}
+			base := c.Client.newTransport()
+			base.DialContext = dialer.DialContext
+			transport = base
+		case *gohttp.Transport:
+			transport = t
+			if c.Policy.BlockPrivateIPs {
+				// 不修改调用者传入的 Transport
+				t = t.Clone()
+				dial := t.DialContext
+				if dial == nil && t.Dial != nil {
+					dial = func(_ context.Context, network, addr string) (net.Conn, error) { return t.Dial(network, addr) }
+				}
+				if dial == nil {
+					dial = (&net.Dialer{}).DialContext
+				}
+				t.DialContext = c.Policy.checkConn(dial)
+				// 自定义的 TLS 拨号函数会绕过 DialContext，同样需要检查
+				dialTLS := t.DialTLSContext
+				if dialTLS == nil && t.DialTLS != nil {
+					dialTLS = func(_ context.Context, network, addr string) (net.Conn, error) { return t.DialTLS(network, addr) }
+				}
+				if dialTLS != nil {
+					t.DialTLSContext = c.Policy.checkConn(dialTLS)
+				}
+				transport = t
+			}
+		default:
+			transport = t
+			if c.Policy.BlockPrivateIPs {
+				// 无法检查其他 RoundTripper 建立的连接，拒绝发送请求而不是静默地放行
+				transport = errTransport{err: errBlockPrivateIPsUnsupported}
+			}
+		}
+
+		checkRedirect := func(req *gohttp.Request, via []*gohttp.Request) error {
+			// 重定向默认由 Guest 处理
+			return gohttp.ErrUseLastResponse
+		}
+		if c.Client.CheckRedirect != nil {
+			// Host 跟随的重定向同样要经过出站策略，并按策略改写请求头。
+			checkRedirect = func(req *gohttp.Request, via []*gohttp.Request) error {
+				if err := c.Client.CheckRedirect(req, via); err != nil {
+					return err
+				}
+				if code := c.Policy.check(req); code != nil {
+					return &codeError{msg: "redirect to " + req.URL.Redacted() + " denied by egress policy", code: *code}
+				}
+				return nil
+			}
+		}
+		c.client = &gohttp.Client{
+			Transport:     transport,
+			CheckRedirect: checkRedirect,
+		}
+	})
+	return c.client
+}
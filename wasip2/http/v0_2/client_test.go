@@ -0,0 +1,98 @@
+package v0_2
+
+import (
+	"context"
+	"crypto/x509"
+	gohttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripperFunc 让函数实现 http.RoundTripper。
+type roundTripperFunc func(*gohttp.Request) (*gohttp.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *gohttp.Request) (*gohttp.Response, error) {
+	return f(req)
+}
+
+// get 发送 GET 请求，返回响应的状态码。
+func (g *testGuest) get(rawURL string) uint16 {
+	result := g.send(g.newRequest("GET", rawURL), witgo.None[RequestOptions]())
+	require.Nil(g.t, result.Err)
+	defer g.responses.Drop(context.Background(), *result.Ok)
+	return g.responses.Status(context.Background(), *result.Ok)
+}
+
+func TestClientTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {}))
+	defer srv.Close()
+
+	// 默认校验服务端证书
+	g := newTestGuest(t, &Config{})
+	result := g.send(g.newRequest("GET", srv.URL), witgo.None[RequestOptions]())
+	require.NotNil(t, result.Err)
+	require.NotNil(t, result.Err.TLSCertificateError)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	g = newTestGuest(t, &Config{Client: ClientConfig{RootCAs: roots}})
+	require.Equal(t, uint16(gohttp.StatusOK), g.get(srv.URL))
+
+	g = newTestGuest(t, &Config{Client: ClientConfig{InsecureSkipVerify: true}})
+	require.Equal(t, uint16(gohttp.StatusOK), g.get(srv.URL))
+}
+
+func TestClientRedirects(t *testing.T) {
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		switch r.URL.Path {
+		case "/a":
+			gohttp.Redirect(w, r, "/b", gohttp.StatusFound)
+		case "/b":
+			gohttp.Redirect(w, r, "/c", gohttp.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	// 默认不跟随重定向，3xx 交给 Guest
+	g := newTestGuest(t, &Config{})
+	require.Equal(t, uint16(gohttp.StatusFound), g.get(srv.URL+"/a"))
+
+	g = newTestGuest(t, &Config{Client: ClientConfig{CheckRedirect: FollowRedirects(2)}})
+	require.Equal(t, uint16(gohttp.StatusOK), g.get(srv.URL+"/a"))
+	g = newTestGuest(t, &Config{Client: ClientConfig{CheckRedirect: FollowRedirects(1)}})
+	require.Equal(t, uint16(gohttp.StatusFound), g.get(srv.URL+"/a"))
+}
+
+func TestClientProxy(t *testing.T) {
+	var target string
+	proxy := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		target = r.RequestURI
+		w.WriteHeader(gohttp.StatusTeapot)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	g := newTestGuest(t, &Config{Client: ClientConfig{Proxy: gohttp.ProxyURL(proxyURL)}})
+	require.Equal(t, uint16(gohttp.StatusTeapot), g.get("http://example.com/path"))
+	require.Equal(t, "http://example.com/path", target)
+}
+
+func TestClientTransport(t *testing.T) {
+	var got *gohttp.Request
+	transport := roundTripperFunc(func(req *gohttp.Request) (*gohttp.Response, error) {
+		got = req
+		return &gohttp.Response{StatusCode: gohttp.StatusAccepted, Header: gohttp.Header{}, Body: gohttp.NoBody, Request: req}, nil
+	})
+
+	// 配置的 Transport 代替默认的客户端发送所有请求
+	g := newTestGuest(t, &Config{Transport: transport})
+	require.Equal(t, uint16(gohttp.StatusAccepted), g.get("https://example.com/path?q=1"))
+	require.NotNil(t, got)
+	require.Equal(t, "https://example.com/path?q=1", got.URL.String())
+}
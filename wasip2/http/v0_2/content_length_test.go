@@ -0,0 +1,121 @@
+package v0_2
+
+import (
+	"context"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireBodySize 检查 err 被归类为 Body 长度错误，payload 为 size。
+func requireBodySize(t *testing.T, err error, response bool, size uint64) {
+	t.Helper()
+	code, ok := classifyError(err)
+	require.True(t, ok)
+	if response {
+		require.Equal(t, ErrorCode{HTTPResponseBodySize: witgo.SomePtr(size)}, code)
+	} else {
+		require.Equal(t, ErrorCode{HTTPRequestBodySize: witgo.SomePtr(size)}, code)
+	}
+}
+
+func TestRequestContentLength(t *testing.T) {
+	type received struct {
+		contentLength    int64
+		transferEncoding []string
+		body             string
+	}
+	requests := make(chan received, 1)
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{r.ContentLength, r.TransferEncoding, string(body)}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	t.Run("declared", func(t *testing.T) {
+		// 声明了长度的请求不使用 chunked 编码
+		g := newTestGuest(t, &Config{})
+		req := g.newRequest("POST", srv.URL, "Content-Length", "5")
+		body := g.requests.Body(ctx, req)
+		require.NotNil(t, body.Ok)
+		handled := g.handler.Handle(req, witgo.None[RequestOptions]())
+		require.NotNil(t, handled.Ok)
+		require.NoError(t, g.write(*body.Ok, []byte("hello")))
+		require.Nil(t, g.bodies.Finish(ctx, *body.Ok, witgo.None[Fields]()).Err)
+		require.Nil(t, g.await(*handled.Ok).Err)
+
+		got := <-requests
+		require.Equal(t, int64(5), got.contentLength)
+		require.Empty(t, got.transferEncoding)
+		require.Equal(t, "hello", got.body)
+	})
+
+	t.Run("overrun", func(t *testing.T) {
+		// 超过声明长度的写入直接失败，payload 是试图写入的总长度
+		g := newTestGuest(t, &Config{})
+		req := g.newRequest("POST", srv.URL, "Content-Length", "3")
+		body := g.requests.Body(ctx, req)
+		require.NotNil(t, body.Ok)
+		requireBodySize(t, g.write(*body.Ok, []byte("hello")), false, 5)
+	})
+
+	t.Run("short", func(t *testing.T) {
+		// 写入的内容不足声明的长度时 finish 失败，payload 是实际写入的长度
+		g := newTestGuest(t, &Config{})
+		req := g.newRequest("POST", srv.URL, "Content-Length", "10")
+		body := g.requests.Body(ctx, req)
+		require.NotNil(t, body.Ok)
+		handled := g.handler.Handle(req, witgo.None[RequestOptions]())
+		require.NotNil(t, handled.Ok)
+		defer g.futures.Drop(ctx, *handled.Ok)
+		require.NoError(t, g.write(*body.Ok, []byte("hello")))
+		finished := g.bodies.Finish(ctx, *body.Ok, witgo.None[Fields]())
+		require.NotNil(t, finished.Err)
+		require.Equal(t, ErrorCode{HTTPRequestBodySize: witgo.SomePtr(uint64(5))}, *finished.Err)
+	})
+}
+
+func TestResponseContentLength(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGuest(t, &Config{})
+	responses := newOutgoingResponseImpl(g.hm)
+	newBody := func() OutgoingBody {
+		resp := responses.Constructor(g.newFields("Content-Length", "4"))
+		body := responses.Body(resp)
+		require.NotNil(t, body.Ok)
+		return *body.Ok
+	}
+
+	// 服务端的响应使用 http-response-body-size 报告长度不符
+	requireBodySize(t, g.write(newBody(), []byte("toolong")), true, 7)
+
+	finished := g.bodies.Finish(ctx, newBody(), witgo.None[Fields]())
+	require.NotNil(t, finished.Err)
+	require.Equal(t, ErrorCode{HTTPResponseBodySize: witgo.SomePtr(uint64(0))}, *finished.Err)
+}
+
+func TestIncomingBodyBytesRead(t *testing.T) {
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		io.WriteString(w, "response body")
+	}))
+	defer srv.Close()
+
+	g := newTestGuest(t, &Config{})
+	result := g.send(g.newRequest("GET", srv.URL), witgo.None[RequestOptions]())
+	require.Nil(t, result.Err)
+	handle := g.consume(*result.Ok)
+	body, ok := g.hm.IncomingBodies.Get(handle)
+	require.True(t, ok)
+
+	// 通过输入流读到的字节数记录在 incoming-body 上
+	data, err := g.read(handle)
+	require.NoError(t, err)
+	require.Equal(t, "response body", string(data))
+	require.Equal(t, uint64(len(data)), body.BytesRead.Load())
+}
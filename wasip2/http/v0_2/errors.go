@@ -0,0 +1,179 @@
+package v0_2
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+// codeError 是 Host 产生的、已经确定了 ErrorCode 的错误。
+type codeError struct {
+	msg  string
+	code ErrorCode
+}
+
+func (e *codeError) Error() string { return e.msg }
+
+// mapGoErrToWasiHttpErr 将 Go 的 net/http 和 net 错误映射到 wasi:http 的 ErrorCode。
+// 无法归类的错误映射为 internal-error，并携带错误信息。
+func mapGoErrToWasiHttpErr(err error) ErrorCode {
+	if code, ok := classifyError(err); ok {
+		return code
+	}
+	if err == nil {
+		return ErrorCode{InternalError: witgo.NonePtr[string]()}
+	}
+	return ErrorCode{InternalError: witgo.SomePtr(err.Error())}
+}
+
+// classifyError 尝试将 err 归类为具体的 ErrorCode，无法归类时返回 false。
+// 检查的顺序很重要：同一个错误往往被多层包装，越具体的类型越先检查。
+func classifyError(err error) (ErrorCode, bool) {
+	if err == nil {
+		return ErrorCode{}, false
+	}
+	unit := &witgo.Unit{}
+
+	// Host 自己产生的错误
+	var codeErr *codeError
+	if errors.As(err, &codeErr) {
+		return codeErr.code, true
+	}
+	switch {
+	case errors.Is(err, errDestinationIPProhibited):
+		return ErrorCode{DestinationIPProhibited: unit}, true
+	case errors.Is(err, errRequestBodyTooLarge):
+		return ErrorCode{HTTPRequestBodySize: witgo.NonePtr[uint64]()}, true
+	}
+
+	// DNS
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrorCode{DNSTimeout: unit}, true
+		}
+		return ErrorCode{DNSError: dnsErrorPayload(dnsErr)}, true
+	}
+
+	// TLS
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &certInvalidErr) || errors.As(err, &hostnameErr) {
+		return ErrorCode{TLSCertificateError: unit}, true
+	}
+	if payload, ok := tlsAlert(err); ok {
+		return ErrorCode{TLSAlertReceived: payload}, true
+	}
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return ErrorCode{TLSProtocolError: unit}, true
+	}
+
+	// 连接
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorCode{ConnectionRefused: unit}, true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return ErrorCode{ConnectionTerminated: unit}, true
+	case errors.Is(err, syscall.ENETUNREACH):
+		return ErrorCode{DestinationIPUnroutable: unit}, true
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.EHOSTDOWN):
+		return ErrorCode{DestinationUnavailable: unit}, true
+	}
+
+	// 超时：建立连接阶段的超时是 connection-timeout，之后等待数据的超时是 connection-read-timeout。
+	if isTimeout(err) {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) {
+			switch opErr.Op {
+			case "dial":
+				return ErrorCode{ConnectionTimeout: unit}, true
+			case "write":
+				return ErrorCode{ConnectionWriteTimeout: unit}, true
+			}
+		}
+		return ErrorCode{ConnectionReadTimeout: unit}, true
+	}
+
+	// 消息体
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return ErrorCode{HTTPRequestBodySize: witgo.NonePtr[uint64]()}, true
+	}
+	if strings.Contains(err.Error(), "http: ContentLength=") {
+		// net/http 在请求体长度与 Content-Length 不符时返回的错误没有导出的类型
+		return ErrorCode{HTTPRequestBodySize: witgo.NonePtr[uint64]()}, true
+	}
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorCode{HTTPResponseIncomplete: unit}, true
+	case errors.Is(err, io.EOF):
+		// 对端在返回响应之前关闭了连接
+		return ErrorCode{ConnectionTerminated: unit}, true
+	}
+
+	return ErrorCode{}, false
+}
+
+// isTimeout 判断 err 是否为超时错误。
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, syscall.ETIMEDOUT) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// dnsErrorPayload 根据 net.DNSError 推断 DNS 的响应码。
+func dnsErrorPayload(err *net.DNSError) *DNSErrorPayload {
+	payload := &DNSErrorPayload{
+		Rcode:    witgo.Some(err.Err),
+		InfoCode: witgo.None[uint16](),
+	}
+	switch {
+	case err.IsNotFound:
+		payload.Rcode = witgo.Some("NXDOMAIN")
+	case err.IsTemporary:
+		payload.Rcode = witgo.Some("SERVFAIL")
+	}
+	return payload
+}
+
+// tlsAlert 识别对端发来的 TLS 警报。
+// crypto/tls 没有导出警报类型，收到的警报会以 Op 为 "remote error" 的 net.OpError 返回，
+// 其中的错误是底层类型为 uint8 的警报编号。
+func tlsAlert(err error) (*TLSAlertReceivedPayload, bool) {
+	var alertErr tls.AlertError
+	if errors.As(err, &alertErr) {
+		return &TLSAlertReceivedPayload{
+			AlertID:      witgo.Some(uint8(alertErr)),
+			AlertMessage: witgo.Some(alertErr.Error()),
+		}, true
+	}
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "remote error" || opErr.Err == nil {
+		return nil, false
+	}
+	payload := &TLSAlertReceivedPayload{
+		AlertID:      witgo.None[uint8](),
+		AlertMessage: witgo.Some(opErr.Err.Error()),
+	}
+	if v := reflect.ValueOf(opErr.Err); v.Kind() == reflect.Uint8 {
+		payload.AlertID = witgo.Some(uint8(v.Uint()))
+	}
+	return payload, true
+}
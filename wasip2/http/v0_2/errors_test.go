@@ -0,0 +1,91 @@
+package v0_2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	gohttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	unit := &witgo.Unit{}
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: err}
+	}
+	for _, tc := range []struct {
+		name string
+		err  error
+		code ErrorCode
+	}{
+		{"host code", fmt.Errorf("wrapped: %w", &codeError{msg: "denied", code: ErrorCode{HTTPRequestDenied: unit}}), ErrorCode{HTTPRequestDenied: unit}},
+		{"dns not found", wrap(&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}),
+			ErrorCode{DNSError: &DNSErrorPayload{Rcode: witgo.Some("NXDOMAIN"), InfoCode: witgo.None[uint16]()}}},
+		{"dns timeout", wrap(&net.DNSError{Err: "i/o timeout", IsTimeout: true}), ErrorCode{DNSTimeout: unit}},
+		{"certificate", wrap(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), ErrorCode{TLSCertificateError: unit}},
+		{"hostname", wrap(x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}), ErrorCode{TLSCertificateError: unit}},
+		{"alert", wrap(tls.AlertError(40)), ErrorCode{TLSAlertReceived: &TLSAlertReceivedPayload{
+			AlertID: witgo.Some(uint8(40)), AlertMessage: witgo.Some(tls.AlertError(40).Error()),
+		}}},
+		{"refused", wrap(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), ErrorCode{ConnectionRefused: unit}},
+		{"reset", wrap(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), ErrorCode{ConnectionTerminated: unit}},
+		{"connect timeout", wrap(&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}), ErrorCode{ConnectionTimeout: unit}},
+		{"read timeout", wrap(&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}), ErrorCode{ConnectionReadTimeout: unit}},
+		{"write timeout", wrap(&net.OpError{Op: "write", Err: os.ErrDeadlineExceeded}), ErrorCode{ConnectionWriteTimeout: unit}},
+		{"body too large", wrap(&gohttp.MaxBytesError{Limit: 10}), ErrorCode{HTTPRequestBodySize: witgo.NonePtr[uint64]()}},
+		{"incomplete", wrap(io.ErrUnexpectedEOF), ErrorCode{HTTPResponseIncomplete: unit}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, ok := classifyError(tc.err)
+			require.True(t, ok)
+			require.Equal(t, tc.code, code)
+			require.Equal(t, tc.code, mapGoErrToWasiHttpErr(tc.err))
+		})
+	}
+
+	// 无法归类的错误不是 HTTP 错误，http-error-code 返回 none，请求失败时映射为 internal-error
+	_, ok := classifyError(errors.New("boom"))
+	require.False(t, ok)
+	require.Equal(t, ErrorCode{InternalError: witgo.SomePtr("boom")}, mapGoErrToWasiHttpErr(errors.New("boom")))
+}
+
+func TestOutgoingErrors(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := l.Addr().String()
+		l.Close()
+
+		g := newTestGuest(t, &Config{})
+		result := g.send(g.newRequest("GET", "http://"+addr), witgo.None[RequestOptions]())
+		require.NotNil(t, result.Err)
+		require.NotNil(t, result.Err.ConnectionRefused)
+	})
+
+	t.Run("tls alert", func(t *testing.T) {
+		// 服务端要求客户端证书，没有证书的客户端收到 TLS 警报
+		srv := httptest.NewUnstartedServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {}))
+		srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+		defer srv.Close()
+
+		g := newTestGuest(t, &Config{Client: ClientConfig{InsecureSkipVerify: true}})
+		result := g.send(g.newRequest("GET", srv.URL), witgo.None[RequestOptions]())
+		require.NotNil(t, result.Err)
+		require.NotNil(t, result.Err.TLSAlertReceived)
+		require.True(t, result.Err.TLSAlertReceived.AlertID.IsSome())
+	})
+}
@@ -2,7 +2,6 @@ package v0_2
 
 import (
 	"context"
-	"sort"
 	"strings"
 
 	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
@@ -11,25 +10,28 @@ import (
 
 // fieldsImpl 封装了 fields 资源的所有操作。
 type fieldsImpl struct {
-	fm *manager_http.FieldsManager
+	fm  *manager_http.FieldsManager
+	cfg *Config
 }
 
-func newFieldsImpl(fm *manager_http.FieldsManager) *fieldsImpl {
-	return &fieldsImpl{fm: fm}
+func newFieldsImpl(fm *manager_http.FieldsManager, cfg *Config) *fieldsImpl {
+	return &fieldsImpl{fm: fm, cfg: cfg}
 }
 
 // Constructor 实现了 [constructor]fields。
 func (i *fieldsImpl) Constructor() Fields {
-	return i.fm.Add(make(manager_http.Fields))
+	return i.fm.Add(manager_http.NewFields())
 }
 
 // FromListConstructor 实现了 [constructor]fields.from-list。
 func (i *fieldsImpl) FromList(_ context.Context, entries []witgo.Tuple[FieldKey, FieldValue]) witgo.Result[Fields, HeaderError] {
-	fields := make(manager_http.Fields)
+	fields := manager_http.NewFields()
 	for _, entry := range entries {
-		// HTTP 头部名称是不区分大小写的，我们统一转为小写。
-		key := strings.ToLower(entry.F0)
-		fields[key] = append(fields[key], string(entry.F1))
+		if herr := i.check(entry.F0, entry.F1); herr != nil {
+			return witgo.Err[Fields, HeaderError](*herr)
+		}
+		// 保留名称的大小写和顺序，查找时不区分大小写。
+		fields.Append(entry.F0, string(entry.F1))
 	}
 	handle := i.fm.Add(fields)
 	return witgo.Ok[Fields, HeaderError](handle)
@@ -46,7 +48,7 @@ func (i *fieldsImpl) Get(_ context.Context, this Fields, name FieldKey) []FieldV
 	if !ok {
 		return nil
 	}
-	values := f[strings.ToLower(name)]
+	values := f.Get(name)
 	ret := make([]FieldValue, len(values))
 	for j, v := range values {
 		ret[j] = FieldValue(v)
@@ -56,66 +58,127 @@ func (i *fieldsImpl) Get(_ context.Context, this Fields, name FieldKey) []FieldV
 
 // Get 实现了 [method]fields.has。
 func (i *fieldsImpl) Has(_ context.Context, this Fields, name FieldKey) bool {
-	_, ok := i.fm.Get(this)
+	f, ok := i.fm.Get(this)
 	if !ok {
 		return false
 	}
-	return true
+	return f.Has(name)
 }
 
 // Set 实现了 [method]fields.set。
 func (i *fieldsImpl) Set(_ context.Context, this Fields, name FieldKey, value []FieldValue) witgo.Result[witgo.Unit, HeaderError] {
-	f, ok := i.fm.Get(this)
-	if !ok {
-		return witgo.Err[witgo.Unit, HeaderError](HeaderError{Immutable: &witgo.Unit{}})
+	f, herr := i.mutable(this)
+	if herr != nil {
+		return witgo.Err[witgo.Unit, HeaderError](*herr)
+	}
+	if herr := i.check(name, nil); herr != nil {
+		return witgo.Err[witgo.Unit, HeaderError](*herr)
 	}
 	values := make([]string, len(value))
 	for j, v := range value {
+		if !validFieldValue(v) {
+			return witgo.Err[witgo.Unit, HeaderError](HeaderError{InvalidSyntax: &witgo.Unit{}})
+		}
 		values[j] = string(v)
 	}
-	f[strings.ToLower(name)] = values
+	f.Set(name, values)
 	return witgo.Ok[witgo.Unit, HeaderError](witgo.Unit{})
 }
 
 // Delete 实现了 [method]fields.delete。
 func (i *fieldsImpl) Delete(_ context.Context, this Fields, name FieldKey) witgo.Result[witgo.Unit, HeaderError] {
-	f, ok := i.fm.Get(this)
-	if !ok {
-		return witgo.Err[witgo.Unit, HeaderError](HeaderError{Immutable: &witgo.Unit{}})
+	f, herr := i.mutable(this)
+	if herr != nil {
+		return witgo.Err[witgo.Unit, HeaderError](*herr)
 	}
-	delete(f, strings.ToLower(name))
+	if herr := i.check(name, nil); herr != nil {
+		return witgo.Err[witgo.Unit, HeaderError](*herr)
+	}
+	f.Delete(name)
 	return witgo.Ok[witgo.Unit, HeaderError](witgo.Unit{})
 }
 
 // Append 实现了 [method]fields.append。
 func (i *fieldsImpl) Append(_ context.Context, this Fields, name FieldKey, value FieldValue) witgo.Result[witgo.Unit, HeaderError] {
-	f, ok := i.fm.Get(this)
-	if !ok {
-		return witgo.Err[witgo.Unit, HeaderError](HeaderError{Immutable: &witgo.Unit{}})
+	f, herr := i.mutable(this)
+	if herr != nil {
+		return witgo.Err[witgo.Unit, HeaderError](*herr)
+	}
+	if herr := i.check(name, value); herr != nil {
+		return witgo.Err[witgo.Unit, HeaderError](*herr)
 	}
-	key := strings.ToLower(name)
-	f[key] = append(f[key], string(value))
+	f.Append(name, string(value))
 	return witgo.Ok[witgo.Unit, HeaderError](witgo.Unit{})
 }
 
+// mutable 返回可以修改的 fields，无效或不可变的句柄返回 immutable。
+func (i *fieldsImpl) mutable(this Fields) (*manager_http.Fields, *HeaderError) {
+	f, ok := i.fm.Get(this)
+	if !ok || i.fm.IsImmutable(this) {
+		return nil, &HeaderError{Immutable: &witgo.Unit{}}
+	}
+	return f, nil
+}
+
+// check 校验头部名称和值的语法，并检查名称是否被 Host 禁止。
+func (i *fieldsImpl) check(name string, value FieldValue) *HeaderError {
+	if !validFieldName(name) || !validFieldValue(value) {
+		return &HeaderError{InvalidSyntax: &witgo.Unit{}}
+	}
+	if containsFold(i.cfg.ForbiddenHeaders, name) {
+		return &HeaderError{Forbidden: &witgo.Unit{}}
+	}
+	return nil
+}
+
+// validFieldName 判断 name 是否符合 RFC 9110 中的 token 语法。
+func validFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for j := 0; j < len(name); j++ {
+		if !isTokenChar(name[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// validFieldValue 判断 value 是否符合 RFC 9110 中的 field-value 语法：
+// 由可见字符、obs-text、空格和水平制表符组成，且首尾不能是空白。
+func validFieldValue(value FieldValue) bool {
+	for _, c := range value {
+		if c != ' ' && c != '\t' && (c < 0x21 || c == 0x7f) {
+			return false
+		}
+	}
+	if n := len(value); n > 0 {
+		isSpace := func(c byte) bool { return c == ' ' || c == '\t' }
+		if isSpace(value[0]) || isSpace(value[n-1]) {
+			return false
+		}
+	}
+	return true
+}
+
 // Entries 实现了 [method]fields.entries。
 func (i *fieldsImpl) Entries(_ context.Context, this Fields) []witgo.Tuple[FieldKey, FieldValue] {
 	f, ok := i.fm.Get(this)
 	if !ok {
 		return nil
 	}
-	// 为了确保稳定的返回顺序，我们对 key 进行排序。
-	keys := make([]FieldKey, 0, len(f))
-	for k := range f {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
+	// 按添加的顺序返回，名称保留原来的大小写。
 	var entries []witgo.Tuple[FieldKey, FieldValue]
-	for _, k := range keys {
-		for _, v := range f[k] {
-			entries = append(entries, witgo.Tuple[FieldKey, FieldValue]{F0: k, F1: FieldValue(v)})
-		}
+	for _, e := range f.Entries() {
+		entries = append(entries, witgo.Tuple[FieldKey, FieldValue]{F0: e.Name, F1: FieldValue(e.Value)})
 	}
 	return entries
 }
@@ -128,12 +191,6 @@ func (i *fieldsImpl) Clone(_ context.Context, this Fields) Fields {
 		return i.Constructor()
 	}
 
-	// 创建一个新的 map 并深拷贝所有键值对。
-	newFields := make(manager_http.Fields, len(f))
-	for k, v := range f {
-		newValues := make([]string, len(v))
-		copy(newValues, v)
-		newFields[k] = newValues
-	}
-	return i.fm.Add(newFields)
+	// 克隆出的 fields 总是可变的。
+	return i.fm.Add(f.Clone())
 }
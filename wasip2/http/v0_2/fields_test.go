@@ -0,0 +1,108 @@
+package v0_2
+
+import (
+	"context"
+	"testing"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireHeaderError 检查每个操作都返回了 want 指定的 header-error。
+func requireHeaderError(t *testing.T, want func(HeaderError) *witgo.Unit, results ...witgo.Result[witgo.Unit, HeaderError]) {
+	t.Helper()
+	for _, result := range results {
+		require.NotNil(t, result.Err)
+		require.NotNil(t, want(*result.Err), "%+v", *result.Err)
+	}
+}
+
+func TestFieldsValidation(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGuest(t, &Config{})
+	fields := g.fields.Constructor()
+	fromList := func(name string, value FieldValue) witgo.Result[witgo.Unit, HeaderError] {
+		result := g.fields.FromList(ctx, []witgo.Tuple[FieldKey, FieldValue]{{F0: name, F1: value}})
+		if result.Err != nil {
+			return witgo.Err[witgo.Unit, HeaderError](*result.Err)
+		}
+		return witgo.Ok[witgo.Unit, HeaderError](witgo.Unit{})
+	}
+	invalidSyntax := func(e HeaderError) *witgo.Unit { return e.InvalidSyntax }
+
+	// 名称必须是 RFC 9110 的 token
+	for _, name := range []string{"", "x name", "x:name", "x\r\nname", "名称"} {
+		requireHeaderError(t, invalidSyntax,
+			fromList(name, FieldValue("v")),
+			g.fields.Set(ctx, fields, name, []FieldValue{FieldValue("v")}),
+			g.fields.Append(ctx, fields, name, FieldValue("v")),
+			g.fields.Delete(ctx, fields, name),
+		)
+	}
+
+	// 值不能包含控制字符，首尾不能是空白
+	for _, value := range []string{"a\r\nb", "a\x00b", "\x7f", " leading", "trailing\t"} {
+		requireHeaderError(t, invalidSyntax,
+			fromList("x-name", FieldValue(value)),
+			g.fields.Set(ctx, fields, "x-name", []FieldValue{FieldValue(value)}),
+			g.fields.Append(ctx, fields, "x-name", FieldValue(value)),
+		)
+	}
+	require.Empty(t, g.fields.Entries(ctx, fields))
+
+	// 中间的空白、obs-text 和空值都是合法的
+	for _, value := range []string{"a b\tc", "\x80\xff", ""} {
+		require.Nil(t, fromList("x-name", FieldValue(value)).Err)
+		require.Nil(t, g.fields.Append(ctx, fields, "x-name", FieldValue(value)).Err)
+	}
+	require.Nil(t, g.fields.Set(ctx, fields, "!#$%&'*+-.^_`|~", []FieldValue{FieldValue("v")}).Err)
+}
+
+func TestFieldsForbidden(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGuest(t, &Config{ForbiddenHeaders: []string{"Host", "x-secret"}})
+	fields := g.fields.Constructor()
+	forbidden := func(e HeaderError) *witgo.Unit { return e.Forbidden }
+
+	// 名称不区分大小写
+	for _, name := range []string{"host", "HOST", "X-Secret"} {
+		list := g.fields.FromList(ctx, []witgo.Tuple[FieldKey, FieldValue]{{F0: name, F1: FieldValue("v")}})
+		require.NotNil(t, list.Err)
+		require.NotNil(t, list.Err.Forbidden)
+		requireHeaderError(t, forbidden,
+			g.fields.Set(ctx, fields, name, []FieldValue{FieldValue("v")}),
+			g.fields.Append(ctx, fields, name, FieldValue("v")),
+			g.fields.Delete(ctx, fields, name),
+		)
+	}
+	require.Nil(t, g.fields.Append(ctx, fields, "x-public", FieldValue("v")).Err)
+}
+
+func TestFieldsImmutable(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGuest(t, &Config{})
+	req := g.newRequest("GET", "http://example.com", "x-name", "v")
+	immutable := func(e HeaderError) *witgo.Unit { return e.Immutable }
+
+	// 从请求中取得的 headers 只能读取
+	headers := g.requests.Headers(ctx, req)
+	require.Equal(t, []FieldValue{FieldValue("v")}, g.fields.Get(ctx, headers, "x-name"))
+	requireHeaderError(t, immutable,
+		g.fields.Set(ctx, headers, "x-name", []FieldValue{FieldValue("changed")}),
+		g.fields.Append(ctx, headers, "x-other", FieldValue("v")),
+		g.fields.Delete(ctx, headers, "x-name"),
+	)
+	require.Equal(t, []FieldValue{FieldValue("v")}, g.fields.Get(ctx, headers, "x-name"))
+
+	// 克隆出的 fields 可以修改，且不影响原来的 headers
+	clone := g.fields.Clone(ctx, headers)
+	require.Nil(t, g.fields.Set(ctx, clone, "x-name", []FieldValue{FieldValue("changed")}).Err)
+	require.Equal(t, []FieldValue{FieldValue("v")}, g.fields.Get(ctx, headers, "x-name"))
+
+	// 丢弃不可变的句柄后，新创建的 fields 不会继承不可变标记
+	g.fields.Drop(ctx, headers)
+	requireHeaderError(t, immutable, g.fields.Set(ctx, headers, "x-name", nil))
+	fields := g.fields.Constructor()
+	require.Nil(t, g.fields.Append(ctx, fields, "x-name", FieldValue("v")).Err)
+}
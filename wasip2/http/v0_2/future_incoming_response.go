@@ -64,7 +64,7 @@ func (i *futureIncomingResponseImpl) Get(
 			Response: future.Result.Response,
 
 			StatusCode: future.Result.Response.StatusCode,
-			Headers:    future.Result.Response.Header,
+			Headers:    manager_http.FieldsFromHeader(future.Result.Response.Header),
 		})
 		innerResult = witgo.Ok[IncomingResponse, ErrorCode](responseHandle)
 	}
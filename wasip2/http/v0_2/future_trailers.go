@@ -49,7 +49,7 @@ func (i *futureTrailersImpl) Get(ctx context.Context, this FutureTrailers) witgo
 
 	if future.Result.Err != nil {
 		// 读取 body 过程中发生错误
-		errorCode := ErrorCode{InternalError: witgo.SomePtr(future.Result.Err.Error())}
+		errorCode := mapGoErrToWasiHttpErr(future.Result.Err)
 		return witgo.Some(witgo.Ok[witgo.Result[witgo.Option[Trailers], ErrorCode], witgo.Unit](
 			witgo.Err[witgo.Option[Trailers], ErrorCode](errorCode),
 		))
@@ -57,7 +57,7 @@ func (i *futureTrailersImpl) Get(ctx context.Context, this FutureTrailers) witgo
 
 	var trailers witgo.Option[Trailers]
 	if future.Result.Trailers != nil {
-		trailers = witgo.Some(i.hm.Fields.Add(future.Result.Trailers))
+		trailers = witgo.Some(i.hm.Fields.AddImmutable(future.Result.Trailers))
 	} else {
 		trailers = witgo.None[Trailers]()
 	}
@@ -10,10 +10,12 @@ import (
 
 type incomingBodyImpl struct {
 	hm *manager_http.HTTPManager
+	// maxReadBufferSize 是 body 输入流的读取高水位
+	maxReadBufferSize int
 }
 
-func newIncomingBodyImpl(hm *manager_http.HTTPManager) *incomingBodyImpl {
-	return &incomingBodyImpl{hm: hm}
+func newIncomingBodyImpl(hm *manager_http.HTTPManager, maxReadBufferSize int) *incomingBodyImpl {
+	return &incomingBodyImpl{hm: hm, maxReadBufferSize: maxReadBufferSize}
 }
 
 // Drop 是析构函数
@@ -34,7 +36,7 @@ func (i *incomingBodyImpl) Stream(_ context.Context, this IncomingBody) witgo.Re
 	}
 
 	// Stream 和 IncomingBody 生命周期绑定，这里不Close
-	stream := manager_io.NewAsyncStreamForReader(body.Stream, manager_io.DontCloseReader())
+	stream := manager_io.NewAsyncStreamForReader(body.Stream, manager_io.DontCloseReader(), manager_io.ReaderRead(&body.BytesRead), manager_io.WithMaxReadBufferSize(i.maxReadBufferSize))
 	body.StreamHandle = i.hm.Streams.Add(stream)
 	return witgo.Ok[InputStream, witgo.Unit](body.StreamHandle)
 }
@@ -48,7 +50,6 @@ func (i *incomingBodyImpl) Finish(this IncomingBody) FutureTrailers {
 		// 在 wazero 的 Go host function 中，panic 会被转换为 trap。
 		panic("invalid incoming-body handle")
 	}
-	defer body.Close()
 
 	// 为了兼容，这里直接清理资源不报错
 	if body.Consumed.CompareAndSwap(false, true) {
@@ -56,11 +57,16 @@ func (i *incomingBodyImpl) Finish(this IncomingBody) FutureTrailers {
 		// panic("trap: finishing incoming-body while its stream is still alive")
 	}
 
+	// trailers 只有在 body 读取完毕后才会到达，剩余的内容在后台读完。
+	// future 被丢弃时关闭 body，中止后台的读取。
 	future := &manager_http.FutureTrailers{
-		Pollable: manager_io.ReadyPollable,
-	}
-	if body.GetTrailers != nil {
-		future.Result.Trailers = body.GetTrailers()
+		Pollable: manager_io.NewPollable(nil),
+		Cancel:   func() { body.Close() },
 	}
+	go func() {
+		defer future.Pollable.SetReady()
+		defer body.Close()
+		future.Result.Trailers, future.Result.Err = body.Trailers()
+	}()
 	return i.hm.FutureTrailers.Add(future)
 }
@@ -73,6 +73,17 @@ func (i *incomingRequestImpl) Consume(this IncomingRequest) witgo.Result[Incomin
 		return witgo.Err[IncomingBody, witgo.Unit](witgo.Unit{})
 	}
 
-	// TODO
+	if !req.Consumed.CompareAndSwap(false, true) {
+		return witgo.Err[IncomingBody, witgo.Unit](witgo.Unit{})
+	}
+
+	// 请求的 trailers 在 body 读取完毕后由 net/http 填充到 Request.Trailer 中。
+	body := manager_http.NewIncomingBody(req.Body, func() *manager_http.Fields {
+		if req.Request == nil {
+			return nil
+		}
+		return manager_http.FieldsFromHeader(req.Request.Trailer)
+	})
+	req.BodyHandle = i.hm.IncomingBodies.Add(body)
 	return witgo.Ok[IncomingBody, witgo.Unit](req.BodyHandle)
 }
@@ -35,7 +35,7 @@ func (i *incomingResponseImpl) Headers(_ context.Context, this IncomingResponse)
 	if !ok {
 		panic("invalid incoming-respone handle")
 	}
-	return i.hm.Fields.Add(manager_http.Fields(resp.Response.Header))
+	return i.hm.Fields.AddImmutable(resp.Headers)
 }
 
 // Consume 实现了 [method]incoming-response.consume。
@@ -49,12 +49,9 @@ func (i *incomingResponseImpl) Consume(_ context.Context, this IncomingResponse)
 		return witgo.Err[OutgoingBody, witgo.Unit](witgo.Unit{})
 	}
 
-	body := &manager_http.IncomingBody{
-		Stream: resp.Response.Body,
-		GetTrailers: func() (trailers manager_http.Fields) {
-			return resp.Response.Trailer
-		},
-	}
+	body := manager_http.NewIncomingBody(resp.Response.Body, func() *manager_http.Fields {
+		return manager_http.FieldsFromHeader(resp.Response.Trailer)
+	})
 	resp.BodyHandle = i.hm.IncomingBodies.Add(body)
 	return witgo.Ok[IncomingBody, witgo.Unit](resp.BodyHandle)
 }
@@ -3,6 +3,7 @@ package v0_2
 import (
 	"context"
 	"fmt"
+	"io"
 
 	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
 	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
@@ -38,6 +39,10 @@ func (i *outgoingBodyImpl) Write(_ context.Context, this OutgoingBody) witgo.Res
 
 	// Stream 由 outgoingBody 管理,所以去除Close
 	stream := manager_io.NewAsyncStreamForWriter(body.BodyWriter, manager_io.WriterWritten(&body.BytesWritten), manager_io.DontCloseWriter())
+	if body.ContentLength != nil {
+		// 超过声明长度的写入直接失败，而不是等到 finish 时才发现
+		stream.Writer = &contentLengthWriter{Writer: stream.Writer, body: body, remaining: *body.ContentLength}
+	}
 	body.OutputStreamHandle = i.hm.Streams.Add(stream)
 	return witgo.Ok[OutputStream, witgo.Unit](body.OutputStreamHandle)
 }
@@ -46,7 +51,7 @@ func (i *outgoingBodyImpl) Write(_ context.Context, this OutgoingBody) witgo.Res
 // 如果对应的 HTTP 请求 / 响应包含Content-Length头，finish会校验实际写入的内容长度是否与该头指定的值一致；不一致则返回失败（确保协议合规）
 // 若未调用finish就直接丢弃outgoing-body资源，系统会将消息体视为 "不完整 / 损坏"，并通过各种方式（如破坏传输内容、中止请求、发送错误状态码）将错误反馈到 HTTP 协议层面
 func (i *outgoingBodyImpl) Finish(_ context.Context, this OutgoingBody, trailers witgo.Option[Fields]) witgo.Result[witgo.Unit, ErrorCode] {
-	var trailer manager_http.Fields
+	var trailer *manager_http.Fields
 	if trailers.IsSome() {
 		trailer, _ = i.hm.Fields.Pop(*trailers.Some)
 	}
@@ -63,14 +68,39 @@ func (i *outgoingBodyImpl) Finish(_ context.Context, this OutgoingBody, trailers
 		}
 	}
 
-	// 执行 Content-Length 校验
+	// 执行 Content-Length 校验，长度不符时以错误关闭管道，避免对端把它当作完整的 Body
 	if body.ContentLength != nil {
-		bytesWritten := body.BytesWritten.Load()
-		if bytesWritten != *body.ContentLength {
-			errMsg := fmt.Sprintf("content-length mismatch: header specified %d, but %d bytes were written", *body.ContentLength, bytesWritten)
-			return witgo.Err[witgo.Unit, ErrorCode](ErrorCode{InternalError: witgo.SomePtr(errMsg)})
+		if bytesWritten := body.BytesWritten.Load(); bytesWritten != *body.ContentLength {
+			err := bodySizeError(body, bytesWritten)
+			body.BodyWriter.CloseWithError(err)
+			return witgo.Err[witgo.Unit, ErrorCode](err.code)
 		}
 	}
 
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
 }
+
+// bodySizeError 返回 Body 长度与 Content-Length 不符时的错误，size 是实际或试图写入的长度。
+func bodySizeError(body *manager_http.OutgoingBody, size uint64) *codeError {
+	msg := fmt.Sprintf("content-length mismatch: header specified %d, but body length is %d", *body.ContentLength, size)
+	if body.Response {
+		return &codeError{msg: msg, code: ErrorCode{HTTPResponseBodySize: witgo.SomePtr(size)}}
+	}
+	return &codeError{msg: msg, code: ErrorCode{HTTPRequestBodySize: witgo.SomePtr(size)}}
+}
+
+// contentLengthWriter 拒绝超过 Content-Length 的写入。
+type contentLengthWriter struct {
+	io.Writer
+	body      *manager_http.OutgoingBody
+	remaining uint64
+}
+
+func (w *contentLengthWriter) Write(p []byte) (int, error) {
+	if uint64(len(p)) > w.remaining {
+		return 0, bodySizeError(w.body, *w.body.ContentLength-w.remaining+uint64(len(p)))
+	}
+	n, err := w.Writer.Write(p)
+	w.remaining -= uint64(n)
+	return n, err
+}
@@ -2,85 +2,21 @@ package v0_2
 
 import (
 	"fmt"
-	"net"
 	gohttp "net/http"
-	"time"
 
 	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
 	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
-
-	lru "github.com/hashicorp/golang-lru/v2"
 )
 
-type timeoutConfig struct {
-	connect      time.Duration
-	firstByte    time.Duration
-	betweenBytes time.Duration
-}
-
 // outgoingHandlerImpl 封装了 wasi:http/outgoing-handler 的所有操作。
 type outgoingHandlerImpl struct {
-	hm          *manager_http.HTTPManager
-	clientCache *lru.Cache[timeoutConfig, *gohttp.Client]
+	hm  *manager_http.HTTPManager
+	cfg *Config
 }
 
-func newOutgoingHandlerImpl(hm *manager_http.HTTPManager) *outgoingHandlerImpl {
-	clientCache, _ := lru.New[timeoutConfig, *gohttp.Client](32)
-	return &outgoingHandlerImpl{hm, clientCache}
-}
-
-func (i *outgoingHandlerImpl) getClient(opts *manager_http.RequestOptions) *gohttp.Client {
-	// 1. 确定超时配置。如果无特定选项，则使用零值配置。
-	var cfg timeoutConfig
-	if opts != nil {
-		if opts.ConnectTimeout != nil {
-			cfg.connect = time.Duration(*opts.ConnectTimeout)
-		}
-		if opts.FirstByteTimeout != nil {
-			cfg.firstByte = time.Duration(*opts.FirstByteTimeout)
-		}
-		if opts.BetweenBytesTimeout != nil {
-			cfg.betweenBytes = time.Duration(*opts.BetweenBytesTimeout)
-		}
-	}
-
-	// 2. 使用配置作为键，检查缓存。
-	//    零值的 cfg 将作为我们自定义的 "默认客户端" 的键。
-	if client, ok := i.clientCache.Get(cfg); ok {
-		return client
-	}
-
-	// 3. 如果缓存未命中，则创建一个新的客户端。
-	//    这块逻辑现在同时服务于自定义客户端和默认客户端。
-	transport := gohttp.DefaultTransport.(*gohttp.Transport).Clone()
-
-	// 保留原始代码中的代理和 TLS 设置
-	// p, _ := url.Parse("http://192.168.3.121:8888")
-	// transport.Proxy = gohttp.ProxyURL(p)
-	transport.Proxy = gohttp.ProxyFromEnvironment
-	transport.TLSClientConfig.InsecureSkipVerify = true
-
-	// 根据配置设置超时（如果cfg为零值，则超时为0，表示不设限制）
-	transport.DialContext = (&net.Dialer{
-		Timeout:   cfg.connect,
-		KeepAlive: 30 * time.Second,
-	}).DialContext
-	transport.ResponseHeaderTimeout = cfg.firstByte
-
-	// 重定向和CookieJar由guest接管
-	client := &gohttp.Client{
-		Transport: transport,
-		Timeout:   cfg.betweenBytes, // 整个请求的超时，包括读取响应体
-		Jar:       nil,
-		CheckRedirect: func(req *gohttp.Request, via []*gohttp.Request) error {
-			return gohttp.ErrUseLastResponse
-		},
-	}
-
-	// 4. 将新创建的客户端存入缓存并返回
-	i.clientCache.Add(cfg, client)
-	return client
+func newOutgoingHandlerImpl(hm *manager_http.HTTPManager, cfg *Config) *outgoingHandlerImpl {
+	return &outgoingHandlerImpl{hm, cfg}
 }
 
 // Handle 实现了 outgoing-handler.handle 接口。
@@ -110,16 +46,29 @@ func (i *outgoingHandlerImpl) Handle(
 		return witgo.Err[FutureIncomingResponse, ErrorCode](ErrorCode{InternalError: witgo.SomePtr(err.Error())})
 	}
 
-	client := i.getClient(opts)
+	// 在发送之前检查出站策略，被拒绝的请求不会建立任何连接。
+	if code := i.cfg.Policy.check(goReq); code != nil {
+		req.Close()
+		return witgo.Err[FutureIncomingResponse, ErrorCode](*code)
+	}
+
+	// 超时按请求单独处理，所有请求共用同一个客户端。
+	timeouts := newRequestTimeouts(newTimeoutConfig(opts))
+	goReq = timeouts.attach(goReq)
+	// 按原始顺序发送请求头时需要 Guest 设置的 fields
+	goReq = goReq.WithContext(withRequestFields(goReq.Context(), req.Headers))
+	req.Request = goReq
 
 	// 3. 创建一个 FutureIncomingResponse 资源。这是异步的关键。
 	//    它包含一个 channel，后台的 goroutine 将通过它发送最终结果。
+	// future 被丢弃时取消请求，请求体的管道也会随之关闭。
 	future := &manager_http.FutureIncomingResponse{
 		Pollable: manager_io.NewPollable(nil),
+		Cancel:   func() { timeouts.cancel(nil) },
 	}
 
 	// 4. 启动一个新的 goroutine 来异步执行 HTTP 请求。
-	go i.executeRequest(client, goReq, future)
+	go i.executeRequest(goReq, timeouts, future)
 
 	// 5. 立即返回 future 句柄，不阻塞。
 	futureHandle := i.hm.Futures.Add(future)
@@ -127,22 +76,12 @@ func (i *outgoingHandlerImpl) Handle(
 }
 
 // executeRequest 在一个单独的 goroutine 中运行。
-func (i *outgoingHandlerImpl) executeRequest(client *gohttp.Client, goReq *gohttp.Request, future *manager_http.FutureIncomingResponse) {
-	defer func() {
-		future.Pollable.SetReady()
-	}()
-
-	resp, err := client.Do(goReq)
-
-	if err != nil {
-		future.Result = manager_http.Result{
-			Err: err,
-		}
-		return
-	}
-	future.Result = manager_http.Result{
+func (i *outgoingHandlerImpl) executeRequest(goReq *gohttp.Request, timeouts *requestTimeouts, future *manager_http.FutureIncomingResponse) {
+	resp, err := timeouts.response(i.cfg.httpClient().Do(goReq))
+	future.SetResult(manager_http.Result{
 		Response: resp,
-	}
+		Err:      err,
+	})
 }
 
 // buildGoRequest 是一个辅助函数，用于将 wasi-http 请求转换为 Go 的 http.Request。
@@ -165,12 +104,23 @@ func (i *outgoingHandlerImpl) buildGoRequest(req *manager_http.OutgoingRequest)
 	}
 
 	// 这里如果直接赋值会导致小写User-Agent和大小共存
-	for k, vv := range req.Headers {
-		for _, v := range vv {
-			goReq.Header.Add(k, v)
+	for _, e := range req.Headers.Entries() {
+		goReq.Header.Add(e.Name, e.Value)
+	}
+	// 声明了长度的 Body 以固定的 Content-Length 发送，否则 net/http 会使用 chunked 编码。
+	// 长度为 0 时必须使用 NoBody，net/http 才不会把它当作长度未知。
+	if req.Body != nil {
+		if cl := manager_http.ContentLength(req.Headers); cl != nil {
+			goReq.ContentLength = int64(*cl)
+			if *cl == 0 {
+				goReq.Body = gohttp.NoBody
+			}
 		}
 	}
-	goReq.Trailer = make(gohttp.Header)
+	goReq.Trailer = req.Trailers
+	if goReq.Trailer == nil {
+		goReq.Trailer = make(gohttp.Header)
+	}
 
 	req.Request = goReq
 	return goReq, nil
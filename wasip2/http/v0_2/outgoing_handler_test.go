@@ -0,0 +1,380 @@
+package v0_2
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net"
+	gohttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
+	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testGuest 像 Guest 一样直接调用 wasi:http 的 Host 实现。
+type testGuest struct {
+	t  *testing.T
+	hm *manager_http.HTTPManager
+
+	fields    *fieldsImpl
+	requests  *outgoingRequestImpl
+	options   *requestOptionsImpl
+	bodies    *outgoingBodyImpl
+	handler   *outgoingHandlerImpl
+	futures   *futureIncomingResponseImpl
+	responses *incomingResponseImpl
+	incoming  *incomingBodyImpl
+	trailers  *futureTrailersImpl
+}
+
+func newTestGuest(t *testing.T, cfg *Config) *testGuest {
+	sm, pm, _ := manager_io.NewManager()
+	hm := manager_http.NewHTTPManager(sm, pm)
+	t.Cleanup(hm.Clear)
+	return &testGuest{
+		t:         t,
+		hm:        hm,
+		fields:    newFieldsImpl(hm.Fields, cfg),
+		requests:  newOutgoingRequestImpl(hm),
+		options:   newRequestOptionsImpl(hm),
+		bodies:    newOutgoingBodyImpl(hm),
+		handler:   newOutgoingHandlerImpl(hm, cfg),
+		futures:   newFutureIncomingResponseImpl(hm),
+		responses: newIncomingResponseImpl(hm),
+		incoming:  newIncomingBodyImpl(hm, 0),
+		trailers:  newFutureTrailersImpl(hm),
+	}
+}
+
+// newRequest 创建一个出站请求，headers 依次为头部的名称和值。
+func (g *testGuest) newRequest(method, rawURL string, headers ...string) OutgoingRequest {
+	ctx := context.Background()
+	u, err := url.Parse(rawURL)
+	require.NoError(g.t, err)
+
+	fields := g.fields.Constructor()
+	for i := 0; i+1 < len(headers); i += 2 {
+		require.Nil(g.t, g.fields.Append(ctx, fields, headers[i], FieldValue(headers[i+1])).Err)
+	}
+	req := g.requests.Constructor(fields)
+	require.Equal(g.t, witgo.UintOk(), g.requests.SetMethod(ctx, req, toWasiMethod(method)))
+	require.Equal(g.t, witgo.UintOk(), g.requests.SetScheme(ctx, req, witgo.Some(toWasiScheme(u.Scheme))))
+	require.Equal(g.t, witgo.UintOk(), g.requests.SetAuthority(ctx, req, witgo.Some(u.Host)))
+	require.Equal(g.t, witgo.UintOk(), g.requests.SetPathWithQuery(ctx, req, witgo.Some(u.RequestURI())))
+	return req
+}
+
+// send 发送请求并等待 future 就绪，返回 future-incoming-response.get 的结果。
+func (g *testGuest) send(req OutgoingRequest, options witgo.Option[RequestOptions]) witgo.Result[IncomingResponse, ErrorCode] {
+	handled := g.handler.Handle(req, options)
+	if handled.Err != nil {
+		return witgo.Err[IncomingResponse, ErrorCode](*handled.Err)
+	}
+	return g.await(*handled.Ok)
+}
+
+// await 等待 future 就绪后丢弃它，返回 future-incoming-response.get 的结果。
+func (g *testGuest) await(future FutureIncomingResponse) witgo.Result[IncomingResponse, ErrorCode] {
+	ctx := context.Background()
+	defer g.futures.Drop(ctx, future)
+
+	got := g.futures.Get(ctx, future)
+	require.True(g.t, got.IsSome())
+	require.NotNil(g.t, got.Some.Ok)
+	return *got.Some.Ok
+}
+
+// newOptions 创建 request-options，零值表示不设置对应的超时。
+func (g *testGuest) newOptions(connect, firstByte, betweenBytes time.Duration) witgo.Option[RequestOptions] {
+	ctx := context.Background()
+	opts := g.options.Constructor()
+	for _, set := range []struct {
+		d   time.Duration
+		set func(context.Context, RequestOptions, witgo.Option[Duration]) witgo.UnitResult
+	}{
+		{connect, g.options.SetConnectTimeout},
+		{firstByte, g.options.SetFirstByteTimeout},
+		{betweenBytes, g.options.SetBetweenBytesTimeout},
+	} {
+		if set.d > 0 {
+			require.Equal(g.t, witgo.UintOk(), set.set(ctx, opts, witgo.Some(Duration(set.d))))
+		}
+	}
+	return witgo.Some(opts)
+}
+
+// write 获取 outgoing-body 的输出流并写入 data，写入结束后丢弃输出流。
+func (g *testGuest) write(body OutgoingBody, data []byte) error {
+	result := g.bodies.Write(context.Background(), body)
+	require.NotNil(g.t, result.Ok)
+	defer g.hm.Streams.Remove(*result.Ok)
+	stream, ok := g.hm.Streams.Get(*result.Ok)
+	require.True(g.t, ok)
+	if _, err := stream.Writer.Write(data); err != nil {
+		return err
+	}
+	return stream.Flusher.Flush()
+}
+
+// read 读取 incoming-body 的全部内容，读取结束后丢弃输入流。
+// 返回的错误是输入流报告的错误，正常结束时为 nil。
+func (g *testGuest) read(body IncomingBody) ([]byte, error) {
+	result := g.incoming.Stream(context.Background(), body)
+	require.NotNil(g.t, result.Ok)
+	defer g.hm.Streams.Remove(*result.Ok)
+	stream, ok := g.hm.Streams.Get(*result.Ok)
+	require.True(g.t, ok)
+
+	var data []byte
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		n, err := stream.Reader.Read(buf)
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return data, err
+		}
+		if n == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	g.t.Fatal("timed out reading the body")
+	return nil, nil
+}
+
+// consume 取得响应的 incoming-body。
+func (g *testGuest) consume(resp IncomingResponse) IncomingBody {
+	body := g.responses.Consume(context.Background(), resp)
+	require.NotNil(g.t, body.Ok)
+	return *body.Ok
+}
+
+// recordingListener 记录服务端从所有连接上读到的原始字节。
+type recordingListener struct {
+	net.Listener
+
+	mu   sync.Mutex
+	data bytes.Buffer
+}
+
+func (l *recordingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: conn, l: l}, nil
+}
+
+func (l *recordingListener) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.data.String()
+}
+
+type recordingConn struct {
+	net.Conn
+	l *recordingListener
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.l.mu.Lock()
+	c.l.data.Write(p[:n])
+	c.l.mu.Unlock()
+	return n, err
+}
+
+// newRecordingServer 启动一个记录原始请求的 httptest 服务器。
+func newRecordingServer(t *testing.T, handler gohttp.Handler) (*httptest.Server, *recordingListener) {
+	srv := httptest.NewUnstartedServer(handler)
+	l := &recordingListener{Listener: srv.Listener}
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, l
+}
+
+func TestPreserveHeaderOrder(t *testing.T) {
+	srv, recorded := newRecordingServer(t, gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if r.URL.Path == "/redirect" {
+			gohttp.Redirect(w, r, "/final", gohttp.StatusFound)
+		}
+	}))
+	host := strings.TrimPrefix(srv.URL, "http://")
+	headers := []string{"X-Zeta", "1", "content-type", "text/plain", "x-alpha", "2", "x-zeta", "3"}
+
+	g := newTestGuest(t, &Config{Client: ClientConfig{
+		PreserveHeaderOrder: true,
+		CheckRedirect:       FollowRedirects(1),
+		Proxy:               gohttp.ProxyURL(nil),
+	}})
+	resp := g.send(g.newRequest("GET", srv.URL+"/plain?q=1", headers...), witgo.None[RequestOptions]())
+	require.NotNil(t, resp.Ok)
+	require.Equal(t, uint16(200), g.responses.Status(context.Background(), *resp.Ok))
+	require.Equal(t, "GET /plain?q=1 HTTP/1.1\r\n"+
+		"Host: "+host+"\r\n"+
+		"X-Zeta: 1\r\n"+
+		"content-type: text/plain\r\n"+
+		"x-alpha: 2\r\n"+
+		"x-zeta: 3\r\n"+
+		"\r\n", recorded.String())
+
+	// 跟随重定向产生的请求同样保持 Guest 的顺序和大小写。
+	// net/http 在丢弃请求体的重定向中删除了 Content-Type，添加的 Referer 写在最后
+	recorded.mu.Lock()
+	recorded.data.Reset()
+	recorded.mu.Unlock()
+	resp = g.send(g.newRequest("GET", srv.URL+"/redirect", headers...), witgo.None[RequestOptions]())
+	require.NotNil(t, resp.Ok)
+	require.Equal(t, uint16(200), g.responses.Status(context.Background(), *resp.Ok))
+	requests := strings.SplitAfter(recorded.String(), "\r\n\r\n")
+	require.Len(t, requests, 3)
+	require.Equal(t, "GET /final HTTP/1.1\r\n"+
+		"Host: "+host+"\r\n"+
+		"X-Zeta: 1\r\n"+
+		"x-alpha: 2\r\n"+
+		"x-zeta: 3\r\n"+
+		"Referer: "+srv.URL+"/redirect\r\n"+
+		"\r\n", requests[1])
+}
+
+func TestPreserveHeaderOrderHost(t *testing.T) {
+	srv, recorded := newRecordingServer(t, gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {}))
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	g := newTestGuest(t, &Config{
+		Client: ClientConfig{PreserveHeaderOrder: true, Proxy: gohttp.ProxyURL(nil)},
+		Policy: EgressPolicy{AllowedAuthorities: []string{host}},
+	})
+
+	// Guest 设置的 Host 只保留名称的大小写和位置，值总是经过出站策略检查的 authority
+	resp := g.send(g.newRequest("GET", srv.URL+"/", "x-a", "1", "host", "internal.example", "x-b", "2"), witgo.None[RequestOptions]())
+	require.NotNil(t, resp.Ok)
+	require.Equal(t, "GET / HTTP/1.1\r\n"+
+		"x-a: 1\r\n"+
+		"host: "+host+"\r\n"+
+		"x-b: 2\r\n"+
+		"\r\n", recorded.String())
+}
+
+func TestPreserveHeaderOrderProxy(t *testing.T) {
+	target := httptest.NewTLSServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		io.WriteString(w, r.Header.Get("X-Guest"))
+	}))
+	defer target.Close()
+
+	var connectAuth string
+	proxy, recorded := newRecordingServer(t, gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if r.Method != gohttp.MethodConnect {
+			// 转发代理：直接返回，只检查收到的原始请求
+			return
+		}
+		connectAuth = r.Header.Get("Proxy-Authorization")
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(gohttp.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(gohttp.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	proxyURL.User = url.UserPassword("user", "pass")
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(target.Certificate())
+	g := newTestGuest(t, &Config{Client: ClientConfig{
+		PreserveHeaderOrder: true,
+		Proxy:               gohttp.ProxyURL(proxyURL),
+		RootCAs:             roots,
+	}})
+
+	// http 请求以绝对形式发给代理，并带上代理的认证信息
+	resp := g.send(g.newRequest("GET", "http://example.invalid/x", "x-b", "1", "X-A", "2"), witgo.None[RequestOptions]())
+	require.NotNil(t, resp.Ok)
+	require.Equal(t, "GET http://example.invalid/x HTTP/1.1\r\n"+
+		"Host: example.invalid\r\n"+
+		"x-b: 1\r\n"+
+		"X-A: 2\r\n"+
+		"Proxy-Authorization: "+auth+"\r\n"+
+		"\r\n", recorded.String())
+
+	// https 请求通过 CONNECT 隧道发送
+	resp = g.send(g.newRequest("GET", target.URL+"/", "X-Guest", "tunneled"), witgo.None[RequestOptions]())
+	require.NotNil(t, resp.Ok)
+	require.Equal(t, uint16(200), g.responses.Status(context.Background(), *resp.Ok))
+	require.Equal(t, auth, connectAuth)
+	require.Contains(t, recorded.String(), "CONNECT "+strings.TrimPrefix(target.URL, "https://")+" HTTP/1.1\r\n")
+}
+
+func TestDropPendingResponse(t *testing.T) {
+	for name, respond := range map[string]bool{
+		// 响应还没有到达时丢弃 future，请求被取消
+		"pending": false,
+		// 响应已经到达但还没有被 Guest 取走时丢弃 future，响应被关闭
+		"ready": true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			started := make(chan struct{})
+			cancelled := make(chan struct{})
+			done := make(chan struct{})
+			srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+				if respond {
+					w.WriteHeader(gohttp.StatusOK)
+					w.(gohttp.Flusher).Flush()
+				}
+				close(started)
+				select {
+				case <-r.Context().Done():
+					close(cancelled)
+				case <-done:
+				}
+			}))
+			defer srv.Close()
+			defer close(done)
+
+			g := newTestGuest(t, &Config{})
+			handled := g.handler.Handle(g.newRequest("GET", srv.URL), witgo.None[RequestOptions]())
+			require.NotNil(t, handled.Ok)
+			<-started
+			if respond {
+				future, ok := g.hm.Futures.Get(*handled.Ok)
+				require.True(t, ok)
+				<-future.Pollable.Channel()
+			}
+
+			g.futures.Drop(context.Background(), *handled.Ok)
+			select {
+			case <-cancelled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("request was not cancelled after the future was dropped")
+			}
+		})
+	}
+}
@@ -2,8 +2,7 @@ package v0_2
 
 import (
 	"context"
-	"maps"
-	"strconv"
+	gohttp "net/http"
 
 	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
@@ -42,19 +41,13 @@ func (i *outgoingRequestImpl) Body(_ context.Context, this OutgoingRequest) witg
 		return witgo.Err[OutgoingBody, witgo.Unit](witgo.Unit{})
 	}
 
-	var contentLength *uint64
-	if cl := req.Headers.Get("Content-Length"); len(cl) > 0 {
-		if val, err := strconv.ParseUint(cl, 10, 64); err == nil {
-			contentLength = &val
-		}
-	}
-	req.BodyHandle, req.Body, req.BodyWriter = i.hm.NewOutgoingBody(contentLength, func(trailers manager_http.Fields) error {
-		if req.Request != nil {
-			if req.Request.Trailer != nil {
-				maps.Copy(req.Request.Trailer, trailers)
-			} else {
-				req.Request.Trailer = trailers
-			}
+	contentLength := manager_http.ContentLength(req.Headers)
+	// Trailers 会作为 Request.Trailer 发送，Transport 在读到 Body 的 EOF 之后才会读取它，
+	// 因此无论 handle 在 finish 之前还是之后调用，都只需在关闭 Body 之前填充同一个 map。
+	req.Trailers = make(gohttp.Header)
+	req.BodyHandle, req.Body, req.BodyWriter = i.hm.NewOutgoingBody(contentLength, false, func(trailers *manager_http.Fields) error {
+		for _, e := range trailers.Entries() {
+			req.Trailers.Add(e.Name, e.Value)
 		}
 		return nil
 	})
@@ -99,7 +92,7 @@ func (i *outgoingRequestImpl) Headers(_ context.Context, this OutgoingRequest) H
 	if !ok {
 		return 0
 	}
-	return i.hm.Fields.Add(manager_http.Fields(req.Headers))
+	return i.hm.Fields.AddImmutable(req.Headers)
 }
 
 // --- Setters ---
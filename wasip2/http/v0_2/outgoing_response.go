@@ -2,7 +2,6 @@ package v0_2
 
 import (
 	gohttp "net/http"
-	"strconv"
 
 	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
@@ -52,7 +51,7 @@ func (i *outgoingResponseImpl) Headers(this OutgoingResponse) Headers {
 	if !ok {
 		return 0
 	}
-	return i.hm.Fields.Add(manager_http.Fields(resp.Headers))
+	return i.hm.Fields.AddImmutable(resp.Headers)
 }
 
 func (i *outgoingResponseImpl) Body(this OutgoingResponse) witgo.Result[OutgoingBody, witgo.Unit] {
@@ -64,17 +63,11 @@ func (i *outgoingResponseImpl) Body(this OutgoingResponse) witgo.Result[Outgoing
 		return witgo.Err[OutgoingBody, witgo.Unit](witgo.Unit{})
 	}
 
-	var contentLength *uint64
-	if cl := resp.Headers.Get("Content-Length"); len(cl) > 0 {
-		if val, err := strconv.ParseUint(cl, 10, 64); err == nil {
-			contentLength = &val
-		}
-	}
+	contentLength := manager_http.ContentLength(resp.Headers)
 
-	resp.BodyHandle, resp.Body, resp.BodyWriter = i.hm.NewOutgoingBody(contentLength, func(trailers manager_http.Fields) error {
-		if resp.Response != nil {
-			return trailers.Write(resp.Response)
-		}
+	// trailers 在 Body 关闭之前设置，Server 读完 Body 之后再把它们写给客户端。
+	resp.BodyHandle, resp.Body, resp.BodyWriter = i.hm.NewOutgoingBody(contentLength, true, func(trailers *manager_http.Fields) error {
+		resp.Trailers = trailers
 		return nil
 	})
 	return witgo.Ok[OutgoingBody, witgo.Unit](resp.BodyHandle)
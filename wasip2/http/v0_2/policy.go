@@ -0,0 +1,223 @@
+package v0_2
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	gohttp "net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+var (
+	// errDestinationIPProhibited 表示目标地址位于被禁止访问的网段。
+	errDestinationIPProhibited = errors.New("destination ip prohibited by egress policy")
+	// errRequestBodyTooLarge 表示请求体超过了策略允许的大小。
+	errRequestBodyTooLarge = errors.New("request body exceeds the size allowed by egress policy")
+)
+
+// EgressPolicy 限制 Guest 可以发出的出站请求，零值不做任何限制。
+// 策略在 outgoing-handler.handle 开始发送请求之前检查，被拒绝的请求不会产生任何网络连接。
+type EgressPolicy struct {
+	// AllowedAuthorities 不为空时，只允许访问匹配的 authority。
+	// 条目可以是 "example.com"、"example.com:8080"、"[::1]:80" 或 "*.example.com"，
+	// 不带端口时匹配任意端口，"*" 匹配所有 authority。
+	AllowedAuthorities []string
+	// DeniedAuthorities 中匹配的 authority 总是被拒绝，优先于 AllowedAuthorities。
+	DeniedAuthorities []string
+
+	// AllowedSchemes 不为空时，只允许使用其中的 scheme。
+	AllowedSchemes []string
+	// DeniedSchemes 中的 scheme 总是被拒绝。
+	DeniedSchemes []string
+
+	// AllowedMethods 不为空时，只允许使用其中的方法。
+	AllowedMethods []string
+
+	// StripHeaders 中的头部会在发送前从请求中删除。
+	StripHeaders []string
+	// InjectHeaders 中的头部会在发送前覆盖请求中的同名头部。
+	InjectHeaders gohttp.Header
+
+	// MaxRequestBodySize 大于 0 时限制请求体的大小。
+	MaxRequestBodySize int64
+
+	// BlockPrivateIPs 在 DNS 解析之后、建立连接之前拒绝回环、链路本地、私有和未指定地址，防止 DNS 重绑定。
+	// Config.Transport 是 *http.Transport 时检查它的 DialContext 和 DialTLSContext 建立的连接，
+	// 检查发生在连接建立之后、发送请求之前；其他 RoundTripper（包括包装了 *http.Transport 的）无法检查，
+	// 此时所有请求都以 configuration-error 失败。
+	// 经由代理发送的请求检查的是代理的地址。
+	BlockPrivateIPs bool
+	// AllowedNetworks 中的网段不受 BlockPrivateIPs 限制，例如位于内网的代理或受信任的服务。
+	AllowedNetworks []netip.Prefix
+
+	// Check 不为 nil 时，在以上规则都通过后对最终的请求做自定义检查，返回错误即拒绝请求。
+	Check func(req *gohttp.Request) error
+}
+
+// check 检查并改写即将发送的请求，请求被拒绝时返回对应的 ErrorCode。
+func (p *EgressPolicy) check(req *gohttp.Request) *ErrorCode {
+	denied := &ErrorCode{HTTPRequestDenied: &witgo.Unit{}}
+
+	scheme := req.URL.Scheme
+	if len(p.AllowedSchemes) > 0 && !containsFold(p.AllowedSchemes, scheme) {
+		return denied
+	}
+	if containsFold(p.DeniedSchemes, scheme) {
+		return denied
+	}
+
+	host, port := req.URL.Hostname(), req.URL.Port()
+	if port == "" {
+		port = "443"
+		if scheme == "http" {
+			port = "80"
+		}
+	}
+	if matchAuthority(p.DeniedAuthorities, host, port) {
+		return denied
+	}
+	if len(p.AllowedAuthorities) > 0 && !matchAuthority(p.AllowedAuthorities, host, port) {
+		return denied
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && p.prohibited(addr) {
+		return &ErrorCode{DestinationIPProhibited: &witgo.Unit{}}
+	}
+
+	if len(p.AllowedMethods) > 0 && !containsFold(p.AllowedMethods, req.Method) {
+		return denied
+	}
+
+	for _, name := range p.StripHeaders {
+		req.Header.Del(name)
+	}
+	for name, values := range p.InjectHeaders {
+		req.Header[gohttp.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+
+	if p.MaxRequestBodySize > 0 {
+		if cl := req.Header.Get("Content-Length"); cl != "" {
+			if size, err := strconv.ParseUint(cl, 10, 64); err == nil && size > uint64(p.MaxRequestBodySize) {
+				return &ErrorCode{HTTPRequestBodySize: witgo.SomePtr(size)}
+			}
+		}
+		if req.Body != nil && req.Body != gohttp.NoBody {
+			req.Body = &limitedBody{ReadCloser: req.Body, remaining: p.MaxRequestBodySize}
+		}
+	}
+
+	if p.Check != nil {
+		if err := p.Check(req); err != nil {
+			return denied
+		}
+	}
+	return nil
+}
+
+// prohibited 判断是否禁止连接到 addr。
+func (p *EgressPolicy) prohibited(addr netip.Addr) bool {
+	if !p.BlockPrivateIPs {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.AllowedNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsPrivate() || addr.IsUnspecified()
+}
+
+// control 作为 net.Dialer.Control，在 DNS 解析之后、连接建立之前检查目标地址。
+func (p *EgressPolicy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if p.prohibited(addrPort.Addr()) {
+		return errDestinationIPProhibited
+	}
+	return nil
+}
+
+// checkConn 包装一个无法插入 Control 的拨号函数，在连接建立后立即检查对端地址。
+func (p *EgressPolicy) checkConn(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && p.prohibited(tcpAddr.AddrPort().Addr()) {
+			conn.Close()
+			return nil, &net.OpError{Op: "dial", Net: network, Addr: tcpAddr, Err: errDestinationIPProhibited}
+		}
+		return conn, nil
+	}
+}
+
+// limitedBody 在请求体超过上限时让读取失败，从而中止请求。
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errRequestBodyTooLarge
+	}
+	// 多读一个字节，用于区分恰好达到上限和超过上限。
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return 0, errRequestBodyTooLarge
+	}
+	return n, err
+}
+
+// matchAuthority 判断 host 和 port 是否匹配 patterns 中的任意一项。
+func matchAuthority(patterns []string, host, port string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		patternHost, patternPort := pattern, ""
+		if h, p, err := net.SplitHostPort(pattern); err == nil {
+			patternHost, patternPort = h, p
+		} else {
+			patternHost = strings.Trim(pattern, "[]")
+		}
+		if patternPort != "" && patternPort != port {
+			continue
+		}
+		patternHost = strings.ToLower(strings.TrimSuffix(patternHost, "."))
+		if suffix, ok := strings.CutPrefix(patternHost, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == patternHost {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
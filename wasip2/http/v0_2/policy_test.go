@@ -0,0 +1,191 @@
+package v0_2
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	gohttp "net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEgressPolicyCheck(t *testing.T) {
+	newRequest := func(method, rawURL string) *gohttp.Request {
+		req, err := gohttp.NewRequest(method, rawURL, nil)
+		require.NoError(t, err)
+		return req
+	}
+
+	p := &EgressPolicy{
+		AllowedAuthorities: []string{"*.example.com", "api.test:8443", "[::1]"},
+		DeniedAuthorities:  []string{"evil.example.com"},
+		DeniedSchemes:      []string{"http"},
+		AllowedMethods:     []string{"GET", "POST"},
+	}
+	require.Nil(t, p.check(newRequest("GET", "https://www.example.com/")))
+	require.Nil(t, p.check(newRequest("get", "https://API.test:8443/")))
+	require.Nil(t, p.check(newRequest("GET", "https://[::1]:9000/")))
+	for _, req := range []*gohttp.Request{
+		newRequest("GET", "https://evil.example.com/"),
+		newRequest("GET", "https://example.com/"),
+		newRequest("GET", "https://api.test/"),
+		newRequest("GET", "http://www.example.com/"),
+		newRequest("DELETE", "https://www.example.com/"),
+	} {
+		code := p.check(req)
+		require.NotNil(t, code, req.Method+" "+req.URL.String())
+		require.NotNil(t, code.HTTPRequestDenied)
+	}
+
+	// 请求头先删除再注入，注入的值覆盖 Guest 设置的同名头部
+	p = &EgressPolicy{
+		StripHeaders:  []string{"cookie"},
+		InjectHeaders: gohttp.Header{"authorization": {"Bearer host"}},
+	}
+	req := newRequest("GET", "https://example.com/")
+	req.Header.Set("Cookie", "a=b")
+	req.Header.Set("Authorization", "Bearer guest")
+	require.Nil(t, p.check(req))
+	require.Empty(t, req.Header.Get("Cookie"))
+	require.Equal(t, []string{"Bearer host"}, req.Header.Values("Authorization"))
+
+	// 声明的长度超过上限时直接拒绝，否则在读取时限制
+	p = &EgressPolicy{MaxRequestBodySize: 4}
+	req = newRequest("POST", "https://example.com/")
+	req.Header.Set("Content-Length", "5")
+	code := p.check(req)
+	require.NotNil(t, code)
+	require.Equal(t, uint64(5), *code.HTTPRequestBodySize.Some)
+	req, err := gohttp.NewRequest("POST", "https://example.com/", io.NopCloser(strings.NewReader("hello")))
+	require.NoError(t, err)
+	require.Nil(t, p.check(req))
+	_, err = io.ReadAll(req.Body)
+	require.ErrorIs(t, err, errRequestBodyTooLarge)
+
+	// IP 字面量在发送之前检查，AllowedNetworks 中的地址不受限制
+	p = &EgressPolicy{
+		BlockPrivateIPs: true,
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+	}
+	code = p.check(newRequest("GET", "http://127.0.0.1/"))
+	require.NotNil(t, code)
+	require.NotNil(t, code.DestinationIPProhibited)
+	require.Nil(t, p.check(newRequest("GET", "http://10.1.2.3/")))
+	require.Nil(t, p.check(newRequest("GET", "http://93.184.216.34/")))
+	require.ErrorIs(t, p.control("tcp", "192.168.1.1:80", nil), errDestinationIPProhibited)
+
+	p = &EgressPolicy{Check: func(req *gohttp.Request) error {
+		if req.URL.Path == "/admin" {
+			return io.EOF
+		}
+		return nil
+	}}
+	require.Nil(t, p.check(newRequest("GET", "https://example.com/")))
+	require.NotNil(t, p.check(newRequest("GET", "https://example.com/admin")))
+}
+
+func TestEgressPolicyRedirect(t *testing.T) {
+	var deniedHits atomic.Int32
+	denied := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		deniedHits.Add(1)
+	}))
+	defer denied.Close()
+
+	var referer atomic.Value
+	allowed := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		switch r.URL.Path {
+		case "/denied":
+			gohttp.Redirect(w, r, denied.URL+"/", gohttp.StatusFound)
+		case "/local":
+			gohttp.Redirect(w, r, "/final", gohttp.StatusFound)
+		default:
+			referer.Store(r.Header.Get("Referer"))
+		}
+	}))
+	defer allowed.Close()
+	allowedURL, err := url.Parse(allowed.URL)
+	require.NoError(t, err)
+
+	cfg := &Config{
+		Client: ClientConfig{CheckRedirect: FollowRedirects(5)},
+		Policy: EgressPolicy{
+			AllowedAuthorities: []string{allowedURL.Host},
+			StripHeaders:       []string{"Referer"},
+		},
+	}
+
+	// 被跟随的重定向指向策略不允许的 authority 时，请求被拒绝且不会连接到目标
+	req, err := gohttp.NewRequest("GET", allowed.URL+"/denied", nil)
+	require.NoError(t, err)
+	require.Nil(t, cfg.Policy.check(req))
+	_, err = cfg.httpClient().Do(req)
+	require.Error(t, err)
+	require.NotNil(t, mapGoErrToWasiHttpErr(err).HTTPRequestDenied)
+	require.Zero(t, deniedHits.Load())
+
+	// 每一跳都会按策略改写请求头，net/http 在重定向时添加的 Referer 也会被删除
+	req, err = gohttp.NewRequest("GET", allowed.URL+"/local", nil)
+	require.NoError(t, err)
+	require.Nil(t, cfg.Policy.check(req))
+	resp, err := cfg.httpClient().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, gohttp.StatusOK, resp.StatusCode)
+	require.Equal(t, "", referer.Load())
+}
+
+func TestBlockPrivateIPsTransport(t *testing.T) {
+	var hits atomic.Int32
+	handler := gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		hits.Add(1)
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(handler)
+	defer tlsSrv.Close()
+	policy := EgressPolicy{BlockPrivateIPs: true}
+
+	t.Run("dial tls", func(t *testing.T) {
+		// 调用者设置的 DialTLSContext 建立的连接同样被检查
+		transport := tlsSrv.Client().Transport.(*gohttp.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialTLSContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return tls.Dial(network, tlsSrv.Listener.Addr().String(), transport.TLSClientConfig)
+		}
+		cfg := &Config{Transport: transport, Policy: policy}
+		req, err := gohttp.NewRequest("GET", "https://example.com/", nil)
+		require.NoError(t, err)
+		_, err = cfg.httpClient().Do(req)
+		require.Error(t, err)
+		require.NotNil(t, mapGoErrToWasiHttpErr(err).DestinationIPProhibited)
+		require.Zero(t, hits.Load())
+	})
+
+	t.Run("wrapped transport", func(t *testing.T) {
+		// 包装了 Transport 的 RoundTripper（例如追踪或重试）无法检查，请求以 configuration-error 失败而不是绕过检查
+		wrapped := roundTripperFunc(func(req *gohttp.Request) (*gohttp.Response, error) {
+			return gohttp.DefaultTransport.RoundTrip(req)
+		})
+		cfg := &Config{Transport: wrapped, Policy: policy}
+		req, err := gohttp.NewRequest("GET", srv.URL, nil)
+		require.NoError(t, err)
+		_, err = cfg.httpClient().Do(req)
+		require.Error(t, err)
+		require.NotNil(t, mapGoErrToWasiHttpErr(err).ConfigurationError)
+		require.Zero(t, hits.Load())
+
+		// 没有开启 BlockPrivateIPs 时照常使用
+		cfg = &Config{Transport: wrapped}
+		resp, err := cfg.httpClient().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, int32(1), hits.Load())
+	})
+}
@@ -0,0 +1,411 @@
+package v0_2
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	gohttp "net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
+)
+
+// fieldsKey 用于在请求的 context 中保存 Guest 设置的原始头部。
+type fieldsKey struct{}
+
+// withRequestFields 保存 Guest 设置的原始头部，rawTransport 按它的顺序和大小写发送请求头。
+func withRequestFields(ctx context.Context, fields *manager_http.Fields) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// rawTransport 是一个最小的 HTTP/1.1 客户端，按 Guest 设置的顺序和大小写发送请求头。
+// net/http 的 Transport 总是会规范化头部名称并按字母顺序发送，无法满足对原始头部做签名之类的需求。
+// 支持 http 和 https 代理，每个请求使用一个新的连接，响应体关闭时连接随之关闭。
+type rawTransport struct {
+	dialer    *net.Dialer
+	tlsConfig *tls.Config
+	proxy     func(*gohttp.Request) (*url.URL, error)
+}
+
+func (t *rawTransport) RoundTrip(req *gohttp.Request) (*gohttp.Response, error) {
+	ctx := req.Context()
+	trace := httptrace.ContextClientTrace(ctx)
+
+	var proxyURL *url.URL
+	if t.proxy != nil {
+		var err error
+		if proxyURL, err = t.proxy(req); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+	}
+
+	addr := hostPort(req.URL)
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+	conn, err := t.connect(ctx, req.URL, proxyURL)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+	}
+
+	// 请求被取消时关闭连接，中止所有读写
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	fail := func(err error) (*gohttp.Response, error) {
+		stop()
+		conn.Close()
+		closeBody(req)
+		if ctxErr := context.Cause(ctx); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(conn)
+	// 经由代理发送的 http 请求使用绝对形式的请求目标，https 请求已经通过 CONNECT 建立了隧道。
+	var forwardProxy *url.URL
+	if proxyURL != nil && req.URL.Scheme == "http" {
+		forwardProxy = proxyURL
+	}
+	chunked := writeRequestHead(bw, req, forwardProxy)
+	if err := bw.Flush(); err != nil {
+		return fail(err)
+	}
+	if trace != nil && trace.WroteHeaders != nil {
+		trace.WroteHeaders()
+	}
+
+	// 请求体和响应并行处理，服务端可能在读完请求体之前就返回响应。
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeRequestBody(bw, req, chunked)
+		if trace != nil && trace.WroteRequest != nil {
+			trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+		}
+		writeErr <- err
+	}()
+
+	br := bufio.NewReader(conn)
+	if _, err := br.Peek(1); err != nil {
+		return fail(firstError(writeErr, err))
+	}
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+
+	var resp *gohttp.Response
+	for {
+		resp, err = gohttp.ReadResponse(br, req)
+		if err != nil {
+			return fail(firstError(writeErr, err))
+		}
+		// 跳过 100 Continue 之类的中间响应
+		if resp.StatusCode < 100 || resp.StatusCode >= 200 || resp.StatusCode == gohttp.StatusSwitchingProtocols {
+			break
+		}
+	}
+	resp.Body = &rawBody{ReadCloser: resp.Body, conn: conn, stop: stop}
+	return resp, nil
+}
+
+// connect 建立到 target 的连接。proxyURL 不为 nil 时先连接到代理，https 请求通过 CONNECT 建立隧道。
+// https 请求会在最后完成与 target 的 TLS 握手。
+func (t *rawTransport) connect(ctx context.Context, target, proxyURL *url.URL) (net.Conn, error) {
+	if proxyURL == nil {
+		return t.dial(ctx, target.Scheme, hostPort(target), target.Hostname())
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	conn, err := t.dial(ctx, proxyURL.Scheme, hostPort(proxyURL), proxyURL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme == "http" {
+		return conn, nil
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	err = proxyConnect(conn, hostPort(target), proxyURL)
+	if !stop() {
+		err = context.Cause(ctx)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return t.handshake(ctx, conn, target.Hostname())
+}
+
+// dial 建立到 addr 的连接，https 会完成 TLS 握手。
+func (t *rawTransport) dial(ctx context.Context, scheme, addr, host string) (net.Conn, error) {
+	conn, err := t.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == "http" {
+		return conn, nil
+	}
+	return t.handshake(ctx, conn, host)
+}
+
+// handshake 在 conn 上完成与 host 的 TLS 握手，失败时关闭 conn。
+func (t *rawTransport) handshake(ctx context.Context, conn net.Conn, host string) (net.Conn, error) {
+	cfg := t.tlsConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	cfg.NextProtos = []string{"http/1.1"}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// proxyConnect 通过 CONNECT 请求让代理建立到 addr 的隧道。
+func proxyConnect(conn net.Conn, addr string, proxyURL *url.URL) error {
+	connectReq := &gohttp.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(gohttp.Header),
+	}
+	if auth := proxyAuthorization(proxyURL); auth != "" {
+		connectReq.Header.Set("Proxy-Authorization", auth)
+	}
+	if err := connectReq.Write(conn); err != nil {
+		return err
+	}
+	// 隧道建立之前服务端不会发送响应之外的数据，不会读走 TLS 握手的内容。
+	resp, err := gohttp.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != gohttp.StatusOK {
+		return fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+	return nil
+}
+
+// proxyAuthorization 根据代理地址中的用户名和密码生成 Proxy-Authorization 的值。
+func proxyAuthorization(proxyURL *url.URL) string {
+	if proxyURL == nil || proxyURL.User == nil {
+		return ""
+	}
+	password, _ := proxyURL.User.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username()+":"+password))
+}
+
+// hostPort 返回 u 的 host:port，没有端口时按 scheme 补充默认端口。
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// writeRequestHead 写出请求行和请求头，返回请求体是否使用 chunked 编码。
+// forwardProxy 不为 nil 时请求经由该代理转发，请求目标使用绝对形式。
+//
+// 头部按 Guest 设置的顺序和大小写写出，重定向产生的请求也是如此。出站策略或重定向可能删除或覆盖了部分头部，
+// 未被修改的头部原样写出，被修改的头部在第一次出现的位置写出新的值，新增的头部写在最后。
+// Host 和 Content-Length 的值由请求本身决定，Guest 设置了它们时在原来的位置使用 Guest 的大小写写出。
+func writeRequestHead(w *bufio.Writer, req *gohttp.Request, forwardProxy *url.URL) (chunked bool) {
+	fields, _ := req.Context().Value(fieldsKey{}).(*manager_http.Fields)
+
+	hasBody := req.Body != nil && req.Body != gohttp.NoBody
+	chunked = hasBody && req.ContentLength <= 0
+
+	target := req.URL.RequestURI()
+	if forwardProxy != nil {
+		target = req.URL.Scheme + "://" + req.URL.Host + target
+	}
+	fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", req.Method, target)
+
+	// Host 的值总是来自经过出站策略检查的 authority，Guest 在头部中设置的值不会被发送
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	var contentLength string
+	switch {
+	case chunked:
+	case hasBody || methodExpectsBody(req.Method):
+		contentLength = strconv.FormatInt(max(req.ContentLength, 0), 10)
+	}
+
+	entries := fields.Entries()
+	written := make(map[string]bool)
+	if !fields.Has("Host") {
+		fmt.Fprintf(w, "Host: %s\r\n", host)
+		written["Host"] = true
+	}
+
+	for _, e := range entries {
+		key := gohttp.CanonicalHeaderKey(e.Name)
+		if written[key] {
+			continue
+		}
+		switch key {
+		case "Host":
+			fmt.Fprintf(w, "%s: %s\r\n", e.Name, host)
+			written[key] = true
+			continue
+		case "Content-Length":
+			if contentLength != "" {
+				fmt.Fprintf(w, "%s: %s\r\n", e.Name, contentLength)
+			}
+			written[key] = true
+			continue
+		case "Transfer-Encoding":
+			// 请求体的编码由这里决定
+			continue
+		}
+		values := req.Header[key]
+		if !slices.Equal(values, fields.Get(e.Name)) {
+			for _, v := range values {
+				fmt.Fprintf(w, "%s: %s\r\n", e.Name, v)
+			}
+			written[key] = true
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\r\n", e.Name, e.Value)
+	}
+	for _, e := range entries {
+		// 上面逐条写出的头部在这里标记为已写出
+		written[gohttp.CanonicalHeaderKey(e.Name)] = true
+	}
+
+	for key, values := range req.Header {
+		if written[key] || key == "Host" || key == "Transfer-Encoding" || key == "Content-Length" {
+			continue
+		}
+		for _, v := range values {
+			fmt.Fprintf(w, "%s: %s\r\n", key, v)
+		}
+	}
+	if auth := proxyAuthorization(forwardProxy); auth != "" && !written["Proxy-Authorization"] && req.Header.Get("Proxy-Authorization") == "" {
+		fmt.Fprintf(w, "Proxy-Authorization: %s\r\n", auth)
+	}
+
+	switch {
+	case chunked:
+		fmt.Fprintf(w, "Transfer-Encoding: chunked\r\n")
+	case contentLength != "" && !written["Content-Length"]:
+		fmt.Fprintf(w, "Content-Length: %s\r\n", contentLength)
+	}
+	fmt.Fprintf(w, "\r\n")
+	return chunked
+}
+
+// writeRequestBody 写出请求体，chunked 编码时在最后写出 trailers。
+func writeRequestBody(w *bufio.Writer, req *gohttp.Request, chunked bool) error {
+	if req.Body == nil || req.Body == gohttp.NoBody {
+		return nil
+	}
+	defer req.Body.Close()
+
+	if !chunked {
+		n, err := io.Copy(w, io.LimitReader(req.Body, req.ContentLength))
+		if err == nil && n != req.ContentLength {
+			err = fmt.Errorf("http: ContentLength=%d with Body length %d", req.ContentLength, n)
+		}
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := req.Body.Read(buf)
+		if n > 0 {
+			fmt.Fprintf(w, "%x\r\n", n)
+			w.Write(buf[:n])
+			w.WriteString("\r\n")
+			if ferr := w.Flush(); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	w.WriteString("0\r\n")
+	for key, values := range req.Trailer {
+		for _, v := range values {
+			fmt.Fprintf(w, "%s: %s\r\n", key, v)
+		}
+	}
+	w.WriteString("\r\n")
+	return w.Flush()
+}
+
+// rawBody 在响应体关闭时关闭连接。
+type rawBody struct {
+	io.ReadCloser
+	conn net.Conn
+	stop func() bool
+	once sync.Once
+}
+
+func (b *rawBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.stop()
+		b.conn.Close()
+	})
+	return err
+}
+
+func closeBody(req *gohttp.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// firstError 优先返回写入请求体时的错误，它通常比读取响应时的错误更能说明原因。
+func firstError(writeErr <-chan error, err error) error {
+	select {
+	case werr := <-writeErr:
+		if werr != nil && !errors.Is(werr, net.ErrClosed) {
+			return werr
+		}
+	default:
+	}
+	return err
+}
+
+// methodExpectsBody 判断没有请求体时是否需要写出 Content-Length: 0。
+func methodExpectsBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
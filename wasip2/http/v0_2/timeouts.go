@@ -0,0 +1,190 @@
+package v0_2
+
+import (
+	"context"
+	"io"
+	gohttp "net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+var (
+	errConnectTimeout = &codeError{
+		msg:  "wasi-http: connect timeout",
+		code: ErrorCode{ConnectionTimeout: &witgo.Unit{}},
+	}
+	errFirstByteTimeout = &codeError{
+		msg:  "wasi-http: first byte timeout",
+		code: ErrorCode{ConnectionReadTimeout: &witgo.Unit{}},
+	}
+	errReadTimeout = &codeError{
+		msg:  "wasi-http: between bytes timeout while reading response body",
+		code: ErrorCode{ConnectionReadTimeout: &witgo.Unit{}},
+	}
+	errWriteTimeout = &codeError{
+		msg:  "wasi-http: between bytes timeout while writing request body",
+		code: ErrorCode{ConnectionWriteTimeout: &witgo.Unit{}},
+	}
+)
+
+type timeoutConfig struct {
+	connect      time.Duration
+	firstByte    time.Duration
+	betweenBytes time.Duration
+}
+
+func newTimeoutConfig(opts *manager_http.RequestOptions) timeoutConfig {
+	var cfg timeoutConfig
+	if opts != nil {
+		if opts.ConnectTimeout != nil {
+			cfg.connect = *opts.ConnectTimeout
+		}
+		if opts.FirstByteTimeout != nil {
+			cfg.firstByte = *opts.FirstByteTimeout
+		}
+		if opts.BetweenBytesTimeout != nil {
+			cfg.betweenBytes = *opts.BetweenBytesTimeout
+		}
+	}
+	return cfg
+}
+
+// requestTimeouts 在单个请求上实现 wasi:http 的三种超时，所有请求共用同一个客户端。
+//   - connect：从开始获取连接到拿到连接，包括拨号和 TLS 握手。
+//   - first-byte：从请求头发送完毕到收到响应头。
+//   - between-bytes：请求体和响应体的每次读取都必须在该时间内取得数据。
+//
+// 超时通过取消请求的 context 中止请求，并以对应的 ErrorCode 报告给 Guest。
+type requestTimeouts struct {
+	cfg    timeoutConfig
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu        sync.Mutex
+	connect   *time.Timer
+	firstByte *time.Timer
+}
+
+func newRequestTimeouts(cfg timeoutConfig) *requestTimeouts {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &requestTimeouts{cfg: cfg, ctx: ctx, cancel: cancel}
+}
+
+// attach 将超时应用到请求上，返回的请求应替代 req 发送。
+func (t *requestTimeouts) attach(req *gohttp.Request) *gohttp.Request {
+	trace := &httptrace.ClientTrace{}
+	if t.cfg.connect > 0 {
+		trace.GetConn = func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connect == nil {
+				t.connect = time.AfterFunc(t.cfg.connect, func() { t.cancel(errConnectTimeout) })
+			}
+		}
+		trace.GotConn = func(httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connect != nil {
+				t.connect.Stop()
+			}
+		}
+	}
+	if t.cfg.firstByte > 0 {
+		trace.WroteHeaders = func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.firstByte == nil {
+				t.firstByte = time.AfterFunc(t.cfg.firstByte, func() { t.cancel(errFirstByteTimeout) })
+			}
+		}
+		trace.GotFirstResponseByte = func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.firstByte != nil {
+				t.firstByte.Stop()
+			}
+		}
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(t.ctx, trace))
+	if t.cfg.betweenBytes > 0 && req.Body != nil && req.Body != gohttp.NoBody {
+		req.Body = &idleTimeoutBody{ReadCloser: req.Body, t: t, err: errWriteTimeout}
+	}
+	return req
+}
+
+// response 在请求完成后调用，将超时应用到响应体上，并把因超时产生的错误替换为对应的错误。
+func (t *requestTimeouts) response(resp *gohttp.Response, err error) (*gohttp.Response, error) {
+	t.mu.Lock()
+	if t.connect != nil {
+		t.connect.Stop()
+	}
+	if t.firstByte != nil {
+		t.firstByte.Stop()
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		t.cancel(nil)
+		return nil, t.cause(err)
+	}
+	body := &idleTimeoutBody{ReadCloser: resp.Body, t: t, err: errReadTimeout}
+	if t.cfg.betweenBytes <= 0 {
+		body.err = nil
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// cause 在请求因超时被取消时返回超时错误，否则原样返回 err。
+func (t *requestTimeouts) cause(err error) error {
+	if cause, ok := context.Cause(t.ctx).(*codeError); ok {
+		return cause
+	}
+	return err
+}
+
+// idleTimeoutBody 要求每次读取都在 between-bytes 时间内完成，关闭时释放请求的 context。
+type idleTimeoutBody struct {
+	io.ReadCloser
+	t *requestTimeouts
+	// err 是超时时报告的错误，为 nil 时不限制读取时间。
+	err *codeError
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	if b.err == nil {
+		n, err := b.ReadCloser.Read(p)
+		if err != nil && err != io.EOF {
+			err = b.t.cause(err)
+		}
+		return n, err
+	}
+
+	timer := time.AfterFunc(b.t.cfg.betweenBytes, func() {
+		b.t.cancel(b.err)
+		// 请求体来自 Guest 的管道，取消 context 并不能让阻塞的读取返回。
+		b.ReadCloser.Close()
+	})
+	n, err := b.ReadCloser.Read(p)
+	if !timer.Stop() {
+		return n, b.err
+	}
+	if err != nil && err != io.EOF {
+		err = b.t.cause(err)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	// 请求体由 Transport 关闭时请求可能还没有结束，只有响应体关闭时才释放 context。
+	if b.err != errWriteTimeout {
+		b.t.cancel(nil)
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+package v0_2
+
+import (
+	"context"
+	"io"
+	"net"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testTimeout = 50 * time.Millisecond
+
+// newStallingServer 启动一个 httptest 服务器，handler 返回后服务端一直等待，直到请求被取消或测试结束。
+func newStallingServer(t *testing.T, handler func(w gohttp.ResponseWriter, r *gohttp.Request)) *httptest.Server {
+	done := make(chan struct{})
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		handler(w, r)
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+	return srv
+}
+
+func TestConnectTimeout(t *testing.T) {
+	// 接受连接但不进行 TLS 握手
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	g := newTestGuest(t, &Config{})
+	start := time.Now()
+	result := g.send(g.newRequest("GET", "https://"+l.Addr().String()), g.newOptions(testTimeout, 0, 0))
+	require.NotNil(t, result.Err)
+	require.NotNil(t, result.Err.ConnectionTimeout)
+	require.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestFirstByteTimeout(t *testing.T) {
+	srv := newStallingServer(t, func(w gohttp.ResponseWriter, r *gohttp.Request) {})
+
+	g := newTestGuest(t, &Config{})
+	start := time.Now()
+	result := g.send(g.newRequest("GET", srv.URL), g.newOptions(0, testTimeout, 0))
+	require.NotNil(t, result.Err)
+	require.NotNil(t, result.Err.ConnectionReadTimeout)
+	require.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestBetweenBytesTimeout(t *testing.T) {
+	t.Run("response body", func(t *testing.T) {
+		srv := newStallingServer(t, func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			io.WriteString(w, "hello")
+			w.(gohttp.Flusher).Flush()
+		})
+
+		// 超时在读取响应体时通过输入流报告
+		g := newTestGuest(t, &Config{})
+		result := g.send(g.newRequest("GET", srv.URL), g.newOptions(0, 0, testTimeout))
+		require.Nil(t, result.Err)
+		data, err := g.read(g.consume(*result.Ok))
+		require.Equal(t, "hello", string(data))
+		code, ok := classifyError(err)
+		require.True(t, ok)
+		require.NotNil(t, code.ConnectionReadTimeout)
+	})
+
+	t.Run("request body", func(t *testing.T) {
+		srv := newStallingServer(t, func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			io.Copy(io.Discard, r.Body)
+		})
+
+		// Guest 没有在超时之前写入请求体
+		g := newTestGuest(t, &Config{})
+		req := g.newRequest("POST", srv.URL)
+		require.NotNil(t, g.requests.Body(context.Background(), req).Ok)
+		result := g.send(req, g.newOptions(0, 0, testTimeout))
+		require.NotNil(t, result.Err)
+		require.NotNil(t, result.Err.ConnectionWriteTimeout)
+	})
+
+	t.Run("not exceeded", func(t *testing.T) {
+		srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			for range 4 {
+				io.WriteString(w, "tick")
+				w.(gohttp.Flusher).Flush()
+				time.Sleep(testTimeout / 2)
+			}
+		}))
+		defer srv.Close()
+
+		// 每次读取都在超时之内，整个响应的耗时超过超时也不会失败
+		g := newTestGuest(t, &Config{})
+		result := g.send(g.newRequest("GET", srv.URL), g.newOptions(0, 0, testTimeout))
+		require.Nil(t, result.Err)
+		data, err := g.read(g.consume(*result.Ok))
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("tick", 4), string(data))
+	})
+}
+
+func TestTimeoutsShareClient(t *testing.T) {
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {}))
+	defer srv.Close()
+
+	// 不同的超时设置共用同一个客户端
+	cfg := &Config{}
+	g := newTestGuest(t, cfg)
+	require.Nil(t, g.send(g.newRequest("GET", srv.URL), g.newOptions(time.Second, 0, 0)).Err)
+	client := cfg.httpClient()
+	require.Nil(t, g.send(g.newRequest("GET", srv.URL), g.newOptions(0, time.Second, time.Second)).Err)
+	require.Nil(t, g.send(g.newRequest("GET", srv.URL), witgo.None[RequestOptions]()).Err)
+	require.Same(t, client, cfg.httpClient())
+}
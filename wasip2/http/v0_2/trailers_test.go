@@ -0,0 +1,126 @@
+package v0_2
+
+import (
+	"context"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	manager_http "github.com/OpenListTeam/wazero-wasip2/manager/http"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newFields 创建 fields，entries 依次为名称和值。
+func (g *testGuest) newFields(entries ...string) Fields {
+	fields := g.fields.Constructor()
+	for i := 0; i+1 < len(entries); i += 2 {
+		require.Nil(g.t, g.fields.Append(context.Background(), fields, entries[i], FieldValue(entries[i+1])).Err)
+	}
+	return fields
+}
+
+// finishIncoming 读完 incoming-body 并等待 future-trailers，返回得到的 trailers。
+func (g *testGuest) finishIncoming(body IncomingBody) ([]byte, witgo.Option[Trailers]) {
+	data, err := g.read(body)
+	require.NoError(g.t, err)
+
+	ctx := context.Background()
+	future := g.incoming.Finish(body)
+	defer g.trailers.Drop(future)
+	got := g.trailers.Get(ctx, future)
+	require.True(g.t, got.IsSome())
+	require.NotNil(g.t, got.Some.Ok)
+	require.NotNil(g.t, got.Some.Ok.Ok)
+	return data, *got.Some.Ok.Ok
+}
+
+func TestOutgoingTrailers(t *testing.T) {
+	type received struct {
+		body     string
+		trailers gohttp.Header
+	}
+	requests := make(chan received, 1)
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		// 请求的 trailers 在读完 body 之后才可用
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{body: string(body), trailers: r.Trailer}
+
+		w.Header().Set("Trailer", "X-Result")
+		io.WriteString(w, "response")
+		w.Header().Set("X-Result", "ok")
+		w.Header().Set(gohttp.TrailerPrefix+"X-Late", "1")
+	}))
+	defer srv.Close()
+
+	g := newTestGuest(t, &Config{})
+	ctx := context.Background()
+	req := g.newRequest("POST", srv.URL, "Trailer", "X-Checksum")
+	body := g.requests.Body(ctx, req)
+	require.NotNil(t, body.Ok)
+	handled := g.handler.Handle(req, witgo.None[RequestOptions]())
+	require.NotNil(t, handled.Ok)
+	require.NoError(t, g.write(*body.Ok, []byte("request")))
+	require.Nil(t, g.bodies.Finish(ctx, *body.Ok, witgo.Some(g.newFields("X-Checksum", "abc"))).Err)
+
+	// outgoing-body.finish 设置的 trailers 在 body 之后发送
+	result := g.await(*handled.Ok)
+	require.Nil(t, result.Err)
+	got := <-requests
+	require.Equal(t, "request", got.body)
+	require.Equal(t, "abc", got.trailers.Get("X-Checksum"))
+
+	// 响应的 trailers 在读完 body 之后通过 future-trailers 得到，声明过的和未声明的都包含在内
+	data, trailers := g.finishIncoming(g.consume(*result.Ok))
+	require.Equal(t, "response", string(data))
+	require.True(t, trailers.IsSome())
+	require.Equal(t, []FieldValue{FieldValue("ok")}, g.fields.Get(ctx, *trailers.Some, "x-result"))
+	require.Equal(t, []FieldValue{FieldValue("1")}, g.fields.Get(ctx, *trailers.Some, "x-late"))
+
+	// trailers 是不可变的
+	set := g.fields.Set(ctx, *trailers.Some, "x-result", []FieldValue{FieldValue("changed")})
+	require.NotNil(t, set.Err)
+	require.NotNil(t, set.Err.Immutable)
+}
+
+func TestIncomingRequestTrailers(t *testing.T) {
+	g := newTestGuest(t, &Config{})
+	handles := make(chan IncomingRequest, 1)
+	done := make(chan struct{})
+	release := sync.OnceFunc(func() { close(done) })
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		handles <- g.hm.IncomingRequests.Add(&manager_http.IncomingRequest{Request: r, Body: r.Body})
+		<-done
+	}))
+	defer srv.Close()
+	defer release()
+
+	// 长度未知的请求使用 chunked 编码，trailers 跟在 body 之后
+	req, err := gohttp.NewRequest("POST", srv.URL, io.NopCloser(strings.NewReader("chunked")))
+	require.NoError(t, err)
+	req.ContentLength = -1
+	req.Trailer = gohttp.Header{"X-Checksum": {"abc"}}
+	sent := make(chan error, 1)
+	go func() {
+		resp, err := gohttp.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		sent <- err
+	}()
+
+	handle := <-handles
+	consumed := newIncomingRequestImpl(g.hm).Consume(handle)
+	require.NotNil(t, consumed.Ok)
+	data, trailers := g.finishIncoming(*consumed.Ok)
+	release()
+	require.NoError(t, <-sent)
+
+	require.Equal(t, "chunked", string(data))
+	require.True(t, trailers.IsSome())
+	require.Equal(t, []FieldValue{FieldValue("abc")}, g.fields.Get(context.Background(), *trailers.Some, "x-checksum"))
+}
@@ -13,11 +13,15 @@ import (
 // --- wasi:http/types@0.2.0 implementation ---
 
 type httpTypes struct {
-	hm *manager_http.HTTPManager
+	hm  *manager_http.HTTPManager
+	cfg *Config
 }
 
-func NewTypes(hm *manager_http.HTTPManager) wasip2.Implementation {
-	return &httpTypes{hm: hm}
+func NewTypes(hm *manager_http.HTTPManager, cfg *Config) wasip2.Implementation {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	return &httpTypes{hm: hm, cfg: cfg}
 }
 
 func (i *httpTypes) Name() string { return "wasi:http/types" }
@@ -30,7 +34,7 @@ func (i *httpTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazer
 
 	hm := h.HTTPManager()
 	// --- fields ---
-	fieldsHandler := newFieldsImpl(hm.Fields)
+	fieldsHandler := newFieldsImpl(hm.Fields, i.cfg)
 	exporter.Export("[constructor]fields", fieldsHandler.Constructor)
 	exporter.Export("[static]fields.from-list", fieldsHandler.FromList)
 	exporter.Export("[resource-drop]fields", fieldsHandler.Drop)
@@ -91,7 +95,7 @@ func (i *httpTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazer
 	exporter.Export("[method]incoming-response.consume", responseHandler.Consume)
 
 	// --- incoming-body ---
-	bodyHandler := newIncomingBodyImpl(hm)
+	bodyHandler := newIncomingBodyImpl(hm, h.MaxReadBufferSize())
 	exporter.Export("[resource-drop]incoming-body", bodyHandler.Drop)
 	exporter.Export("[method]incoming-body.stream", bodyHandler.Stream)
 	exporter.Export("[static]incoming-body.finish", bodyHandler.Finish)
@@ -124,7 +128,7 @@ func (i *httpTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazer
 	exporter.Export("[method]future-incoming-response.get", futureHandler.Get)
 
 	// 导出核心的 handle 函数。
-	handler := newOutgoingHandlerImpl(h.HTTPManager())
+	handler := newOutgoingHandlerImpl(h.HTTPManager(), i.cfg)
 	exporter.Export("handle", handler.Handle)
 
 	// --- incoming-handler (placeholder for world linking) ---
@@ -142,11 +146,9 @@ func (i *httpTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazer
 			return witgo.None[ErrorCode]()
 		}
 
-		// Map the Go error to a wasi:http ErrorCode
-		httpErr := mapGoErrToWasiHttpErr(goErr)
-
-		// Check if it's an unclassified "internal error"
-		if httpErr.InternalError != nil && httpErr.InternalError.Some != nil && *httpErr.InternalError.Some == goErr.Error() {
+		// Map the Go error to a wasi:http ErrorCode, unclassified errors are not HTTP errors
+		httpErr, ok := classifyError(goErr)
+		if !ok {
 			return witgo.None[ErrorCode]()
 		}
 
@@ -158,11 +160,15 @@ func (i *httpTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazer
 // --- wasi:http/outgoing-handler@0.2.0 implementation ---
 
 type outgoingHandler struct {
-	hm *manager_http.HTTPManager
+	hm  *manager_http.HTTPManager
+	cfg *Config
 }
 
-func NewOutgoingHandler(hm *manager_http.HTTPManager) wasip2.Implementation {
-	return &outgoingHandler{hm: hm}
+func NewOutgoingHandler(hm *manager_http.HTTPManager, cfg *Config) wasip2.Implementation {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	return &outgoingHandler{hm: hm, cfg: cfg}
 }
 
 func (i *outgoingHandler) Name() string { return "wasi:http/outgoing-handler" }
@@ -171,7 +177,7 @@ func (i *outgoingHandler) Versions() []string {
 }
 
 func (i *outgoingHandler) Instantiate(_ context.Context, h *wasip2.Host, builder wazero.HostModuleBuilder) error {
-	handler := newOutgoingHandlerImpl(h.HTTPManager())
+	handler := newOutgoingHandlerImpl(h.HTTPManager(), i.cfg)
 	exporter := witgo.NewExporter(builder)
 	exporter.Export("handle", handler.Handle)
 	return nil
@@ -0,0 +1,144 @@
+package wasip2
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// InstantiateModule 实例化一个 Guest，并为它创建独立的资源上下文。
+//
+// 与 Instantiate 不同，这里会为该 Guest 单独实例化一组匿名的宿主模块，
+// 它们绑定到一组只属于该 Guest 的资源管理器上。因此同一个运行时中的多个 Guest
+// 不会共享句柄，也无法访问彼此的 stream、socket 或文件描述符。
+// 当 Guest 模块被关闭（包括 wasi:cli/exit 或运行时关闭）时，它的所有资源都会被释放。
+//
+// 使用 Instance 可以获取该 Guest 的资源上下文，以便 Host 侧向它注入资源。
+func (h *Host) InstantiateModule(ctx context.Context, r wazero.Runtime, compiled wazero.CompiledModule, config wazero.ModuleConfig) (api.Module, error) {
+	inst := &Host{implementations: h.implementations}
+	inst.initManagers()
+	inst.blocker.MaxBlock = h.blocker.MaxBlock
+	inst.virtualNetwork = h.virtualNetwork
+	inst.resolver = h.resolver
+	inst.maxReadBufferSize = h.maxReadBufferSize
+
+	modules := make(map[string]api.Module)
+	for _, impl := range h.implementations {
+		for _, version := range impl.Versions() {
+			moduleName := impl.Name() + "@" + version
+			builder := r.NewHostModuleBuilder(moduleName)
+			if err := impl.Instantiate(ctx, inst, builder); err != nil {
+				inst.release(ctx)
+				return nil, err
+			}
+
+			cm, err := builder.Compile(ctx)
+			if err != nil {
+				inst.release(ctx)
+				return nil, err
+			}
+			// 匿名模块不会占用运行时中的模块名，只能通过 ImportResolver 链接到该 Guest。
+			mod, err := r.InstantiateModule(ctx, cm, wazero.NewModuleConfig().WithName(""))
+			if err != nil {
+				inst.release(ctx)
+				return nil, err
+			}
+			inst.hostModules = append(inst.hostModules, mod)
+			modules[moduleName] = mod
+		}
+	}
+
+	ctx = experimental.WithImportResolver(ctx, func(name string) api.Module {
+		if mod, ok := modules[name]; ok {
+			return mod
+		}
+		return nil
+	})
+
+	var (
+		guest  api.Module
+		closed bool
+	)
+	ctx = experimental.WithCloseNotifier(ctx, experimental.CloseNotifyFunc(func(ctx context.Context, _ uint32) {
+		h.instancesMu.Lock()
+		closed = true
+		if guest != nil {
+			delete(h.instances, guest)
+		}
+		h.instancesMu.Unlock()
+		inst.release(ctx)
+	}))
+
+	mod, err := r.InstantiateModule(ctx, compiled, config)
+	if err != nil {
+		inst.release(ctx)
+		return nil, err
+	}
+
+	h.instancesMu.Lock()
+	guest = mod
+	// Guest 可能已经在 start 函数中退出，此时资源已被释放，不再登记。
+	if !closed {
+		if h.instances == nil {
+			h.instances = make(map[api.Module]*Host)
+		}
+		h.instances[mod] = inst
+	}
+	h.instancesMu.Unlock()
+	return mod, nil
+}
+
+// Instance 返回通过 InstantiateModule 创建的 Guest 所独享的资源上下文。
+// 返回的 Host 的各个管理器只对该 Guest 可见。
+func (h *Host) Instance(guest api.Module) (*Host, bool) {
+	h.instancesMu.Lock()
+	defer h.instancesMu.Unlock()
+	inst, ok := h.instances[guest]
+	return inst, ok
+}
+
+// release 释放实例持有的所有资源，并关闭为它创建的宿主模块。
+func (h *Host) release(ctx context.Context) {
+	// 先打断所有阻塞中的调用
+	h.blocker.Close()
+
+	// 先释放依赖 stream 的上层资源，最后再释放 stream 和 pollable 本身。
+	h.httpManager.Clear()
+	h.tlsManager.Clear()
+	h.resolveAddressStreamManager.Clear()
+	h.tcpSocketManager.Clear()
+	h.udpSocketManager.Clear()
+	h.networkManager.Clear()
+	h.directoryEntryStreamManager.Clear()
+	h.filesystemManager.Clear()
+	h.preopensMu.Lock()
+	for _, root := range h.preopens {
+		root.File.Close()
+	}
+	h.preopens = nil
+	h.preopensMu.Unlock()
+	h.stdioMu.Lock()
+	stdio := h.stdio
+	h.stdio = nil
+	h.stdioMu.Unlock()
+	if stdio != nil {
+		stdio.Close()
+	}
+	h.terminalInputManager.Clear()
+	h.terminalOutputManager.Clear()
+	h.streamManager.Clear()
+	h.pollManager.Clear()
+	h.errorManager.Clear()
+
+	// 关闭通知可能在运行时持有 store 锁时触发（例如 Runtime.Close），
+	// 在这里同步关闭宿主模块会死锁，因此放到后台进行。已关闭的模块再次关闭是无害的。
+	hostModules := h.hostModules
+	h.hostModules = nil
+	go func() {
+		for _, mod := range hostModules {
+			_ = mod.Close(ctx)
+		}
+	}()
+}
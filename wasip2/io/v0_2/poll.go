@@ -2,7 +2,6 @@ package v0_2
 
 import (
 	"context"
-	"reflect"
 
 	"github.com/OpenListTeam/wazero-wasip2/manager/io"
 )
@@ -10,10 +9,11 @@ import (
 // pollImpl 结构体持有 wasi:io/poll 的具体实现逻辑。
 type pollImpl struct {
 	pm *io.PollManager
+	b  *io.Blocker
 }
 
-func newPollImpl(pm *io.PollManager) *pollImpl {
-	return &pollImpl{pm: pm}
+func newPollImpl(pm *io.PollManager, b *io.Blocker) *pollImpl {
+	return &pollImpl{pm: pm, b: b}
 }
 
 // DropPollable 是 pollable 资源的析构函数。
@@ -34,15 +34,17 @@ func (i *pollImpl) Ready(_ context.Context, this Pollable) bool {
 }
 
 // Block 实现 [method]pollable.block 方法。
-func (i *pollImpl) Block(_ context.Context, this Pollable) {
+func (i *pollImpl) Block(ctx context.Context, this Pollable) {
 	p, ok := i.pm.Get(this)
 	if !ok {
 		return
 	}
-	p.Block()
+	ctx, cancel := i.b.Context(ctx)
+	defer cancel()
+	trap(i.b.Wait(ctx, p.Channel()))
 }
 
-func (i *pollImpl) Poll(_ context.Context, handles []Pollable) []uint32 {
+func (i *pollImpl) Poll(ctx context.Context, handles []Pollable) []uint32 {
 	if len(handles) == 0 {
 		panic("poll input list cannot be empty")
 	}
@@ -58,19 +60,41 @@ func (i *pollImpl) Poll(_ context.Context, handles []Pollable) []uint32 {
 		return readyIndexes
 	}
 
-	// 2. 构造 select cases 并阻塞
-	cases := make([]reflect.SelectCase, len(handles))
-	for j, handle := range handles {
-		var ch <-chan struct{} = make(chan struct{}) // 默认创建一个永不就绪的 channel
+	// 2. 所有 pollable 共用一个唤醒 channel，任意一个就绪都会唤醒等待。
+	//    先注册再检查状态，避免在两者之间就绪的通知丢失。
+	wake := make(chan struct{}, 1)
+	unregisters := make([]func(), 0, len(handles))
+	defer func() {
+		for _, unregister := range unregisters {
+			unregister()
+		}
+	}()
+	for _, handle := range handles {
 		if p, ok := i.pm.Get(handle); ok {
-			ch = p.Channel()
+			unregisters = append(unregisters, p.Register(wake))
 		}
-		cases[j] = reflect.SelectCase{
-			Dir:  reflect.SelectRecv,
-			Chan: reflect.ValueOf(ch),
+	}
+
+	ctx, cancel := i.b.Context(ctx)
+	defer cancel()
+	for {
+		// 3. 唤醒后返回所有已就绪的 pollable，而不只是第一个
+		for j, handle := range handles {
+			if i.Ready(ctx, handle) {
+				readyIndexes = append(readyIndexes, uint32(j))
+			}
 		}
+		if len(readyIndexes) > 0 {
+			return readyIndexes
+		}
+		// 就绪状态可能已被其他操作重置，继续等待
+		trap(i.b.Wait(ctx, wake))
 	}
+}
 
-	chosen, _, _ := reflect.Select(cases)
-	return []uint32{uint32(chosen)}
+// trap 在阻塞被打断时中止当前调用，Host 函数中的 panic 会使 Guest 陷入 trap。
+func trap(err error) {
+	if err != nil {
+		panic(err)
+	}
 }
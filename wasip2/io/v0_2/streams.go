@@ -13,10 +13,11 @@ type streamsImpl struct {
 	sm *manager_io.StreamManager
 	em *manager_io.ErrorManager
 	pm *manager_io.PollManager
+	b  *manager_io.Blocker
 }
 
-func newStreamsImpl(sm *manager_io.StreamManager, em *manager_io.ErrorManager, pm *manager_io.PollManager) *streamsImpl {
-	return &streamsImpl{sm: sm, em: em, pm: pm}
+func newStreamsImpl(sm *manager_io.StreamManager, em *manager_io.ErrorManager, pm *manager_io.PollManager, b *manager_io.Blocker) *streamsImpl {
+	return &streamsImpl{sm: sm, em: em, pm: pm, b: b}
 }
 
 // subscribeToStream 是 SubscribeToInputStream 和 SubscribeToOutputStream 的通用实现。
@@ -80,7 +81,9 @@ func (i *streamsImpl) BlockingRead(ctx context.Context, this InputStream, maxLen
 
 	if s.OnSubscribe != nil {
 		if pollable := s.OnSubscribe(); pollable != nil {
-			pollable.Block()
+			ctx, cancel := i.b.Context(ctx)
+			defer cancel()
+			trap(i.b.Wait(ctx, pollable.Channel()))
 		}
 	}
 
@@ -116,7 +119,7 @@ func (i *streamsImpl) Skip(ctx context.Context, this InputStream, maxLen uint64)
 	}
 
 	// 回退到读取和丢弃方法，并指定为非阻塞模式。
-	return i.skipByReading(s, maxLen, false) // blocking = false
+	return i.skipByReading(ctx, s, maxLen, false) // blocking = false
 }
 
 // BlockingSkip (阻塞) 会跳过 maxLen 字节，并在必要时等待数据。
@@ -138,11 +141,13 @@ func (i *streamsImpl) BlockingSkip(ctx context.Context, this InputStream, maxLen
 	}
 
 	// 回退到读取和丢弃方法，并指定为阻塞模式。
-	return i.skipByReading(s, maxLen, true) // blocking = true
+	ctx, cancel := i.b.Context(ctx)
+	defer cancel()
+	return i.skipByReading(ctx, s, maxLen, true) // blocking = true
 }
 
 // skipByReading 是跳过字节的核心实现，支持阻塞和非阻塞两种模式。
-func (i *streamsImpl) skipByReading(s *manager_io.Stream, maxLen uint64, blocking bool) witgo.Result[uint64, StreamError] {
+func (i *streamsImpl) skipByReading(ctx context.Context, s *manager_io.Stream, maxLen uint64, blocking bool) witgo.Result[uint64, StreamError] {
 	var totalSkipped uint64
 	buf := make([]byte, 32*1024)
 
@@ -171,11 +176,11 @@ func (i *streamsImpl) skipByReading(s *manager_io.Stream, maxLen uint64, blockin
 				// 阻塞模式：等待更多数据
 				if s.OnSubscribe != nil {
 					if pollable := s.OnSubscribe(); pollable != nil {
-						pollable.Block()
+						trap(i.b.Wait(ctx, pollable.Channel()))
 						continue // 继续循环以尝试再次读取
 					}
 				}
-				time.Sleep(time.Millisecond * 20)
+				trap(i.b.Sleep(ctx, time.Millisecond*20))
 			} else {
 				// 非阻塞模式：立即停止
 				break
@@ -220,6 +225,9 @@ func (i *streamsImpl) BlockingWriteAndFlush(ctx context.Context, this OutputStre
 		return witgo.Err[witgo.Unit, StreamError](StreamError{Closed: &witgo.Unit{}})
 	}
 
+	ctx, cancel := i.b.Context(ctx)
+	defer cancel()
+
 	writeSize := uint64(4096)
 	for len(contents) > 0 {
 		if s.CheckWriter != nil {
@@ -229,11 +237,12 @@ func (i *streamsImpl) BlockingWriteAndFlush(ctx context.Context, this OutputStre
 		if writeSize == 0 {
 			if s.OnSubscribe != nil {
 				if pollable := s.OnSubscribe(); pollable != nil {
-					pollable.Block()
+					trap(i.b.Wait(ctx, pollable.Channel()))
 					continue
 				}
 			}
-			time.Sleep(time.Millisecond * 20)
+			trap(i.b.Sleep(ctx, time.Millisecond*20))
+			continue
 		}
 
 		n, err := s.Writer.Write(contents[:min(writeSize, uint64(len(contents)))])
@@ -246,14 +255,16 @@ func (i *streamsImpl) BlockingWriteAndFlush(ctx context.Context, this OutputStre
 	return i.BlockingFlush(ctx, this)
 }
 
-func (i *streamsImpl) Flush(_ context.Context, this OutputStream) witgo.Result[witgo.Unit, StreamError] {
+func (i *streamsImpl) Flush(ctx context.Context, this OutputStream) witgo.Result[witgo.Unit, StreamError] {
 	s, ok := i.sm.Get(this)
 	if !ok || s.Writer == nil {
 		return witgo.Err[witgo.Unit, StreamError](StreamError{Closed: &witgo.Unit{}})
 	}
 
 	if s.Flusher != nil {
-		if err := s.Flusher.Flush(); err != nil {
+		ctx, cancel := i.b.Context(ctx)
+		defer cancel()
+		if err := i.flush(ctx, s.Flusher); err != nil {
 			errHandle := i.em.Add(err)
 			return witgo.Err[witgo.Unit, StreamError](StreamError{LastOperationFailed: &errHandle})
 		}
@@ -266,6 +277,18 @@ func (i *streamsImpl) BlockingFlush(ctx context.Context, this OutputStream) witg
 	return i.Flush(ctx, this)
 }
 
+// flush 在后台执行可能长时间阻塞的 Flush，等待被打断时使调用陷入 trap，Flush 会在后台继续执行直到结束。
+func (i *streamsImpl) flush(ctx context.Context, f manager_io.Flusher) error {
+	var err error
+	done := make(chan struct{})
+	go func() {
+		err = f.Flush()
+		close(done)
+	}()
+	trap(i.b.Wait(ctx, done))
+	return err
+}
+
 func (i *streamsImpl) SubscribeToOutputStream(_ context.Context, this OutputStream) Pollable {
 	return i.subscribeToStream(this)
 }
@@ -278,6 +301,9 @@ func (i *streamsImpl) Splice(ctx context.Context, this OutputStream, src InputSt
 		return witgo.Err[uint64, StreamError](StreamError{Closed: &witgo.Unit{}})
 	}
 
+	ctx, cancel := i.b.Context(ctx)
+	defer cancel()
+
 	// 设置源读取器。如果 src 为 0，我们使用一个特殊的 zeroReader。
 	var srcReader io.Reader
 	if src == 0 {
@@ -289,7 +315,7 @@ func (i *streamsImpl) Splice(ctx context.Context, this OutputStream, src InputSt
 		if !ok || srcStream.Reader == nil {
 			return witgo.Err[uint64, StreamError](StreamError{Closed: &witgo.Unit{}})
 		}
-		srcReader = srcStream.Reader
+		srcReader = &blockingReader{ctx: ctx, i: i, s: srcStream}
 	}
 
 	var totalWritten uint64
@@ -306,12 +332,12 @@ func (i *streamsImpl) Splice(ctx context.Context, this OutputStream, src InputSt
 			// 这个逻辑与阻塞读取的逻辑相似。我们等待流再次变为可写状态。
 			if dst.OnSubscribe != nil {
 				if pollable := dst.OnSubscribe(); pollable != nil {
-					pollable.Block()
+					trap(i.b.Wait(ctx, pollable.Channel()))
 					continue // 重启循环以再次检查可用空间。
 				}
 			}
 			// 如果没有订阅机制，则使用固定的 sleep 作为备用方案。
-			time.Sleep(20 * time.Millisecond)
+			trap(i.b.Sleep(ctx, 20*time.Millisecond))
 			continue
 		}
 
@@ -359,6 +385,29 @@ func (i *streamsImpl) BlockingWriteZeroesAndFlush(ctx context.Context, this Outp
 	return i.BlockingFlush(ctx, this)
 }
 
+// blockingReader 在源流暂时没有数据时等待它就绪，避免 io.CopyN 空转。
+type blockingReader struct {
+	ctx context.Context
+	i   *streamsImpl
+	s   *manager_io.Stream
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.s.Reader.Read(p)
+		if n > 0 || err != nil || len(p) == 0 {
+			return n, err
+		}
+		if r.s.OnSubscribe != nil {
+			if pollable := r.s.OnSubscribe(); pollable != nil {
+				trap(r.i.b.Wait(r.ctx, pollable.Channel()))
+				continue
+			}
+		}
+		trap(r.i.b.Sleep(r.ctx, 20*time.Millisecond))
+	}
+}
+
 type zeroReader struct{}
 
 func (z zeroReader) Read(p []byte) (n int, err error) {
@@ -44,7 +44,7 @@ func (i *wasiPoll) Versions() []string {
 }
 
 func (i *wasiPoll) Instantiate(_ context.Context, h *wasip2.Host, builder wazero.HostModuleBuilder) error {
-	handler := newPollImpl(h.PollManager())
+	handler := newPollImpl(h.PollManager(), h.Blocker())
 	exporter := witgo.NewExporter(builder)
 	exporter.Export("[resource-drop]pollable", handler.DropPollable)
 	exporter.Export("[method]pollable.ready", handler.Ready)
@@ -67,7 +67,7 @@ func (i *wasiStreams) Versions() []string {
 }
 
 func (i *wasiStreams) Instantiate(_ context.Context, h *wasip2.Host, builder wazero.HostModuleBuilder) error {
-	handler := newStreamsImpl(h.StreamManager(), h.ErrorManager(), h.PollManager())
+	handler := newStreamsImpl(h.StreamManager(), h.ErrorManager(), h.PollManager(), h.Blocker())
 	exporter := witgo.NewExporter(builder)
 
 	// 导出资源析构函数
@@ -1,19 +1,44 @@
 package wasi_sockets
 
 import (
+	"github.com/OpenListTeam/wazero-wasip2/manager/sockets"
 	"github.com/OpenListTeam/wazero-wasip2/wasip2"
 	v0_2 "github.com/OpenListTeam/wazero-wasip2/wasip2/sockets/v0_2"
 )
 
+// Option 用于配置 wasi:sockets 模块。
+type Option func(*v0_2.Config)
+
+// WithNetworkPolicy 限制 Guest 可以进行的网络访问，例如只允许连接指定的网段和端口，或禁止 UDP 和监听。
+// 被拒绝的操作会以 access-denied 返回给 Guest。
+func WithNetworkPolicy(policy sockets.NetworkPolicy) Option {
+	return func(c *v0_2.Config) {
+		c.Policy = policy
+	}
+}
+
+// WithProxy 让 Guest 的 TCP 连接经过 SOCKS5 或 HTTP CONNECT 代理建立，代理返回的错误会映射为
+// connection-refused、access-denied 等错误码。使用 sockets.NewProxy 创建代理并设置不经过代理的目标地址。
+func WithProxy(proxy *sockets.Proxy) Option {
+	return func(c *v0_2.Config) {
+		c.Proxy = proxy
+	}
+}
+
 // Module 返回一个配置好的 wasi:sockets 模块选项。
-func Module(version string) wasip2.ModuleOption {
+func Module(version string, opts ...Option) wasip2.ModuleOption {
 	return func(h *wasip2.Host) {
+		cfg := &v0_2.Config{}
+		for _, opt := range opts {
+			opt(cfg)
+		}
+
 		var networkImpl, instanceNetworkImpl, tcpImpl, tcpCreateSocketImpl, udpImpl, udpCreateSocketImpl, ipNameLookupImpl wasip2.Implementation
 
 		switch version {
 		case "0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7":
-			networkImpl = v0_2.NewNetwork()
-			instanceNetworkImpl = v0_2.NewInstanceNetwork()
+			networkImpl = v0_2.NewNetwork(cfg)
+			instanceNetworkImpl = v0_2.NewInstanceNetwork(cfg)
 			tcpImpl = v0_2.NewTCP()
 			tcpCreateSocketImpl = v0_2.NewTCPCreateSocket()
 			udpImpl = v0_2.NewUDP()
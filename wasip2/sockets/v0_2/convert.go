@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io/fs"
 	"net"
+	"net/netip"
 	"syscall"
 )
 
@@ -49,6 +50,23 @@ func fromIPSocketAddressToTCPAddr(addr IPSocketAddress) (*net.TCPAddr, error) {
 	return nil, errors.New("invalid ip-socket-address")
 }
 
+// fromIPSocketAddressToAddrPort 将 WIT 的 IPSocketAddress 转换为 netip.AddrPort。
+func fromIPSocketAddressToAddrPort(addr IPSocketAddress) (netip.AddrPort, error) {
+	tcpAddr, err := fromIPSocketAddressToTCPAddr(addr)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return tcpAddr.AddrPort(), nil
+}
+
+// matchFamily 判断地址是否属于套接字的地址族。IPv6 套接字总是 v6-only，不接受 IPv4 映射地址。
+func matchFamily(family IPAddressFamily, addr netip.Addr) bool {
+	if family == IPAddressFamilyIPV4 {
+		return addr.Is4()
+	}
+	return addr.Is6() && !addr.Is4In6()
+}
+
 // fromIPSocketAddressToUDPAddr 将 WIT 的 IPSocketAddress 转换为 Go 的 *net.UDPAddr。
 func fromIPSocketAddressToUDPAddr(addr IPSocketAddress) (*net.UDPAddr, error) {
 	if addr.IPV4 != nil {
@@ -67,6 +85,9 @@ func fromIPSocketAddressToUDPAddr(addr IPSocketAddress) (*net.UDPAddr, error) {
 
 // mapDnsError 将 Go 的 net.DNSError 映射到 wasi:sockets 的 ErrorCode。
 func mapDnsError(err error) ErrorCode {
+	if errors.Is(err, syscall.EACCES) {
+		return ErrorCodeAccessDenied
+	}
 	var dnsErr *net.DNSError
 	if errors.As(err, &dnsErr) {
 		if dnsErr.IsTemporary {
@@ -82,6 +103,14 @@ func mapDnsError(err error) ErrorCode {
 	return ErrorCodeNameUnresolvable
 }
 
+// syscallConn 返回连接底层的系统套接字，虚拟网络中的连接没有系统套接字，返回 EOPNOTSUPP。
+func syscallConn(conn any) (syscall.RawConn, error) {
+	if c, ok := conn.(syscall.Conn); ok {
+		return c.SyscallConn()
+	}
+	return nil, syscall.EOPNOTSUPP
+}
+
 // mapOsError 将 Go 的 os/syscall 网络错误映射到 wasi:sockets 的 ErrorCode。
 func mapOsError(err error) ErrorCode {
 	if err == nil {
@@ -126,7 +155,7 @@ func mapOsError(err error) ErrorCode {
 			return ErrorCodeInvalidArgument
 		case syscall.EISCONN:
 			return ErrorCodeInvalidState
-		case syscall.ENETUNREACH:
+		case syscall.ENETUNREACH, syscall.EHOSTUNREACH:
 			return ErrorCodeRemoteUnreachable
 		case syscall.ENFILE, syscall.EMFILE:
 			return ErrorCodeNewSocketLimit
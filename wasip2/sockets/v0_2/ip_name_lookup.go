@@ -3,6 +3,7 @@ package v0_2
 import (
 	"context"
 	"net"
+	"syscall"
 
 	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
 	"github.com/OpenListTeam/wazero-wasip2/manager/sockets"
@@ -20,6 +21,15 @@ func newIPNameLookupImpl(h *wasip2.Host) *ipNameLookupImpl {
 }
 
 func (i *ipNameLookupImpl) ResolveAddresses(ctx context.Context, network Network, name string) witgo.Result[ResolveAddressStream, ErrorCode] {
+	policy, code := networkPolicy(i.host, network)
+	if code != nil {
+		return witgo.Err[ResolveAddressStream, ErrorCode](*code)
+	}
+	// IP 地址不需要查询 DNS，总是允许
+	if net.ParseIP(name) == nil && !policy.AllowNameLookup() {
+		return witgo.Err[ResolveAddressStream, ErrorCode](ErrorCodeAccessDenied)
+	}
+
 	// 创建一个用于管理此解析操作状态的资源
 	state := &sockets.ResolveAddressStreamState{
 		Done: make(chan struct{}),
@@ -34,20 +44,26 @@ func (i *ipNameLookupImpl) ResolveAddresses(ctx context.Context, network Network
 		return witgo.Ok[ResolveAddressStream, ErrorCode](handle)
 	}
 
-	// 如果不是 IP 地址，则在后台 goroutine 中执行 DNS 查询
+	// 如果不是 IP 地址，则在后台 goroutine 中使用 Host 配置的解析器查询，资源被丢弃时取消查询
+	lookupCtx, cancel := context.WithCancel(context.Background())
+	state.Cancel = cancel
+	resolver := i.host.Resolver()
 	go func() {
 		defer close(state.Done)
-		// 使用带 context 的 Resolver 以支持取消
-		resolver := net.Resolver{}
-		addrs, err := resolver.LookupIPAddr(context.Background(), name)
+		defer cancel()
+		addrs, err := resolver.LookupNetIP(lookupCtx, "ip", name)
 		if err != nil {
 			state.Error = err
 			return
 		}
-		// 将 net.IPAddr 转换为 net.IP
-		state.Addresses = make([]net.IP, len(addrs))
-		for i, ipAddr := range addrs {
-			state.Addresses[i] = ipAddr.IP
+		// 丢弃策略禁止的地址，全部被禁止时拒绝访问
+		for _, addr := range addrs {
+			if addr = addr.Unmap(); policy.AllowResolved(addr) {
+				state.Addresses = append(state.Addresses, net.IP(addr.AsSlice()))
+			}
+		}
+		if len(state.Addresses) == 0 && len(addrs) > 0 {
+			state.Error = syscall.EACCES
 		}
 	}()
 
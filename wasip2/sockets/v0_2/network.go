@@ -7,12 +7,21 @@ import (
 	"github.com/OpenListTeam/wazero-wasip2/wasip2"
 )
 
+// Config 保存 wasi:sockets 模块的 Host 端配置。
+type Config struct {
+	// Policy 限制 Guest 可以进行的网络访问，随 instance-network 返回的 network 资源生效。
+	Policy sockets.NetworkPolicy
+	// Proxy 不为 nil 时 Guest 的 TCP 连接经过它建立，使用虚拟网络时不生效。
+	Proxy *sockets.Proxy
+}
+
 type networkImpl struct {
 	host *wasip2.Host
+	cfg  *Config
 }
 
-func newNetworkImpl(h *wasip2.Host) *networkImpl {
-	return &networkImpl{host: h}
+func newNetworkImpl(h *wasip2.Host, cfg *Config) *networkImpl {
+	return &networkImpl{host: h, cfg: cfg}
 }
 
 // DropNetwork 是 network 资源的析构函数。
@@ -21,11 +30,45 @@ func (i *networkImpl) DropNetwork(_ context.Context, handle Network) {
 }
 
 // InstanceNetwork 返回一个代表默认网络访问能力的句柄。
+// 返回的 network 资源携带 Host 配置的访问策略。
 func (i *networkImpl) InstanceNetwork(_ context.Context) Network {
-	// TODO
-	// 在一个真实的实现中，这里可能会有复杂的逻辑来确定
-	// “默认网络”是什么，以及 Guest 是否有权限访问它。
-	// 在我们的实现中，我们简单地创建一个新的 Network 资源。
-	net := &sockets.Network{}
+	net := &sockets.Network{Policy: &i.cfg.Policy, Proxy: i.cfg.Proxy}
 	return i.host.NetworkManager().Add(net)
 }
+
+// networkPolicy 返回 network 资源携带的访问策略，句柄无效时返回 invalid-argument。
+func networkPolicy(h *wasip2.Host, network Network) (*sockets.NetworkPolicy, *ErrorCode) {
+	n, ok := h.NetworkManager().Get(network)
+	if !ok {
+		code := ErrorCodeInvalidArgument
+		return nil, &code
+	}
+	return n.Policy, nil
+}
+
+// networkProxy 返回 network 资源使用的代理，句柄需要已经通过 networkPolicy 检查。
+func networkProxy(h *wasip2.Host, network Network) *sockets.Proxy {
+	if n, ok := h.NetworkManager().Get(network); ok {
+		return n.Proxy
+	}
+	return nil
+}
+
+// bindPolicy 检查是否允许通过 network 绑定到 localAddress，返回套接字之后使用的策略。
+// localAddress 不属于套接字的地址族时返回 invalid-argument。
+func bindPolicy(h *wasip2.Host, network Network, family IPAddressFamily, localAddress IPSocketAddress, udp bool) (*sockets.NetworkPolicy, *ErrorCode) {
+	policy, code := networkPolicy(h, network)
+	if code != nil {
+		return nil, code
+	}
+	addr, err := fromIPSocketAddressToAddrPort(localAddress)
+	if err != nil || !matchFamily(family, addr.Addr()) {
+		code := ErrorCodeInvalidArgument
+		return nil, &code
+	}
+	if (udp && !policy.AllowUDP()) || !policy.AllowBind(addr) {
+		code := ErrorCodeAccessDenied
+		return nil, &code
+	}
+	return policy, nil
+}
@@ -3,6 +3,7 @@ package v0_2
 import (
 	"context"
 	"net"
+	"syscall"
 
 	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
 	"github.com/OpenListTeam/wazero-wasip2/manager/sockets"
@@ -30,19 +31,57 @@ func (i *tcpImpl) StartConnect(ctx context.Context, this TCPSocket, network Netw
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
 
-	addr, err := fromIPSocketAddressToTCPAddr(remoteAddress)
+	addr, err := fromIPSocketAddressToAddrPort(remoteAddress)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
 	}
+	// 远端地址必须属于套接字的地址族，并且不能是未指定的地址或端口 0
+	if !matchFamily(sock.Family, addr.Addr()) || addr.Addr().IsUnspecified() || addr.Port() == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+
+	policy, code := networkPolicy(i.host, network)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if !policy.AllowConnect(addr) {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeAccessDenied)
+	}
+
+	var dial func(context.Context) (sockets.TCPConn, error)
+	if vn := i.host.VirtualNetwork(); vn != nil {
+		dial = startVirtualConnect(vn, sock, addr)
+	} else if proxy := networkProxy(i.host, network); proxy != nil && !proxy.Bypassed(addr) {
+		// 经过代理的连接无法使用 Guest 绑定的本地地址
+		if sock.State == sockets.TCPStateBound {
+			return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeNotSupported)
+		}
+		// 经过代理的连接由 Host 建立，Guest 创建的系统套接字不再使用
+		sock.CloseFd()
+		dial = func(ctx context.Context) (sockets.TCPConn, error) {
+			return proxy.DialContext(ctx, addr)
+		}
+	} else {
+		dial, err = startConnect(sock, remoteAddress)
+		if err != nil {
+			return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+		}
+	}
+	sock.Policy = policy
 
 	sock.State = sockets.TCPStateConnecting
-	sock.ConnectResult = make(chan sockets.ConnectResult, 1) // 创建带缓冲的 channel
+	results := make(chan sockets.ConnectResult, 1) // 创建带缓冲的 channel
+	dialCtx, cancel := context.WithCancel(context.Background())
+	sock.ConnectResult, sock.CancelConnect = results, cancel
 
-	// 在后台 goroutine 中执行阻塞的 Dial 操作
+	// 在后台 goroutine 中等待连接完成，套接字被丢弃时取消
 	go func() {
-		// net.DialTCP 会处理隐式绑定（如果套接字未绑定）
-		conn, dialErr := net.DialTCP("tcp", nil, addr)
-		sock.ConnectResult <- sockets.ConnectResult{Conn: conn, Err: dialErr}
+		conn, dialErr := dial(dialCtx)
+		if dialErr != nil {
+			results <- sockets.ConnectResult{Err: dialErr}
+			return
+		}
+		results <- sockets.ConnectResult{Conn: conn}
 	}()
 
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
@@ -61,6 +100,7 @@ func (i *tcpImpl) FinishConnect(ctx context.Context, this TCPSocket) witgo.Resul
 	// 非阻塞地检查连接结果
 	select {
 	case result := <-sock.ConnectResult:
+		sock.FinishConnect()
 		if result.Err != nil {
 			sock.State = sockets.TCPStateClosed
 			return witgo.Err[witgo.Tuple[wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode](mapOsError(result.Err))
@@ -71,9 +111,9 @@ func (i *tcpImpl) FinishConnect(ctx context.Context, this TCPSocket) witgo.Resul
 		sock.State = sockets.TCPStateConnected
 
 		// 为连接创建输入输出流
-		inStream := manager_io.NewAsyncStreamForReader(sock.Conn, manager_io.DontCloseReader())
+		inStream := manager_io.NewAsyncStreamForReader(sock.Conn, manager_io.DontCloseReader(), manager_io.ReaderRead(&sock.BytesRead), manager_io.WithMaxReadBufferSize(i.host.MaxReadBufferSize()))
 		inStreamHandle := i.host.StreamManager().Add(inStream)
-		outStream := manager_io.NewAsyncStreamForWriter(sock.Conn, manager_io.DontCloseWriter())
+		outStream := manager_io.NewAsyncStreamForWriter(sock.Conn, manager_io.DontCloseWriter(), manager_io.WriterWritten(&sock.BytesWritten))
 		outStreamHandle := i.host.StreamManager().Add(outStream)
 
 		return witgo.Ok[witgo.Tuple[wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode](witgo.Tuple[wasip2_io.InputStream, wasip2_io.OutputStream]{
@@ -92,15 +132,51 @@ func (i *tcpImpl) StartListen(ctx context.Context, this TCPSocket) witgo.Result[
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
 	}
-	if sock.State != sockets.TCPStateBound {
+	if sock.State != sockets.TCPStateBound || sock.Listener == nil {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
+	if !sock.Policy.AllowListen() {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeAccessDenied)
+	}
 
-	// 在 Go 中，Listen 已经在 StartBind 中完成了。这里我们只改变状态。
+	if err := startListen(sock); err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+	}
+	// 开始在后台接受连接
+	sock.Acceptor = sockets.NewAsyncAcceptor(sock.Listener)
 	sock.State = sockets.TCPStateListening
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
 }
 
+// startListen 让已绑定的套接字开始监听，已经在监听时更新队列长度。
+func startListen(sock *sockets.TCPSocket) error {
+	if listener, ok := sock.Listener.(*sockets.VirtualListener); ok {
+		return listener.Listen(sock.ListenBacklog)
+	}
+	return listen(sock)
+}
+
+// dialTCP 使用 net.Dialer 在一个新的套接字上建立连接，用于不能直接在 Guest 创建的套接字上 connect 的平台。
+// control 不为 nil 时在连接之前对新的套接字调用。
+func dialTCP(ctx context.Context, local net.Addr, remote *net.TCPAddr, control func(fd uintptr) error) (sockets.TCPConn, error) {
+	// keep-alive 默认关闭，由 Guest 自己设置
+	dialer := net.Dialer{LocalAddr: local, KeepAlive: -1}
+	if control != nil {
+		dialer.Control = func(_, _ string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) { err = control(fd) }); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", remote.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.TCPConn), nil
+}
+
 func (i *tcpImpl) FinishListen(ctx context.Context, this TCPSocket) witgo.Result[witgo.Unit, ErrorCode] {
 	sock, ok := i.host.TCPSocketManager().Get(this)
 	if !ok {
@@ -117,32 +193,33 @@ func (i *tcpImpl) Accept(ctx context.Context, this TCPSocket) witgo.Result[witgo
 	if !ok {
 		return witgo.Err[witgo.Tuple3[TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode](ErrorCodeInvalidArgument)
 	}
-	if sock.State != sockets.TCPStateListening || sock.Listener == nil {
+	if sock.State != sockets.TCPStateListening || sock.Acceptor == nil {
 		return witgo.Err[witgo.Tuple3[TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode](ErrorCodeInvalidState)
 	}
 
-	conn, err := sock.Listener.AcceptTCP()
+	conn, err := sock.Acceptor.Accept()
 	if err != nil {
-		// 如果是超时或临时错误，映射到 would-block
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return witgo.Err[witgo.Tuple3[TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode](ErrorCodeWouldBlock)
-		}
 		return witgo.Err[witgo.Tuple3[TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode](mapOsError(err))
 	}
+	if conn == nil {
+		// 队列中没有等待的连接
+		return witgo.Err[witgo.Tuple3[TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode](ErrorCodeWouldBlock)
+	}
 
 	// 为新的连接创建一个新的 TCPSocket 资源
 	newSock := &sockets.TCPSocket{
 		Conn:   conn,
 		Family: sock.Family,
 		State:  sockets.TCPStateConnected,
+		Policy: sock.Policy,
 	}
 	newSockHandle := i.host.TCPSocketManager().Add(newSock)
 
 	// 为新的连接创建输入输出流
 	// conn 同时实现了 io.Reader 和 io.Writer
-	inStream := manager_io.NewAsyncStreamForReader(conn, manager_io.DontCloseReader())
+	inStream := manager_io.NewAsyncStreamForReader(conn, manager_io.DontCloseReader(), manager_io.ReaderRead(&newSock.BytesRead), manager_io.WithMaxReadBufferSize(i.host.MaxReadBufferSize()))
 	inStreamHandle := i.host.StreamManager().Add(inStream)
-	outStream := manager_io.NewAsyncStreamForWriter(conn, manager_io.DontCloseWriter())
+	outStream := manager_io.NewAsyncStreamForWriter(conn, manager_io.DontCloseWriter(), manager_io.WriterWritten(&newSock.BytesWritten))
 	outStreamHandle := i.host.StreamManager().Add(outStream)
 
 	result := witgo.Tuple3[TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream]{
@@ -240,55 +317,6 @@ func (i *tcpImpl) IsListening(ctx context.Context, this TCPSocket) bool {
 	return sock.State == sockets.TCPStateListening
 }
 
-// SetKeepAliveEnabled 启用或禁用 keep-alive。
-func (i *tcpImpl) SetKeepAliveEnabled(ctx context.Context, this TCPSocket, value bool) witgo.Result[witgo.Unit, ErrorCode] {
-	sock, ok := i.host.TCPSocketManager().Get(this)
-	if !ok {
-		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
-	}
-	if sock.Conn == nil {
-		// 必须在连接建立后才能设置
-		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
-	}
-	err := sock.Conn.SetKeepAlive(value)
-	if err != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
-	}
-	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
-}
-
-// SetReceiveBufferSize 设置接收缓冲区大小。
-func (i *tcpImpl) SetReceiveBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
-	sock, ok := i.host.TCPSocketManager().Get(this)
-	if !ok {
-		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
-	}
-	if sock.Conn == nil {
-		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
-	}
-	err := sock.Conn.SetReadBuffer(int(value))
-	if err != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
-	}
-	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
-}
-
-// SetSendBufferSize 设置发送缓冲区大小。
-func (i *tcpImpl) SetSendBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
-	sock, ok := i.host.TCPSocketManager().Get(this)
-	if !ok {
-		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
-	}
-	if sock.Conn == nil {
-		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
-	}
-	err := sock.Conn.SetWriteBuffer(int(value))
-	if err != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
-	}
-	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
-}
-
 // Subscribe 创建一个 pollable 用于异步操作。
 func (i *tcpImpl) Subscribe(pctx context.Context, this TCPSocket) wasip2_io.Pollable {
 	sock, ok := i.host.TCPSocketManager().Get(this)
@@ -309,15 +337,17 @@ func (i *tcpImpl) Subscribe(pctx context.Context, this TCPSocket) wasip2_io.Poll
 		handle := i.host.PollManager().Add(p)
 
 		// This goroutine waits for the connection result to appear on the channel.
+		// The channel is captured here because finish-connect and drop reset the field.
+		results := sock.ConnectResult
 		go func() {
 			select {
 			// Wait for the result from the connection goroutine.
-			case res := <-sock.ConnectResult:
+			case res := <-results:
 				// The connection attempt is complete. Signal the pollable.
 				p.SetReady()
 				// Put the result back onto the buffered channel so `finish-connect` can consume it.
 				// This is a delicate operation, relying on the channel being buffered and having a single final consumer.
-				sock.ConnectResult <- res
+				results <- res
 			case <-ctx.Done():
 				// If the context is cancelled, also unblock the poll.
 				p.SetReady()
@@ -325,29 +355,15 @@ func (i *tcpImpl) Subscribe(pctx context.Context, this TCPSocket) wasip2_io.Poll
 		}()
 		return handle
 
-	// case sockets.TCPStateListening, sockets.TCPStateConnected:
-	// 	// For listening or connected sockets, we can poll on the underlying file descriptor if it exists.
-	// 	if sock.Fd != 0 {
-	// 		// For listening, poll for readability (new connection). For connected, poll for writability.
-	// 		direction := manager_io.PollDirectionWrite
-	// 		if sock.State == sockets.TCPStateListening {
-	// 			direction = manager_io.PollDirectionRead
-	// 		}
-	// 		// NOTE: Assuming NewPollaleFd exists and works as intended for OS-specific polling.
-	// 		p := manager_io.NewPollaleFd(sock.Fd, direction)
-	// 		handle := i.host.PollManager().Add(p)
-	// 		return handle
-	// 	}
-	// 	// Fallthrough if Fd is not available
+	case sockets.TCPStateListening:
+		// 监听中的套接字在有等待 accept 的连接时就绪。
+		if sock.Acceptor != nil {
+			return i.host.PollManager().Add(sock.Acceptor.Subscribe())
+		}
 
 	default:
-		// For other states (e.g., unbound, closed), the operation should fail quickly.
-		// A ready pollable allows the guest to proceed to the next step (e.g., finish-connect)
-		// where it will receive the appropriate error for the socket's state.
-		// p := manager_io.NewPollable(nil)
-		// handle := i.host.PollManager().Add(p)
-		// p.SetReady()
-		// return handle
+		// 已连接的套接字没有进行中的异步操作，读写就绪由各自的 stream 提供。
+		// 其他状态（未绑定、已关闭等）同样立即就绪，让 Guest 在下一步调用中得到对应的错误。
 	}
 
 	// Fallback for states with no specific polling mechanism (e.g. no Fd).
@@ -16,6 +16,9 @@ func (i *tcpCreateSocketImpl) CreateTCPSocket(_ context.Context, addressFamily I
 	if err != nil {
 		return witgo.Err[TCPSocket, ErrorCode](ErrorCodeNotSupported)
 	}
+	if i.host.VirtualNetwork() != nil {
+		return i.createVirtualSocket(family)
+	}
 
 	domain := unix.AF_INET
 	if family == IPAddressFamilyIPV6 {
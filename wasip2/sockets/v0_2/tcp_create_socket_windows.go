@@ -21,6 +21,9 @@ func (i *tcpCreateSocketImpl) CreateTCPSocket(_ context.Context, addressFamily I
 	if err != nil {
 		return witgo.Err[TCPSocket, ErrorCode](ErrorCodeNotSupported)
 	}
+	if i.host.VirtualNetwork() != nil {
+		return i.createVirtualSocket(family)
+	}
 
 	domain := windows.AF_INET
 	if family == sockets.IPAddressFamilyIPV6 {
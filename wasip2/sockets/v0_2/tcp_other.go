@@ -11,16 +11,7 @@ import (
 )
 
 func (i *tcpImpl) DropTCPSocket(_ context.Context, handle TCPSocket) {
-	sock, ok := i.host.TCPSocketManager().Get(handle)
-	if !ok {
-		return
-	}
-	if sock.Conn != nil {
-		sock.Conn.Close()
-	}
-	if sock.Listener != nil {
-		sock.Listener.Close()
-	}
+	// 关闭逻辑由 TCPSocketManager 的析构函数负责。
 	i.host.TCPSocketManager().Remove(handle)
 }
 
@@ -32,6 +23,13 @@ func (i *tcpImpl) StartBind(_ context.Context, this TCPSocket, network Network,
 	if sock.State != sockets.TCPStateUnbound {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
+	policy, code := bindPolicy(i.host, network, sock.Family, localAddress, false)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if vn := i.host.VirtualNetwork(); vn != nil {
+		return i.bindVirtual(vn, sock, policy, localAddress)
+	}
 
 	addr, err := fromIPSocketAddressToTCPAddr(localAddress)
 	if err != nil {
@@ -50,6 +48,7 @@ func (i *tcpImpl) StartBind(_ context.Context, this TCPSocket, network Network,
 
 	// 绑定成功，更新套接字状态
 	sock.Listener = listener
+	sock.Policy = policy
 	sock.State = sockets.TCPStateBound
 
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
@@ -71,6 +70,91 @@ func (i *tcpImpl) SetListenBacklogSize(ctx context.Context, this TCPSocket, valu
 	return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeNotSupported)
 }
 
+// listen 让已绑定的套接字开始监听，net.ListenTCP 在绑定时已经开始监听。
+func listen(sock *sockets.TCPSocket) error {
+	return nil
+}
+
+// startConnect 返回建立连接的函数。这个平台上没有 Guest 创建的系统套接字，使用 net.Dialer 连接。
+func startConnect(sock *sockets.TCPSocket, remote IPSocketAddress) (func(context.Context) (sockets.TCPConn, error), error) {
+	addr, err := fromIPSocketAddressToTCPAddr(remote)
+	if err != nil {
+		return nil, err
+	}
+	// 已绑定的套接字从绑定的地址发起连接
+	var local net.Addr
+	if sock.Listener != nil {
+		local = sock.Listener.Addr()
+		sock.Listener.Close()
+		sock.Listener = nil
+	}
+	return func(ctx context.Context) (sockets.TCPConn, error) {
+		return dialTCP(ctx, local, addr, nil)
+	}, nil
+}
+
+// SetKeepAliveEnabled 启用或禁用 keep-alive。
+func (i *tcpImpl) SetKeepAliveEnabled(ctx context.Context, this TCPSocket, value bool) witgo.Result[witgo.Unit, ErrorCode] {
+	conn, code := i.tcpConn(this)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if conn == nil {
+		// 虚拟网络中的连接没有这个选项
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
+	if err := conn.SetKeepAlive(value); err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+	}
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
+
+// SetReceiveBufferSize 设置接收缓冲区大小。
+func (i *tcpImpl) SetReceiveBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
+	conn, code := i.tcpConn(this)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if conn == nil {
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
+	if err := conn.SetReadBuffer(int(value)); err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+	}
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
+
+// SetSendBufferSize 设置发送缓冲区大小。
+func (i *tcpImpl) SetSendBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
+	conn, code := i.tcpConn(this)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if conn == nil {
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
+	if err := conn.SetWriteBuffer(int(value)); err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+	}
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
+
+// tcpConn 返回已连接套接字的 *net.TCPConn，虚拟网络中的连接返回 nil。
+// 这个平台上只能在连接建立之后设置选项。
+func (i *tcpImpl) tcpConn(this TCPSocket) (*net.TCPConn, *ErrorCode) {
+	sock, ok := i.host.TCPSocketManager().Get(this)
+	if !ok {
+		code := ErrorCodeInvalidArgument
+		return nil, &code
+	}
+	if sock.Conn == nil {
+		code := ErrorCodeInvalidState
+		return nil, &code
+	}
+	conn, _ := sock.Conn.(*net.TCPConn)
+	return conn, nil
+}
+
 func (i *tcpImpl) KeepAliveEnabled(ctx context.Context, this TCPSocket) witgo.Result[bool, ErrorCode] {
 	return witgo.Err[bool, ErrorCode](ErrorCodeNotSupported)
 }
@@ -0,0 +1,286 @@
+package v0_2
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/sockets"
+	"github.com/OpenListTeam/wazero-wasip2/wasip2"
+	wasip2_io "github.com/OpenListTeam/wazero-wasip2/wasip2/io/v0_2"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testSockets 直接调用 wasi:sockets 的 Host 实现，模拟 Guest 的调用。
+type testSockets struct {
+	t       *testing.T
+	h       *wasip2.Host
+	create  *tcpCreateSocketImpl
+	tcp     *tcpImpl
+	network Network
+}
+
+func newTestSockets(t *testing.T, cfg *Config, opts ...wasip2.ModuleOption) *testSockets {
+	h := wasip2.NewHost(opts...)
+	return &testSockets{
+		t:       t,
+		h:       h,
+		create:  newTCPCreateSocketImpl(h),
+		tcp:     newTCPImpl(h),
+		network: newNetworkImpl(h, cfg).InstanceNetwork(context.Background()),
+	}
+}
+
+// socketAddress 将 Go 的地址转换为 ip-socket-address。
+func (s *testSockets) socketAddress(addr net.Addr) IPSocketAddress {
+	a, err := toIPSocketAddress(addr)
+	require.NoError(s.t, err)
+	return a
+}
+
+// connect 创建套接字并连接到 remote，返回套接字和它的输入流、输出流。
+func (s *testSockets) connect(family IPAddressFamily, remote net.Addr) (TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream) {
+	ctx := context.Background()
+	sock := s.create.CreateTCPSocket(ctx, family)
+	require.NotNil(s.t, sock.Ok)
+	require.Nil(s.t, s.tcp.StartConnect(ctx, *sock.Ok, s.network, s.socketAddress(remote)).Err)
+	return s.finishConnect(*sock.Ok)
+}
+
+func (s *testSockets) finishConnect(sock TCPSocket) (TCPSocket, wasip2_io.InputStream, wasip2_io.OutputStream) {
+	var streams witgo.Result[witgo.Tuple[wasip2_io.InputStream, wasip2_io.OutputStream], ErrorCode]
+	require.Eventually(s.t, func() bool {
+		streams = s.tcp.FinishConnect(context.Background(), sock)
+		return streams.Err == nil || *streams.Err != ErrorCodeWouldBlock
+	}, 5*time.Second, time.Millisecond)
+	require.Nil(s.t, streams.Err)
+	return sock, streams.Ok.F0, streams.Ok.F1
+}
+
+func TestTCPMaxReadBufferSize(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := newTestSockets(t, &Config{}, wasip2.WithMaxReadBufferSize(16))
+	_, input, _ := s.connect(IPAddressFamilyIPV4, listener.Addr())
+	server, err := listener.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+	_, err = server.Write(make([]byte, 64))
+	require.NoError(t, err)
+
+	// 后台读取最多缓冲 16 字节，Guest 每次最多读到 16 字节
+	stream, ok := s.h.StreamManager().Get(input)
+	require.True(t, ok)
+	buf := make([]byte, 64)
+	total := 0
+	require.Eventually(t, func() bool {
+		n, err := stream.Reader.Read(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 16)
+		total += n
+		return total == 64
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestTCPConnectOptions(t *testing.T) {
+	ctx := context.Background()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := newTestSockets(t, &Config{})
+	sock := s.create.CreateTCPSocket(ctx, IPAddressFamilyIPV4)
+	require.NotNil(t, sock.Ok)
+	this := *sock.Ok
+	require.Nil(t, s.tcp.StartBind(ctx, this, s.network, s.socketAddress(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})).Err)
+	require.Nil(t, s.tcp.FinishBind(ctx, this).Err)
+	bound := s.tcp.LocalAddress(ctx, this)
+	require.NotNil(t, bound.Ok)
+	require.NotZero(t, bound.Ok.IPV4.Port)
+
+	// 连接之前设置的选项在连接后保留
+	const bufferSize = 64 << 10
+	require.Nil(t, s.tcp.SetKeepAliveEnabled(ctx, this, true).Err)
+	require.Nil(t, s.tcp.SetReceiveBufferSize(ctx, this, bufferSize).Err)
+	require.Nil(t, s.tcp.SetSendBufferSize(ctx, this, bufferSize).Err)
+
+	require.Nil(t, s.tcp.StartConnect(ctx, this, s.network, s.socketAddress(listener.Addr())).Err)
+	s.finishConnect(this)
+	server, err := listener.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	// 连接使用绑定的地址
+	local := s.tcp.LocalAddress(ctx, this)
+	require.NotNil(t, local.Ok)
+	require.Equal(t, *bound.Ok, *local.Ok)
+	require.Equal(t, s.socketAddress(server.RemoteAddr()), *local.Ok)
+
+	keepAlive := s.tcp.KeepAliveEnabled(ctx, this)
+	require.NotNil(t, keepAlive.Ok)
+	require.True(t, *keepAlive.Ok)
+	// 系统可能会调整缓冲区大小（Linux 会翻倍），但不会小于设置的值
+	receive := s.tcp.ReceiveBufferSize(ctx, this)
+	require.NotNil(t, receive.Ok)
+	require.GreaterOrEqual(t, *receive.Ok, uint64(bufferSize))
+	send := s.tcp.SendBufferSize(ctx, this)
+	require.NotNil(t, send.Ok)
+	require.GreaterOrEqual(t, *send.Ok, uint64(bufferSize))
+}
+
+func TestTCPRejectsMappedAddress(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSockets(t, &Config{})
+	sock := s.create.CreateTCPSocket(ctx, IPAddressFamilyIPV6)
+	if sock.Err != nil {
+		t.Skip("IPv6 is not available")
+	}
+
+	// IPv6 套接字是 v6-only 的，不接受 IPv4 映射地址
+	mapped := IPSocketAddress{IPV6: &IPv6SocketAddress{
+		Port:    8080,
+		Address: IPv6Address{0, 0, 0, 0, 0, 0xffff, 0x7f00, 0x0001},
+	}}
+	code := s.tcp.StartBind(ctx, *sock.Ok, s.network, mapped).Err
+	require.NotNil(t, code)
+	require.Equal(t, ErrorCodeInvalidArgument, *code)
+	code = s.tcp.StartConnect(ctx, *sock.Ok, s.network, mapped).Err
+	require.NotNil(t, code)
+	require.Equal(t, ErrorCodeInvalidArgument, *code)
+}
+
+func TestDropConnectingSocket(t *testing.T) {
+	ctx := context.Background()
+	// 代理接受连接后不响应 CONNECT，连接一直停留在进行中
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	proxy, err := sockets.NewProxy("http://" + listener.Addr().String())
+	require.NoError(t, err)
+
+	s := newTestSockets(t, &Config{Proxy: proxy})
+	sock := s.create.CreateTCPSocket(ctx, IPAddressFamilyIPV4)
+	require.NotNil(t, sock.Ok)
+	remote := s.socketAddress(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 80})
+	require.Nil(t, s.tcp.StartConnect(ctx, *sock.Ok, s.network, remote).Err)
+
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not receive the connection")
+	}
+	defer conn.Close()
+
+	// 丢弃套接字后连接被取消，代理一侧读到连接关闭
+	s.tcp.DropTCPSocket(ctx, *sock.Ok)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.Copy(io.Discard, conn)
+	require.NoError(t, err)
+}
+
+func TestTCPProxyRejectsBoundSocket(t *testing.T) {
+	ctx := context.Background()
+	proxy, err := sockets.NewProxy("socks5://127.0.0.1:1")
+	require.NoError(t, err)
+	s := newTestSockets(t, &Config{Proxy: proxy})
+
+	sock := s.create.CreateTCPSocket(ctx, IPAddressFamilyIPV4)
+	require.NotNil(t, sock.Ok)
+	this := *sock.Ok
+	require.Nil(t, s.tcp.StartBind(ctx, this, s.network, s.socketAddress(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})).Err)
+	require.Nil(t, s.tcp.FinishBind(ctx, this).Err)
+	bound := s.tcp.LocalAddress(ctx, this)
+	require.NotNil(t, bound.Ok)
+
+	// 代理无法使用绑定的地址，连接被拒绝而不是悄悄换成另一个本地地址
+	remote := s.socketAddress(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 80})
+	result := s.tcp.StartConnect(ctx, this, s.network, remote)
+	require.NotNil(t, result.Err)
+	require.Equal(t, ErrorCodeNotSupported, *result.Err)
+
+	// 套接字仍然保持绑定的状态
+	local := s.tcp.LocalAddress(ctx, this)
+	require.NotNil(t, local.Ok)
+	require.Equal(t, *bound.Ok, *local.Ok)
+}
+
+// blockingResolver 的查询一直阻塞到被取消。
+type blockingResolver struct {
+	started, cancelled chan struct{}
+}
+
+func (r *blockingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	close(r.started)
+	<-ctx.Done()
+	close(r.cancelled)
+	return nil, ctx.Err()
+}
+
+func TestDropResolveAddressStream(t *testing.T) {
+	ctx := context.Background()
+	resolver := &blockingResolver{started: make(chan struct{}), cancelled: make(chan struct{})}
+	s := newTestSockets(t, &Config{}, wasip2.WithResolver(resolver))
+	lookup := newIPNameLookupImpl(s.h)
+
+	stream := lookup.ResolveAddresses(ctx, s.network, "example.com")
+	require.NotNil(t, stream.Ok)
+	<-resolver.started
+
+	// 丢弃 resolve-address-stream 后查询被取消
+	lookup.DropResolveAddressStream(ctx, *stream.Ok)
+	select {
+	case <-resolver.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup was not cancelled after the stream was dropped")
+	}
+}
+
+func TestTCPByteCounters(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := newTestSockets(t, &Config{})
+	this, input, output := s.connect(IPAddressFamilyIPV4, listener.Addr())
+	server, err := listener.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	out, ok := s.h.StreamManager().Get(output)
+	require.True(t, ok)
+	_, err = out.Writer.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, out.Flusher.Flush())
+	_, err = server.Write([]byte("world!"))
+	require.NoError(t, err)
+
+	// 输入流和输出流收发的字节数记录在套接字上
+	in, ok := s.h.StreamManager().Get(input)
+	require.True(t, ok)
+	buf := make([]byte, 16)
+	total := 0
+	require.Eventually(t, func() bool {
+		n, err := in.Reader.Read(buf)
+		require.NoError(t, err)
+		total += n
+		return total == 6
+	}, 5*time.Second, time.Millisecond)
+	sock, ok := s.h.TCPSocketManager().Get(this)
+	require.True(t, ok)
+	require.Equal(t, uint64(6), sock.BytesRead.Load())
+	require.Equal(t, uint64(5), sock.BytesWritten.Load())
+}
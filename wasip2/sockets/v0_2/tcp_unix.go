@@ -4,9 +4,11 @@ package v0_2
 
 import (
 	"context"
+	"math"
 	"net"
 	"os"
 	"syscall"
+	"time"
 
 	"github.com/OpenListTeam/wazero-wasip2/manager/sockets"
 	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
@@ -15,19 +17,7 @@ import (
 )
 
 func (i *tcpImpl) DropTCPSocket(_ context.Context, handle TCPSocket) {
-	sock, ok := i.host.TCPSocketManager().Get(handle)
-	if !ok {
-		return
-	}
-	if sock.Fd != 0 {
-		unix.Close(sock.Fd)
-	}
-	if sock.Conn != nil {
-		sock.Conn.Close()
-	}
-	if sock.Listener != nil {
-		sock.Listener.Close()
-	}
+	// 关闭逻辑由 TCPSocketManager 的析构函数负责。
 	i.host.TCPSocketManager().Remove(handle)
 }
 
@@ -39,6 +29,13 @@ func (i *tcpImpl) StartBind(_ context.Context, this TCPSocket, network Network,
 	if sock.State != sockets.TCPStateUnbound {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
+	policy, code := bindPolicy(i.host, network, sock.Family, localAddress, false)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if vn := i.host.VirtualNetwork(); vn != nil {
+		return i.bindVirtual(vn, sock, policy, localAddress)
+	}
 
 	sockaddr, err := fromIPSocketAddressToSockaddr(localAddress)
 	if err != nil {
@@ -51,13 +48,20 @@ func (i *tcpImpl) StartBind(_ context.Context, this TCPSocket, network Network,
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(bindErr))
 	}
 
-	file := os.NewFile(uintptr(sock.Fd), "")
+	// FileListener 会复制 fd，这里交给它一个副本，sock.Fd 仍由套接字自己管理
+	dupFd, dupErr := unix.Dup(sock.Fd)
+	if dupErr != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(dupErr))
+	}
+	file := os.NewFile(uintptr(dupFd), "")
 	listener, connErr := net.FileListener(file)
+	file.Close()
 	if connErr != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(connErr))
 	}
 
 	sock.Listener = listener.(*net.TCPListener)
+	sock.Policy = policy
 	sock.State = sockets.TCPStateBound
 
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
@@ -83,19 +87,140 @@ func (i *tcpImpl) SetListenBacklogSize(ctx context.Context, this TCPSocket, valu
 	if sock.Listener == nil {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
 
-	// This is not directly supported by Go's net.Listener, so we use syscall.
-	file, err := sock.Listener.File()
-	if err != nil {
+	sock.ListenBacklog = int(min(value, math.MaxInt32))
+	if sock.State != sockets.TCPStateListening {
+		// 在 start-listen 时生效
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
+	// 已经在监听时再次调用 listen 更新队列长度
+	if err := startListen(sock); err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
-	defer file.Close()
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
+
+// listen 让已绑定的套接字开始监听。
+func listen(sock *sockets.TCPSocket) error {
+	backlog := sock.ListenBacklog
+	if backlog <= 0 {
+		backlog = unix.SOMAXCONN
+	}
+	return unix.Listen(sock.Fd, backlog)
+}
 
-	err = unix.Listen(int(file.Fd()), int(value))
+// startConnect 在 Guest 创建的套接字上发起非阻塞的 connect(2)，返回的函数等待连接完成。
+// 绑定的地址和连接之前设置的选项都保留在这个套接字上。fd 交给返回的函数管理，无论连接成功与否都会被关闭。
+func startConnect(sock *sockets.TCPSocket, remote IPSocketAddress) (func(context.Context) (sockets.TCPConn, error), error) {
+	sockaddr, err := fromIPSocketAddressToSockaddr(remote)
 	if err != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+		return nil, err
 	}
-	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+
+	connErr := syscall.Connect(sock.Fd, sockaddr)
+	// os.NewFile 把非阻塞的 fd 注册到 netpoller，之后由 file 负责关闭它
+	file := os.NewFile(uintptr(sock.Fd), "")
+	sock.Fd = 0
+	// 绑定时创建的监听器使用的是 fd 的副本
+	if sock.Listener != nil {
+		sock.Listener.Close()
+		sock.Listener = nil
+	}
+
+	return func(ctx context.Context) (sockets.TCPConn, error) {
+		defer file.Close()
+		switch connErr {
+		case nil:
+		case syscall.EINPROGRESS, syscall.EINTR:
+			if err := waitConnect(ctx, file); err != nil {
+				return nil, err
+			}
+		default:
+			// 连接的错误在 finish-connect 中报告
+			return nil, os.NewSyscallError("connect", connErr)
+		}
+		conn, err := net.FileConn(file)
+		if err != nil {
+			return nil, err
+		}
+		return conn.(*net.TCPConn), nil
+	}, nil
+}
+
+// waitConnect 通过 netpoller 等待非阻塞的 connect 完成，ctx 被取消时放弃等待。
+func waitConnect(ctx context.Context, file *os.File) error {
+	rawConn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		file.SetWriteDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	var connErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		// 套接字可写时连接已经结束，SO_ERROR 给出结果
+		errno, err := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_ERROR)
+		if err != nil {
+			connErr = err
+			return true
+		}
+		switch e := syscall.Errno(errno); e {
+		case 0:
+		case syscall.EINPROGRESS, syscall.EALREADY, syscall.EINTR:
+			return false
+		default:
+			connErr = e
+			return true
+		}
+		// 没有错误时也可能还在连接中，能取得对端地址才算连接成功
+		if _, err := unix.Getpeername(int(fd)); err != nil {
+			if err == unix.ENOTCONN {
+				return false
+			}
+			connErr = err
+		}
+		return true
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	if connErr != nil {
+		return os.NewSyscallError("connect", connErr)
+	}
+	return nil
+}
+
+// SetKeepAliveEnabled 启用或禁用 keep-alive，连接之前设置的值在连接后保留。
+func (i *tcpImpl) SetKeepAliveEnabled(ctx context.Context, this TCPSocket, value bool) witgo.Result[witgo.Unit, ErrorCode] {
+	enabled := 0
+	if value {
+		enabled = 1
+	}
+	return setsockoptInt(i, this, unix.SOL_SOCKET, unix.SO_KEEPALIVE, enabled)
+}
+
+// SetReceiveBufferSize 设置接收缓冲区大小。
+func (i *tcpImpl) SetReceiveBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+	return setsockoptInt(i, this, unix.SOL_SOCKET, unix.SO_RCVBUF, int(min(value, math.MaxInt32)))
+}
+
+// SetSendBufferSize 设置发送缓冲区大小。
+func (i *tcpImpl) SetSendBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+	return setsockoptInt(i, this, unix.SOL_SOCKET, unix.SO_SNDBUF, int(min(value, math.MaxInt32)))
 }
 
 func (i *tcpImpl) KeepAliveEnabled(ctx context.Context, this TCPSocket) witgo.Result[bool, ErrorCode] {
@@ -123,11 +248,24 @@ func (i *tcpImpl) SetKeepAliveCount(ctx context.Context, this TCPSocket, value u
 }
 
 func (i *tcpImpl) HopLimit(ctx context.Context, this TCPSocket) witgo.Result[uint8, ErrorCode] {
-	return getsockoptInt[uint8](i, this, unix.IPPROTO_IP, unix.IP_TTL)
+	level, opt := i.hopLimitOption(this)
+	return getsockoptInt[uint8](i, this, level, opt)
 }
 
 func (i *tcpImpl) SetHopLimit(ctx context.Context, this TCPSocket, value uint8) witgo.Result[witgo.Unit, ErrorCode] {
-	return setsockoptInt(i, this, unix.IPPROTO_IP, unix.IP_TTL, int(value))
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+	level, opt := i.hopLimitOption(this)
+	return setsockoptInt(i, this, level, opt, int(value))
+}
+
+// hopLimitOption 返回套接字地址族对应的 hop limit 选项。
+func (i *tcpImpl) hopLimitOption(this TCPSocket) (level, opt int) {
+	if sock, ok := i.host.TCPSocketManager().Get(this); ok && sock.Family == sockets.IPAddressFamilyIPV6 {
+		return unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS
+	}
+	return unix.IPPROTO_IP, unix.IP_TTL
 }
 
 func (i *tcpImpl) ReceiveBufferSize(ctx context.Context, this TCPSocket) witgo.Result[uint64, ErrorCode] {
@@ -140,53 +278,57 @@ func (i *tcpImpl) SendBufferSize(ctx context.Context, this TCPSocket) witgo.Resu
 
 func getsockoptInt[T ~int | ~uint64 | ~uint32 | ~uint8](i *tcpImpl, this TCPSocket, level, opt int) witgo.Result[T, ErrorCode] {
 	sock, ok := i.host.TCPSocketManager().Get(this)
-	if !ok || sock.Conn == nil {
+	if !ok {
 		return witgo.Err[T, ErrorCode](ErrorCodeInvalidArgument)
 	}
 
-	rawConn, err := sock.Conn.SyscallConn()
-	if err != nil {
-		return witgo.Err[T, ErrorCode](mapOsError(err))
-	}
-
 	var val int
-	var getErr error
-	err = rawConn.Control(func(fd uintptr) {
-		val, getErr = unix.GetsockoptInt(int(fd), level, opt)
+	err := controlFd(sock, func(fd int) (err error) {
+		val, err = unix.GetsockoptInt(fd, level, opt)
+		return err
 	})
-
 	if err != nil {
 		return witgo.Err[T, ErrorCode](mapOsError(err))
 	}
-	if getErr != nil {
-		return witgo.Err[T, ErrorCode](mapOsError(getErr))
-	}
-
 	return witgo.Ok[T, ErrorCode](T(val))
 }
 
 func setsockoptInt(i *tcpImpl, this TCPSocket, level, opt, value int) witgo.Result[witgo.Unit, ErrorCode] {
 	sock, ok := i.host.TCPSocketManager().Get(this)
-	if !ok || sock.Conn == nil {
+	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
 	}
+	if i.host.VirtualNetwork() != nil {
+		// 虚拟网络中的套接字没有这些选项
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
 
-	rawConn, err := sock.Conn.SyscallConn()
+	err := controlFd(sock, func(fd int) error {
+		return unix.SetsockoptInt(fd, level, opt, value)
+	})
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
 
-	var setErr error
-	err = rawConn.Control(func(fd uintptr) {
-		setErr = unix.SetsockoptInt(int(fd), level, opt, value)
-	})
+// controlFd 在套接字的 fd 上调用 f：连接建立之前使用 Guest 创建的 fd，之后使用连接的 fd。
+// 正在连接或已关闭的套接字返回 ENOTCONN。
+func controlFd(sock *sockets.TCPSocket, f func(fd int) error) error {
+	if sock.Conn == nil {
+		if sock.Fd == 0 {
+			return syscall.ENOTCONN
+		}
+		return f(sock.Fd)
+	}
 
+	rawConn, err := syscallConn(sock.Conn)
 	if err != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+		return err
 	}
-	if setErr != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(setErr))
+	var ferr error
+	if err := rawConn.Control(func(fd uintptr) { ferr = f(int(fd)) }); err != nil {
+		return err
 	}
-
-	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	return ferr
 }
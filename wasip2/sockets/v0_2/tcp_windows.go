@@ -4,6 +4,7 @@ package v0_2
 
 import (
 	"context"
+	"math"
 	"net"
 	"os"
 	"syscall"
@@ -15,19 +16,7 @@ import (
 )
 
 func (i *tcpImpl) DropTCPSocket(_ context.Context, handle TCPSocket) {
-	sock, ok := i.host.TCPSocketManager().Get(handle)
-	if !ok {
-		return
-	}
-	if sock.Fd != 0 {
-		windows.Close(windows.Handle(sock.Fd))
-	}
-	if sock.Conn != nil {
-		sock.Conn.Close()
-	}
-	if sock.Listener != nil {
-		sock.Listener.Close()
-	}
+	// 关闭逻辑由 TCPSocketManager 的析构函数负责。
 	i.host.TCPSocketManager().Remove(handle)
 }
 
@@ -39,6 +28,13 @@ func (i *tcpImpl) StartBind(_ context.Context, this TCPSocket, network Network,
 	if sock.State != sockets.TCPStateUnbound {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
+	policy, code := bindPolicy(i.host, network, sock.Family, localAddress, false)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if vn := i.host.VirtualNetwork(); vn != nil {
+		return i.bindVirtual(vn, sock, policy, localAddress)
+	}
 
 	sockaddr, err := fromIPSocketAddressToSockaddr(localAddress)
 	if err != nil {
@@ -60,6 +56,7 @@ func (i *tcpImpl) StartBind(_ context.Context, this TCPSocket, network Network,
 
 	// 绑定成功，更新套接字状态
 	sock.Listener = listener.(*net.TCPListener)
+	sock.Policy = policy
 	sock.State = sockets.TCPStateBound
 
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
@@ -85,18 +82,102 @@ func (i *tcpImpl) SetListenBacklogSize(ctx context.Context, this TCPSocket, valu
 	if sock.Listener == nil {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
 
-	file, err := sock.Listener.File()
-	if err != nil {
+	sock.ListenBacklog = int(min(value, math.MaxInt32))
+	if sock.State != sockets.TCPStateListening {
+		// 在 start-listen 时生效
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
+	// 已经在监听时再次调用 listen 更新队列长度
+	if err := startListen(sock); err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
-	defer file.Close()
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
 
-	err = windows.Listen(windows.Handle(file.Fd()), int(value))
+// listen 让已绑定的套接字开始监听。
+func listen(sock *sockets.TCPSocket) error {
+	backlog := sock.ListenBacklog
+	if backlog <= 0 {
+		backlog = windows.SOMAXCONN
+	}
+	return windows.Listen(windows.Handle(sock.Fd), backlog)
+}
+
+// startConnect 返回建立连接的函数。
+// Windows 上不能把 Guest 创建的套接字转换为 net.Conn，这里关闭它，改用 net.Dialer 在新的套接字上连接，
+// 并把绑定的地址和连接之前设置的选项重新设置到新的套接字上。
+func startConnect(sock *sockets.TCPSocket, remote IPSocketAddress) (func(context.Context) (sockets.TCPConn, error), error) {
+	addr, err := fromIPSocketAddressToTCPAddr(remote)
 	if err != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+		return nil, err
 	}
-	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+
+	var local net.Addr
+	if sock.Listener != nil {
+		local = sock.Listener.Addr()
+		sock.Listener.Close()
+		sock.Listener = nil
+	} else if sock.Fd != 0 && sock.State == sockets.TCPStateBound {
+		if sa, err := windows.Getsockname(windows.Handle(sock.Fd)); err == nil {
+			local = sockaddrToTCPAddr(sa)
+		}
+	}
+	if sock.Fd != 0 {
+		windows.Closesocket(windows.Handle(sock.Fd))
+		sock.Fd = 0
+	}
+
+	options := sock.Options
+	sock.Options = nil
+	return func(ctx context.Context) (sockets.TCPConn, error) {
+		return dialTCP(ctx, local, addr, func(fd uintptr) error {
+			for _, opt := range options {
+				if err := windows.SetsockoptInt(windows.Handle(fd), opt.Level, opt.Name, opt.Value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}, nil
+}
+
+func sockaddrToTCPAddr(sa windows.Sockaddr) net.Addr {
+	switch sa := sa.(type) {
+	case *windows.SockaddrInet4:
+		return &net.TCPAddr{IP: net.IP(sa.Addr[:]), Port: sa.Port}
+	case *windows.SockaddrInet6:
+		return &net.TCPAddr{IP: net.IP(sa.Addr[:]), Port: sa.Port}
+	}
+	return nil
+}
+
+// SetKeepAliveEnabled 启用或禁用 keep-alive，连接之前设置的值在连接后保留。
+func (i *tcpImpl) SetKeepAliveEnabled(ctx context.Context, this TCPSocket, value bool) witgo.Result[witgo.Unit, ErrorCode] {
+	enabled := 0
+	if value {
+		enabled = 1
+	}
+	return setTCPSockopt(i, this, windows.SOL_SOCKET, windows.SO_KEEPALIVE, enabled)
+}
+
+// SetReceiveBufferSize 设置接收缓冲区大小。
+func (i *tcpImpl) SetReceiveBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+	return setTCPSockopt(i, this, windows.SOL_SOCKET, windows.SO_RCVBUF, int(min(value, math.MaxInt32)))
+}
+
+// SetSendBufferSize 设置发送缓冲区大小。
+func (i *tcpImpl) SetSendBufferSize(ctx context.Context, this TCPSocket, value uint64) witgo.Result[witgo.Unit, ErrorCode] {
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+	return setTCPSockopt(i, this, windows.SOL_SOCKET, windows.SO_SNDBUF, int(min(value, math.MaxInt32)))
 }
 
 func (i *tcpImpl) KeepAliveEnabled(ctx context.Context, this TCPSocket) witgo.Result[bool, ErrorCode] {
@@ -132,11 +213,24 @@ func (i *tcpImpl) SetKeepAliveCount(ctx context.Context, this TCPSocket, value u
 }
 
 func (i *tcpImpl) HopLimit(ctx context.Context, this TCPSocket) witgo.Result[uint8, ErrorCode] {
-	return getTCPSockopt[uint8](i, this, windows.IPPROTO_IP, windows.IP_TTL)
+	level, opt := i.hopLimitOption(this)
+	return getTCPSockopt[uint8](i, this, level, opt)
 }
 
 func (i *tcpImpl) SetHopLimit(ctx context.Context, this TCPSocket, value uint8) witgo.Result[witgo.Unit, ErrorCode] {
-	return setTCPSockopt(i, this, windows.IPPROTO_IP, windows.IP_TTL, int(value))
+	if value == 0 {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+	level, opt := i.hopLimitOption(this)
+	return setTCPSockopt(i, this, level, opt, int(value))
+}
+
+// hopLimitOption 返回套接字地址族对应的 hop limit 选项。
+func (i *tcpImpl) hopLimitOption(this TCPSocket) (level, opt int) {
+	if sock, ok := i.host.TCPSocketManager().Get(this); ok && sock.Family == sockets.IPAddressFamilyIPV6 {
+		return windows.IPPROTO_IPV6, windows.IPV6_UNICAST_HOPS
+	}
+	return windows.IPPROTO_IP, windows.IP_TTL
 }
 
 func (i *tcpImpl) ReceiveBufferSize(ctx context.Context, this TCPSocket) witgo.Result[uint64, ErrorCode] {
@@ -149,53 +243,61 @@ func (i *tcpImpl) SendBufferSize(ctx context.Context, this TCPSocket) witgo.Resu
 
 func getTCPSockopt[T ~int | ~uint64 | ~uint32 | ~uint8](i *tcpImpl, this TCPSocket, level, opt int) witgo.Result[T, ErrorCode] {
 	sock, ok := i.host.TCPSocketManager().Get(this)
-	if !ok || sock.Conn == nil {
+	if !ok {
 		return witgo.Err[T, ErrorCode](ErrorCodeInvalidArgument)
 	}
 
-	rawConn, err := sock.Conn.SyscallConn()
-	if err != nil {
-		return witgo.Err[T, ErrorCode](mapOsError(err))
-	}
-
 	var val int
-	var getErr error
-	err = rawConn.Control(func(fd uintptr) {
-		val, getErr = windows.GetsockoptInt(windows.Handle(fd), level, opt)
+	err := controlFd(sock, func(fd windows.Handle) (err error) {
+		val, err = windows.GetsockoptInt(fd, level, opt)
+		return err
 	})
-
 	if err != nil {
 		return witgo.Err[T, ErrorCode](mapOsError(err))
 	}
-	if getErr != nil {
-		return witgo.Err[T, ErrorCode](mapOsError(getErr))
-	}
-
 	return witgo.Ok[T, ErrorCode](T(val))
 }
 
 func setTCPSockopt(i *tcpImpl, this TCPSocket, level, opt, value int) witgo.Result[witgo.Unit, ErrorCode] {
 	sock, ok := i.host.TCPSocketManager().Get(this)
-	if !ok || sock.Conn == nil {
+	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
 	}
+	if i.host.VirtualNetwork() != nil {
+		// 虚拟网络中的套接字没有这些选项
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
 
-	rawConn, err := sock.Conn.SyscallConn()
+	err := controlFd(sock, func(fd windows.Handle) error {
+		return windows.SetsockoptInt(fd, level, opt, value)
+	})
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
+	if sock.Conn == nil {
+		// 连接时重新设置到新的套接字上
+		sock.Options = append(sock.Options, sockets.SocketOption{Level: level, Name: opt, Value: value})
+	}
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
 
-	var setErr error
-	err = rawConn.Control(func(fd uintptr) {
-		setErr = windows.SetsockoptInt(windows.Handle(fd), level, opt, value)
-	})
+// controlFd 在套接字上调用 f：连接建立之前使用 Guest 创建的套接字，之后使用连接的套接字。
+// 正在连接或已关闭的套接字返回 ENOTCONN。
+func controlFd(sock *sockets.TCPSocket, f func(fd windows.Handle) error) error {
+	if sock.Conn == nil {
+		if sock.Fd == 0 {
+			return syscall.ENOTCONN
+		}
+		return f(windows.Handle(sock.Fd))
+	}
 
+	rawConn, err := syscallConn(sock.Conn)
 	if err != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+		return err
 	}
-	if setErr != nil {
-		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(setErr))
+	var ferr error
+	if err := rawConn.Control(func(fd uintptr) { ferr = f(windows.Handle(fd)) }); err != nil {
+		return err
 	}
-
-	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	return ferr
 }
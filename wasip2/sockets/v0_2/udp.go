@@ -2,6 +2,7 @@ package v0_2
 
 import (
 	"context"
+	"net"
 
 	manager_io "github.com/OpenListTeam/wazero-wasip2/manager/io"
 	manager_sockets "github.com/OpenListTeam/wazero-wasip2/manager/sockets"
@@ -27,6 +28,15 @@ func (i *udpImpl) Stream(_ context.Context, this UDPSocket, remoteAddress witgo.
 	if !ok || sock.Conn == nil {
 		return witgo.Err[witgo.Tuple[IncomingDatagramStream, OutgoingDatagramStream], ErrorCode](ErrorCodeInvalidState)
 	}
+	if remoteAddress.Some != nil {
+		addr, err := fromIPSocketAddressToAddrPort(*remoteAddress.Some)
+		if err != nil {
+			return witgo.Err[witgo.Tuple[IncomingDatagramStream, OutgoingDatagramStream], ErrorCode](ErrorCodeInvalidArgument)
+		}
+		if !sock.Policy.AllowConnect(addr) {
+			return witgo.Err[witgo.Tuple[IncomingDatagramStream, OutgoingDatagramStream], ErrorCode](ErrorCodeAccessDenied)
+		}
+	}
 
 	if sock.Reader != nil {
 		sock.Reader.Close()
@@ -87,7 +97,12 @@ func (i *udpImpl) SetReceiveBufferSize(ctx context.Context, this TCPSocket, valu
 	if sock.Conn == nil {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
-	err := sock.Conn.SetReadBuffer(int(value))
+	conn, ok := sock.Conn.(*net.TCPConn)
+	if !ok {
+		// 虚拟网络中的连接没有这个选项
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
+	err := conn.SetReadBuffer(int(value))
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -103,7 +118,12 @@ func (i *udpImpl) SetSendBufferSize(ctx context.Context, this TCPSocket, value u
 	if sock.Conn == nil {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
 	}
-	err := sock.Conn.SetWriteBuffer(int(value))
+	conn, ok := sock.Conn.(*net.TCPConn)
+	if !ok {
+		// 虚拟网络中的连接没有这个选项
+		return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+	}
+	err := conn.SetWriteBuffer(int(value))
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
@@ -157,6 +177,18 @@ func (i *udpImpl) Send(_ context.Context, this OutgoingDatagramStream, datagrams
 	if !ok || sock.Writer == nil {
 		return witgo.Err[uint64, ErrorCode](ErrorCodeInvalidArgument)
 	}
+	for _, datagram := range datagrams {
+		if datagram.RemoteAddress.Some == nil {
+			continue
+		}
+		addr, err := fromIPSocketAddressToAddrPort(*datagram.RemoteAddress.Some)
+		if err != nil {
+			return witgo.Err[uint64, ErrorCode](ErrorCodeInvalidArgument)
+		}
+		if !sock.Policy.AllowConnect(addr) {
+			return witgo.Err[uint64, ErrorCode](ErrorCodeAccessDenied)
+		}
+	}
 
 	sentCount, err := sock.Writer.Send(datagrams)
 	if err != nil {
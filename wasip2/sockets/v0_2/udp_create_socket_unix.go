@@ -17,6 +17,9 @@ func (i *udpCreateSocketImpl) CreateUDPSocket(_ context.Context, addressFamily I
 	if err != nil {
 		return witgo.Err[UDPSocket, ErrorCode](ErrorCodeNotSupported)
 	}
+	if i.host.VirtualNetwork() != nil {
+		return i.createVirtualSocket(family)
+	}
 
 	domain := syscall.AF_INET
 	if family == sockets.IPAddressFamilyIPV6 {
@@ -53,14 +56,6 @@ func (i *udpCreateSocketImpl) CreateUDPSocket(_ context.Context, addressFamily I
 }
 
 func (i *udpImpl) DropUDPSocket(_ context.Context, handle UDPSocket) {
-	sock, ok := i.host.UDPSocketManager().Get(handle)
-	if !ok {
-		return
-	}
-	if sock.Conn != nil {
-		sock.Conn.Close()
-	} else if sock.Fd != 0 {
-		unix.Close(sock.Fd)
-	}
+	// 关闭逻辑由 UDPSocketManager 的析构函数负责。
 	i.host.UDPSocketManager().Remove(handle)
 }
@@ -15,6 +15,13 @@ func (i *udpImpl) StartBind(_ context.Context, this UDPSocket, network Network,
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
 	}
+	policy, code := bindPolicy(i.host, network, sock.Family, localAddress, true)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if vn := i.host.VirtualNetwork(); vn != nil {
+		return i.bindVirtual(vn, sock, policy, localAddress)
+	}
 
 	addr, err := fromIPSocketAddressToUDPAddr(localAddress)
 	if err != nil {
@@ -33,6 +40,7 @@ func (i *udpImpl) StartBind(_ context.Context, this UDPSocket, network Network,
 
 	// 绑定成功，更新套接字状态
 	sock.Conn = conn
+	sock.Policy = policy
 
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
 }
@@ -18,6 +18,13 @@ func (i *udpImpl) StartBind(_ context.Context, this UDPSocket, network Network,
 	if !ok {
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
 	}
+	policy, code := bindPolicy(i.host, network, sock.Family, localAddress, true)
+	if code != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](*code)
+	}
+	if vn := i.host.VirtualNetwork(); vn != nil {
+		return i.bindVirtual(vn, sock, policy, localAddress)
+	}
 
 	// 将 WIT 地址转换为 syscall.Sockaddr
 	sockaddr, err := fromIPSocketAddressToSockaddr(localAddress)
@@ -38,6 +45,7 @@ func (i *udpImpl) StartBind(_ context.Context, this UDPSocket, network Network,
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(connErr))
 	}
 	sock.Conn = conn.(*net.UDPConn)
+	sock.Policy = policy
 
 	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
 }
@@ -69,7 +77,7 @@ func getUDPSockoptInt[T ~int | ~uint64 | ~uint32 | ~uint8](i *udpImpl, this UDPS
 		return witgo.Err[T, ErrorCode](ErrorCodeInvalidArgument)
 	}
 
-	rawConn, err := sock.Conn.SyscallConn()
+	rawConn, err := syscallConn(sock.Conn)
 	if err != nil {
 		return witgo.Err[T, ErrorCode](mapOsError(err))
 	}
@@ -96,7 +104,7 @@ func setUDPSockoptInt(i *udpImpl, this UDPSocket, level, opt, value int) witgo.R
 		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
 	}
 
-	rawConn, err := sock.Conn.SyscallConn()
+	rawConn, err := syscallConn(sock.Conn)
 	if err != nil {
 		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
 	}
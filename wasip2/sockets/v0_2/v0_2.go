@@ -10,34 +10,44 @@ import (
 )
 
 // --- wasi:sockets/network ---
-type wasiNetwork struct{}
+type wasiNetwork struct {
+	cfg *Config
+}
 
-func NewNetwork() wasip2.Implementation {
-	return &wasiNetwork{}
+func NewNetwork(cfg *Config) wasip2.Implementation {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	return &wasiNetwork{cfg: cfg}
 }
 func (i *wasiNetwork) Name() string { return "wasi:sockets/network" }
 func (i *wasiNetwork) Versions() []string {
 	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
 }
 func (i *wasiNetwork) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
-	handler := newNetworkImpl(h)
+	handler := newNetworkImpl(h, i.cfg)
 	exporter := witgo.NewExporter(b)
 	exporter.Export("[resource-drop]network", handler.DropNetwork)
 	return nil
 }
 
 // --- wasi:sockets/instance-network ---
-type wasiInstanceNetwork struct{}
+type wasiInstanceNetwork struct {
+	cfg *Config
+}
 
-func NewInstanceNetwork() wasip2.Implementation {
-	return &wasiInstanceNetwork{}
+func NewInstanceNetwork(cfg *Config) wasip2.Implementation {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	return &wasiInstanceNetwork{cfg: cfg}
 }
 func (i *wasiInstanceNetwork) Name() string { return "wasi:sockets/instance-network" }
 func (i *wasiInstanceNetwork) Versions() []string {
 	return []string{"0.2.0", "0.2.1", "0.2.2", "0.2.3", "0.2.4", "0.2.5", "0.2.6", "0.2.7"}
 }
 func (i *wasiInstanceNetwork) Instantiate(_ context.Context, h *wasip2.Host, b wazero.HostModuleBuilder) error {
-	handler := newNetworkImpl(h)
+	handler := newNetworkImpl(h, i.cfg)
 	exporter := witgo.NewExporter(b)
 	exporter.Export("instance-network", handler.InstanceNetwork)
 	return nil
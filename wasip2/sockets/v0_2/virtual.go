@@ -0,0 +1,77 @@
+package v0_2
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/OpenListTeam/wazero-wasip2/manager/sockets"
+	witgo "github.com/OpenListTeam/wazero-wasip2/wit-go"
+)
+
+// 使用虚拟网络时，套接字在创建时不打开系统套接字，绑定和连接都在 sockets.VirtualNetwork 中完成。
+
+func (i *tcpCreateSocketImpl) createVirtualSocket(family sockets.IPAddressFamily) witgo.Result[TCPSocket, ErrorCode] {
+	tcpSocket := &sockets.TCPSocket{
+		Family: family,
+		State:  sockets.TCPStateUnbound,
+	}
+	return witgo.Ok[TCPSocket, ErrorCode](i.host.TCPSocketManager().Add(tcpSocket))
+}
+
+func (i *udpCreateSocketImpl) createVirtualSocket(family sockets.IPAddressFamily) witgo.Result[UDPSocket, ErrorCode] {
+	udpSocket := &sockets.UDPSocket{
+		Family: family,
+	}
+	return witgo.Ok[UDPSocket, ErrorCode](i.host.UDPSocketManager().Add(udpSocket))
+}
+
+// startVirtualConnect 返回在虚拟网络中建立连接的函数，已绑定的套接字从绑定的地址发起连接。
+func startVirtualConnect(vn *sockets.VirtualNetwork, sock *sockets.TCPSocket, remote netip.AddrPort) func(context.Context) (sockets.TCPConn, error) {
+	var local netip.AddrPort
+	if listener, ok := sock.Listener.(*sockets.VirtualListener); ok {
+		local = listener.AddrPort()
+	}
+	return func(ctx context.Context) (sockets.TCPConn, error) {
+		conn, err := vn.DialTCP(ctx, local, remote)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// bindVirtual 在虚拟网络中占用 localAddress，监听器在 start-listen 之前拒绝所有连接。
+func (i *tcpImpl) bindVirtual(vn *sockets.VirtualNetwork, sock *sockets.TCPSocket, policy *sockets.NetworkPolicy, localAddress IPSocketAddress) witgo.Result[witgo.Unit, ErrorCode] {
+	addr, err := fromIPSocketAddressToAddrPort(localAddress)
+	if err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+
+	listener, err := vn.BindTCP(addr)
+	if err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+	}
+	sock.Listener = listener
+	sock.Policy = policy
+	sock.State = sockets.TCPStateBound
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
+
+// bindVirtual 在虚拟网络中绑定 UDP 套接字。
+func (i *udpImpl) bindVirtual(vn *sockets.VirtualNetwork, sock *sockets.UDPSocket, policy *sockets.NetworkPolicy, localAddress IPSocketAddress) witgo.Result[witgo.Unit, ErrorCode] {
+	if sock.Conn != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidState)
+	}
+	addr, err := fromIPSocketAddressToAddrPort(localAddress)
+	if err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](ErrorCodeInvalidArgument)
+	}
+
+	conn, err := vn.BindUDP(addr)
+	if err != nil {
+		return witgo.Err[witgo.Unit, ErrorCode](mapOsError(err))
+	}
+	sock.Conn = conn
+	sock.Policy = policy
+	return witgo.Ok[witgo.Unit, ErrorCode](witgo.Unit{})
+}
@@ -91,15 +91,18 @@ func (i *tlsTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazero
 			panic("invalid client-handshake handle")
 		}
 
+		// future 被丢弃时取消握手，HandshakeContext 会关闭底层的流。
+		ctx, cancel := context.WithCancel(context.Background())
 		future := &manager_tls.FutureClientStreams{
 			Pollable: manager_io.NewPollable(nil),
+			Cancel:   cancel,
 		}
 
 		futureHandle := tm.FutureClientStreams.Add(future)
 
 		// 在后台 goroutine 中启动 TLS 握手。
 		go func() {
-			defer future.Pollable.SetReady()
+			defer cancel()
 
 			// 使用 streamConn 包装器来满足 net.Conn 接口。
 			underlyingConn := &streamConn{
@@ -113,12 +116,14 @@ func (i *tlsTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazero
 				ServerName: handshake.ServerName,
 			})
 
-			if err := tlsConn.Handshake(); err != nil {
-				future.Result = manager_tls.Result{Err: err}
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				// 握手失败时底层的流不再有用
+				tlsConn.Close()
+				future.SetResult(manager_tls.Result{Err: err})
 				return
 			}
 
-			future.Result = manager_tls.Result{TlsConn: tlsConn}
+			future.SetResult(manager_tls.Result{TlsConn: tlsConn})
 		}()
 
 		return futureHandle
@@ -171,7 +176,7 @@ func (i *tlsTypes) Instantiate(_ context.Context, h *wasip2.Host, builder wazero
 
 		tlsConn := future.Result.TlsConn
 		// 为加密连接创建新的异步流。
-		inStreamEncrypted := manager_io.NewAsyncStreamForReader(tlsConn)
+		inStreamEncrypted := manager_io.NewAsyncStreamForReader(tlsConn, manager_io.WithMaxReadBufferSize(h.MaxReadBufferSize()))
 		outStreamEncrypted := manager_io.NewAsyncStreamForWriter(tlsConn)
 
 		inStreamHandle := sm.Add(inStreamEncrypted)
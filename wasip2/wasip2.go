@@ -2,7 +2,11 @@ package wasip2
 
 import (
 	"context"
+	"net"
+	"sync"
+	"time"
 
+	"github.com/OpenListTeam/wazero-wasip2/manager/cli"
 	"github.com/OpenListTeam/wazero-wasip2/manager/filesystem"
 	"github.com/OpenListTeam/wazero-wasip2/manager/http"
 	"github.com/OpenListTeam/wazero-wasip2/manager/io"
@@ -10,6 +14,7 @@ import (
 	"github.com/OpenListTeam/wazero-wasip2/manager/tls"
 
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
 )
 
 // Implementation 是所有 WASI 模块必须实现的接口。
@@ -27,6 +32,7 @@ type Host struct {
 	streamManager *io.StreamManager
 	errorManager  *io.ErrorManager
 	pollManager   *io.PollManager
+	blocker       *io.Blocker
 	httpManager   *http.HTTPManager
 	tlsManager    *tls.TLSManager
 
@@ -39,9 +45,35 @@ type Host struct {
 	tcpSocketManager            *sockets.TCPSocketManager
 	udpSocketManager            *sockets.UDPSocketManager
 	resolveAddressStreamManager *sockets.ResolveAddressStreamManager
+	// virtualNetwork 不为 nil 时 wasi:sockets 使用它代替系统网络
+	virtualNetwork *sockets.VirtualNetwork
+	// resolver 为 nil 时使用系统解析器
+	resolver sockets.Resolver
+	// maxReadBufferSize 是套接字、TLS 和 HTTP body 输入流的读取高水位，零值表示使用默认值
+	maxReadBufferSize int
 	// 未来可以在这里添加 httpManager 等其他状态管理器
 
+	// cli 终端资源管理器
+	terminalInputManager  *cli.TerminalInputManager
+	terminalOutputManager *cli.TerminalOutputManager
+
+	// preopens 是本资源上下文中打开的预打开目录，wasi:filesystem/preopens 的各个版本共用。
+	// 它们不在 filesystemManager 中，Guest 无法通过句柄使用或丢弃它们。
+	preopensMu     sync.Mutex
+	preopens       []*filesystem.Descriptor
+	preopensOpened bool
+
+	// stdio 是本资源上下文中的标准流，由 wasi:cli 在需要时创建。
+	stdioMu sync.Mutex
+	stdio   *cli.Stdio
+
 	implementations []Implementation
+
+	// 通过 InstantiateModule 创建的 Guest 实例，每个实例拥有独立的 Host。
+	instancesMu sync.Mutex
+	instances   map[api.Module]*Host
+	// 仅对实例 Host 有效：为该 Guest 单独实例化的匿名宿主模块。
+	hostModules []api.Module
 }
 
 // ModuleOption 是用于配置 Host 的选项函数。
@@ -49,22 +81,8 @@ type ModuleOption func(*Host)
 
 // NewHost 创建一个新的 Host 实例，并应用所有提供的模块选项。
 func NewHost(opts ...ModuleOption) *Host {
-	streamManager, pollManager, errorManager := io.NewManager()
-	h := &Host{
-		streamManager: streamManager,
-		errorManager:  errorManager,
-		pollManager:   pollManager,
-		httpManager:   http.NewHTTPManager(streamManager, pollManager),
-		tlsManager:    tls.NewTLSManager(),
-
-		filesystemManager:           filesystem.NewManager(),
-		directoryEntryStreamManager: filesystem.NewDirectoryEntryStreamManager(),
-
-		networkManager:              sockets.NewNetworkManager(),
-		tcpSocketManager:            sockets.NewTCPSocketManager(),
-		udpSocketManager:            sockets.NewUDPSocketManager(),
-		resolveAddressStreamManager: sockets.NewResolveAddressStreamManager(),
-	}
+	h := &Host{}
+	h.initManagers()
 
 	for _, opt := range opts {
 		opt(h)
@@ -73,11 +91,67 @@ func NewHost(opts ...ModuleOption) *Host {
 	return h
 }
 
+// WithMaxBlockDuration 限制 poll、blocking-read 等阻塞调用单次最长的等待时间，零值表示不限制。
+// 超时后调用会陷入 trap，用于终止失控的 Guest。
+func WithMaxBlockDuration(d time.Duration) ModuleOption {
+	return func(h *Host) {
+		h.blocker.MaxBlock = d
+	}
+}
+
+// WithVirtualNetwork 让 wasi:sockets 使用内存中的虚拟网络代替系统网络，Guest 的套接字不再占用系统端口。
+// 多个 Host 或通过 InstantiateModule 创建的多个 Guest 可以共享同一个虚拟网络互相通信，
+// Go 代码通过它的 Listen、Dial 和 ListenPacket 接入。
+func WithVirtualNetwork(n *sockets.VirtualNetwork) ModuleOption {
+	return func(h *Host) {
+		h.virtualNetwork = n
+	}
+}
+
+// WithResolver 设置 wasi:sockets 解析域名时使用的解析器，默认使用系统解析器。
+func WithResolver(r sockets.Resolver) ModuleOption {
+	return func(h *Host) {
+		h.resolver = r
+	}
+}
+
+// WithMaxReadBufferSize 设置 TCP 连接、TLS 连接和 HTTP 请求体、响应体输入流的读取高水位，
+// 后台读取的数据达到 size 字节而 Guest 还没有取走时暂停读取。零值表示使用默认的 256 KiB。
+func WithMaxReadBufferSize(size int) ModuleOption {
+	return func(h *Host) {
+		h.maxReadBufferSize = size
+	}
+}
+
+// initManagers 为 Host 创建一组全新的资源管理器。
+func (h *Host) initManagers() {
+	streamManager, pollManager, errorManager := io.NewManager()
+	h.streamManager = streamManager
+	h.errorManager = errorManager
+	h.pollManager = pollManager
+	h.blocker = io.NewBlocker()
+	h.httpManager = http.NewHTTPManager(streamManager, pollManager)
+	h.tlsManager = tls.NewTLSManager()
+
+	h.filesystemManager = filesystem.NewManager()
+	h.directoryEntryStreamManager = filesystem.NewDirectoryEntryStreamManager()
+
+	h.networkManager = sockets.NewNetworkManager()
+	h.tcpSocketManager = sockets.NewTCPSocketManager()
+	h.udpSocketManager = sockets.NewUDPSocketManager()
+	h.resolveAddressStreamManager = sockets.NewResolveAddressStreamManager()
+
+	h.terminalInputManager = cli.NewTerminalInputManager()
+	h.terminalOutputManager = cli.NewTerminalOutputManager()
+}
+
 func (h *Host) AddImplementation(impl Implementation) {
 	h.implementations = append(h.implementations, impl)
 }
 
 // Instantiate 将所有已配置的模块实例化到 wazero 运行时。
+// 以这种方式链接的所有 Guest 共享同一组资源管理器；
+// 如果需要在同一个运行时中隔离多个 Guest，请使用 InstantiateModule。
 func (h *Host) Instantiate(ctx context.Context, r wazero.Runtime) error {
 	for _, impl := range h.implementations {
 		for _, version := range impl.Versions() {
@@ -107,6 +181,11 @@ func (h *Host) PollManager() *io.PollManager {
 	return h.pollManager
 }
 
+// Blocker 返回阻塞调用使用的 Blocker，它在实例关闭时打断所有等待。
+func (h *Host) Blocker() *io.Blocker {
+	return h.blocker
+}
+
 func (h *Host) HTTPManager() *http.HTTPManager {
 	return h.httpManager
 }
@@ -134,6 +213,63 @@ func (h *Host) ResolveAddressStreamManager() *sockets.ResolveAddressStreamManage
 	return h.resolveAddressStreamManager
 }
 
+// VirtualNetwork 返回 Guest 使用的虚拟网络，使用系统网络时返回 nil。
+func (h *Host) VirtualNetwork() *sockets.VirtualNetwork {
+	return h.virtualNetwork
+}
+
+// Resolver 返回 Guest 解析域名时使用的解析器。
+func (h *Host) Resolver() sockets.Resolver {
+	if h.resolver == nil {
+		return net.DefaultResolver
+	}
+	return h.resolver
+}
+
+// MaxReadBufferSize 返回输入流的读取高水位，零值表示使用默认值。
+func (h *Host) MaxReadBufferSize() int {
+	return h.maxReadBufferSize
+}
+
 func (h *Host) TLSManager() *tls.TLSManager {
 	return h.tlsManager
 }
+
+// TerminalInputManager 返回 terminal-input 资源管理器。
+func (h *Host) TerminalInputManager() *cli.TerminalInputManager {
+	return h.terminalInputManager
+}
+
+// TerminalOutputManager 返回 terminal-output 资源管理器。
+func (h *Host) TerminalOutputManager() *cli.TerminalOutputManager {
+	return h.terminalOutputManager
+}
+
+// Preopens 返回本资源上下文中的预打开目录。
+// 第一次调用时通过 open 打开它们，之后的调用直接返回同一组目录；open 返回错误时不记录结果。
+// 返回的目录由 Host 持有，在资源上下文释放时关闭。
+func (h *Host) Preopens(open func() ([]*filesystem.Descriptor, error)) ([]*filesystem.Descriptor, error) {
+	h.preopensMu.Lock()
+	defer h.preopensMu.Unlock()
+	if !h.preopensOpened {
+		roots, err := open()
+		if err != nil {
+			return nil, err
+		}
+		h.preopens = roots
+		h.preopensOpened = true
+	}
+	return h.preopens, nil
+}
+
+// Stdio 返回本资源上下文中的标准流。
+// 第一次调用时通过 open 创建它们，之后的调用直接返回同一组标准流。
+// 返回的标准流由 Host 持有，在资源上下文释放时关闭。
+func (h *Host) Stdio(open func() *cli.Stdio) *cli.Stdio {
+	h.stdioMu.Lock()
+	defer h.stdioMu.Unlock()
+	if h.stdio == nil {
+		h.stdio = open()
+	}
+	return h.stdio
+}
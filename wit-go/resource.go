@@ -111,3 +111,20 @@ func (m *ResourceManager[T]) Range(f func(handle uint32, resource T) bool) {
 		}
 	}
 }
+
+// Clear removes all resources from the manager. If a destructor was provided
+// on creation, it is called on every removed resource. The destructors run
+// after the lock is released, so they may safely access the manager again.
+func (m *ResourceManager[T]) Clear() {
+	m.mu.Lock()
+	handles := m.handles
+	m.handles = make(map[uint32]T)
+	m.mu.Unlock()
+
+	if m.destructor == nil {
+		return
+	}
+	for _, resource := range handles {
+		m.destructor(resource)
+	}
+}